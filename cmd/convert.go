@@ -1,13 +1,28 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"math"
 	"os"
+	"path"
+	"path/filepath"
+	"runtime"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/nilptrderef/gogeo/internal/common"
+	"github.com/nilptrderef/gogeo/internal/crs"
+	"github.com/nilptrderef/gogeo/internal/dbase"
 	"github.com/nilptrderef/gogeo/internal/shapefile"
 	"github.com/nilptrderef/gogeo/internal/simplification"
 	"github.com/tinylib/msgp/msgp"
@@ -18,19 +33,63 @@ import (
 var (
 	ShpPath            string
 	DbfPath            string
+	PrjPath            string
+	CpgPath            string
 	SimplifyPercentage float64
 	SimplifyAlgorithm  string
+	SimplifyTolerance  float64
+	MinimumArea        float64
+	SimplifyPoints     int
+	SimplifyBytes      int
 	PreProject         bool
 	StateFilter        []string
 	OutFile            string
+	LayerNames         []string
+	Topology           bool
+	Intersections      string
+	Levels             bool
+	Workers            int
+	Report             string
 )
 
 var ConvertCmd = &cobra.Command{
 	Use:   "convert",
-	Short: "Convert a shapefile, and optionally a '.dbf' file into GeoJSON",
+	Short: "Convert a shapefile, and optionally a '.dbf' file into GeoJSON, msgpack or another shapefile",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var simplifier simplification.Simplifier
-		if cmd.Flags().Changed("sp") {
+		if SimplifyTolerance < 0 || MinimumArea < 0 {
+			return fmt.Errorf("tolerance and minimum area have to be positive")
+		}
+		if cmd.Flags().Changed("tolerance") && !cmd.Flags().Changed("sp") {
+			// Simplify by tolerance alone rather than keeping every point by percentage.
+			SimplifyPercentage = 0
+		}
+		if SimplifyPoints < 0 || SimplifyBytes < 0 {
+			return fmt.Errorf("point and byte budgets have to be positive")
+		}
+		if cmd.Flags().Changed("points") && cmd.Flags().Changed("bytes") {
+			return fmt.Errorf("--points and --bytes cannot be combined")
+		}
+		budget := cmd.Flags().Changed("points") || cmd.Flags().Changed("bytes")
+		if Levels && (budget || cmd.Flags().Changed("sp") || cmd.Flags().Changed("tolerance") || Topology) {
+			return fmt.Errorf("--levels keeps the full geometry and cannot be combined with other simplification")
+		}
+		if budget && (cmd.Flags().Changed("sp") || cmd.Flags().Changed("tolerance") || Topology) {
+			return fmt.Errorf("a point or byte budget cannot be combined with --sp, --tolerance or --topology")
+		}
+		if _, err := validation(); err != nil {
+			return err
+		}
+		if MinimumArea > 0 && (Topology || budget || Levels) {
+			return fmt.Errorf("--min-area cannot be combined with --topology, --levels or a point or byte budget")
+		}
+		if Intersections != "ignore" && (Topology || budget || Levels) {
+			return fmt.Errorf("--intersections cannot be combined with --topology, --levels or a point or byte budget")
+		}
+		if Report != "" && (Topology || budget || Levels) {
+			return fmt.Errorf("--report cannot be combined with --topology, --levels or a point or byte budget")
+		}
+		if cmd.Flags().Changed("sp") || cmd.Flags().Changed("tolerance") || cmd.Flags().Changed("min-area") || budget || Levels {
 			switch SimplifyAlgorithm {
 			case "vis":
 				simplifier = simplification.VisvalingamSimplifier{}
@@ -41,82 +100,949 @@ var ConvertCmd = &cobra.Command{
 			}
 		}
 
-		file, err := os.Open(ShpPath)
+		if !strings.EqualFold(filepath.Ext(ShpPath), ".zip") {
+			return convert(fileSource(), OutFile, Report, simplifier)
+		}
+
+		archive, err := shapefile.OpenArchive(ShpPath)
 		if err != nil {
 			return err
 		}
-		defer file.Close()
+		defer archive.Close()
 
-		shp, err := shapefile.Parse(file)
-		if err != nil {
+		layers := archive.Layers
+		if len(LayerNames) > 0 {
+			layers = nil
+			for _, name := range LayerNames {
+				layer, err := archive.Layer(name)
+				if err != nil {
+					return err
+				}
+				layers = append(layers, layer)
+			}
+		}
+		if len(layers) > 1 && OutFile == "" {
+			return fmt.Errorf("%s has %d layers, pick one with --layer or pass an output path", ShpPath, len(layers))
+		}
+
+		for _, layer := range layers {
+			// Each layer gets its own output, named after the layer when there are several.
+			outFile, reportFile := OutFile, Report
+			if len(layers) > 1 {
+				outFile = layerPath(OutFile, layer)
+				if Report != "" {
+					reportFile = layerPath(Report, layer)
+				}
+			}
+			if err := convert(archiveSource(layer), outFile, reportFile, simplifier); err != nil {
+				return fmt.Errorf("%s: %w", layer.Name, err)
+			}
+		}
+		return nil
+	},
+}
+
+// layerPath suffixes a path with the name of a layer, before its extension.
+func layerPath(file string, layer *shapefile.Layer) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + "_" + path.Base(layer.Name) + ext
+}
+
+// source is a shapefile layer to convert, either files on disk or a layer of a zip archive. Its files are
+// opened by extension, missing files return an error wrapping fs.ErrNotExist.
+type source struct {
+	name string
+	open func(ext string) (io.ReadCloser, error)
+}
+
+// fileSource reads the files passed on the command line. The .prj file defaults to the one next to the
+// shapefile, the .cpg and memo files to the ones next to the dbase file.
+func fileSource() source {
+	return source{name: ShpPath, open: func(ext string) (io.ReadCloser, error) {
+		switch ext {
+		case ".shp":
+			return os.Open(ShpPath)
+		case ".dbf":
+			if DbfPath == "" {
+				return nil, fs.ErrNotExist
+			}
+			return os.Open(DbfPath)
+		case ".prj":
+			if PrjPath != "" {
+				return os.Open(PrjPath)
+			}
+			return os.Open(strings.TrimSuffix(ShpPath, filepath.Ext(ShpPath)) + ".prj")
+		case ".cpg":
+			if CpgPath != "" {
+				return os.Open(CpgPath)
+			}
+			if DbfPath == "" {
+				return nil, fs.ErrNotExist
+			}
+			return os.Open(strings.TrimSuffix(DbfPath, filepath.Ext(DbfPath)) + ".cpg")
+		case ".dbt", ".fpt":
+			if DbfPath == "" {
+				return nil, fs.ErrNotExist
+			}
+			return os.Open(strings.TrimSuffix(DbfPath, filepath.Ext(DbfPath)) + ext)
+		}
+		return nil, fs.ErrNotExist
+	}}
+}
+
+// archiveSource reads the files of a layer straight from its archive. Paths passed on the command line take
+// precedence over the files of the archive.
+func archiveSource(layer *shapefile.Layer) source {
+	return source{name: layer.Name + ".shp", open: func(ext string) (io.ReadCloser, error) {
+		switch {
+		case ext == ".dbf" && DbfPath != "":
+			return os.Open(DbfPath)
+		case ext == ".prj" && PrjPath != "":
+			return os.Open(PrjPath)
+		case ext == ".cpg" && CpgPath != "":
+			return os.Open(CpgPath)
+		}
+		return layer.Open(ext)
+	}}
+}
+
+// convert writes a single layer to outFile, or to stdout when it is empty, and the quality of its
+// simplification to reportFile when it is not empty.
+func convert(in source, outFile, reportFile string, simplifier simplification.Simplifier) error {
+	file, err := in.open(".shp")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var dbf io.Reader
+	dfile, err := in.open(".dbf")
+	if err == nil {
+		defer dfile.Close()
+		dbf = bufio.NewReader(dfile)
+	} else if !errors.Is(err, fs.ErrNotExist) || DbfPath != "" {
+		return err
+	}
+
+	stream, err := shapefile.NewStream(bufio.NewReader(file), dbf)
+	if err != nil {
+		return err
+	}
+	if stream.Dbase != nil {
+		if err := readCodePage(in, stream.Dbase); err != nil {
+			return err
+		}
+		if err := readMemo(in, stream.Dbase); err != nil {
 			return err
 		}
+	}
 
-		if DbfPath != "" {
-			dfile, err := os.Open(DbfPath)
+	input, err := readCrs(in, stream.Header)
+	if err != nil {
+		return err
+	}
+
+	// Projection needs longitude and latitude, anything else is converted first or refused.
+	output := input
+	var toLonLat func(common.Point) common.Point
+	if PreProject {
+		if input != nil {
+			toLonLat, err = input.ToLonLat()
 			if err != nil {
-				return err
+				return fmt.Errorf("cannot project %s: %w", in.name, err)
 			}
-			defer dfile.Close()
+		}
+		output, err = crs.ParseString(crs.AlbersUSA)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Geographic coordinates are measured along the parallel through the middle of the layer.
+	latitude := (stream.Header.Shape.Mbr.Start.Y + stream.Header.Shape.Mbr.End.Y) / 2
+	simplifier, err = withUnits(simplifier, output, latitude)
+	if err != nil {
+		return err
+	}
+
+	var report *qualityReport
+	if reportFile != "" {
+		meters, squareMeters, err := metersPerUnit(output, latitude)
+		if err != nil {
+			return err
+		}
+		report = &qualityReport{meters: meters, squareMeters: squareMeters}
+	}
 
-			if err := shp.LoadAttributes(dfile); err != nil {
+	records := convertRecords(stream, toLonLat)
+	if (SimplifyPoints > 0 || SimplifyBytes > 0 || Levels) && !strings.HasSuffix(outFile, "msgpk") {
+		return fmt.Errorf("--points, --bytes and --levels only apply to msgpack output")
+	}
+	if strings.HasSuffix(outFile, ".shp") {
+		if Topology {
+			return fmt.Errorf("--topology only applies to GeoJSON and msgpack output")
+		}
+		var fields []dbase.FieldDescriptor
+		if stream.Dbase != nil {
+			if fields, err = utf8Fields(in, stream.Dbase); err != nil {
 				return err
 			}
 		}
+		if err := writeShapefile(strings.TrimSuffix(outFile, ".shp"), stream.Header.Shape.Type, fields, output, records, simplifier, report); err != nil {
+			return err
+		}
+		return report.write(reportFile)
+	}
+
+	var out *os.File
+	if outFile != "" {
+		out, err = os.Create(outFile)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	} else {
+		out = os.Stdout
+	}
+
+	if strings.HasSuffix(outFile, "msgpk") {
+		err = writeMap(out, output, records, simplifier, report)
+	} else {
+		err = writeGeoJson(out, output, records, simplifier, report)
+	}
+	if err != nil {
+		return err
+	}
+	return report.write(reportFile)
+}
+
+// withUnits sets the tolerance of the simplifier from the --tolerance flag, converted from meters, or
+// square kilometers for Visvalingam-Whyatt, into the units of the output coordinates, and its minimum area
+// from the --min-area flag, converted from square kilometers. Coordinates without a .prj file are taken to
+// be NAD83 longitude and latitude, measured at the given latitude.
+func withUnits(simplifier simplification.Simplifier, output *crs.CRS, latitude float64) (simplification.Simplifier, error) {
+	if SimplifyTolerance == 0 && MinimumArea == 0 {
+		return simplifier, nil
+	}
+	meters, squareMeters, err := metersPerUnit(output, latitude)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s := simplifier.(type) {
+	case simplification.DouglasPeuckerSimplifier:
+		s.Tolerance = SimplifyTolerance / meters
+		s.MinimumArea = MinimumArea * 1e6 / squareMeters
+		return s, nil
+	case simplification.VisvalingamSimplifier:
+		s.Tolerance = SimplifyTolerance * 1e6 / squareMeters
+		s.MinimumArea = MinimumArea * 1e6 / squareMeters
+		return s, nil
+	}
+	return simplifier, nil
+}
+
+// metersPerUnit returns the length of a unit of the output coordinates in meters and the area of a square
+// unit in square meters at the given latitude, see crs.CRS.MetersPerUnitAt. Coordinates without a .prj file
+// are taken to be NAD83 longitude and latitude.
+func metersPerUnit(output *crs.CRS, latitude float64) (float64, float64, error) {
+	if output == nil {
+		var err error
+		if output, err = crs.ParseString(crs.NAD83); err != nil {
+			return 0, 0, err
+		}
+	}
+	meters := output.MetersPerUnitAt(latitude)
+	return meters, meters * output.MetersPerUnit(), nil
+}
+
+// validation returns what is done about rings crossing after simplifying, from the --intersections flag.
+func validation() (simplification.Validation, error) {
+	switch Intersections {
+	case "ignore":
+		return simplification.NoValidation, nil
+	case "report":
+		return simplification.ReportIntersections, nil
+	case "repair":
+		return simplification.RepairIntersections, nil
+	}
+	return 0, fmt.Errorf("invalid intersections %q, expected 'ignore', 'report' or 'repair'", Intersections)
+}
+
+// recordId names a record by GEOID when it has one, by record number otherwise.
+func recordId(record shapefile.Record) string {
+	if id := record.Attrs.String("GEOID"); id != "" {
+		return id
+	}
+	return fmt.Sprintf("record %d", record.Number)
+}
+
+// reportIntersections warns about a record whose rings cross after simplifying, see recordId.
+func reportIntersections(record shapefile.Record, valid bool) {
+	if valid {
+		return
+	}
+	id := recordId(record)
+	if Intersections == "repair" {
+		fmt.Fprintf(os.Stderr, "%s: rings crossed before simplifying and could not be repaired\n", id)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s: rings cross after simplifying\n", id)
+}
+
+// qualityReport collects the quality of every simplified feature, see simplification.Measure, to write it
+// along with the totals of the layer once it is converted.
+type qualityReport struct {
+	// Length of a unit of the coordinates in meters and area of a square unit in square meters
+	meters       float64
+	squareMeters float64
+	rows         []qualityRow
+	total        simplification.Quality
+}
+
+// qualityRow is the quality of a feature, or of the whole layer, in kilometers and meters.
+type qualityRow struct {
+	Id                    string  `json:"id,omitempty"`
+	Points                int     `json:"points"`
+	SimplifiedPoints      int     `json:"simplified_points"`
+	PointReduction        float64 `json:"point_reduction"`
+	AreaKm2               float64 `json:"area_km2"`
+	SimplifiedAreaKm2     float64 `json:"simplified_area_km2"`
+	AreaChange            float64 `json:"area_change"`
+	PerimeterKm           float64 `json:"perimeter_km"`
+	SimplifiedPerimeterKm float64 `json:"simplified_perimeter_km"`
+	PerimeterChange       float64 `json:"perimeter_change"`
+	HausdorffM            float64 `json:"hausdorff_m"`
+}
+
+var qualityColumns = []string{
+	"id", "points", "simplified_points", "point_reduction", "area_km2", "simplified_area_km2", "area_change",
+	"perimeter_km", "simplified_perimeter_km", "perimeter_change", "hausdorff_m",
+}
+
+// add reports the quality of a record, see recordId. Records that were not measured, such as points, are
+// left out.
+func (r *qualityReport) add(record shapefile.Record, quality *simplification.Quality) {
+	if r == nil || quality == nil {
+		return
+	}
+	r.rows = append(r.rows, r.row(recordId(record), *quality))
+	r.total.Add(*quality)
+}
+
+// row converts a quality from the units of the coordinates.
+func (r *qualityReport) row(id string, q simplification.Quality) qualityRow {
+	km := r.meters / 1000
+	km2 := r.squareMeters / 1e6
+	return qualityRow{
+		Id:                    id,
+		Points:                q.Points,
+		SimplifiedPoints:      q.SimplifiedPoints,
+		PointReduction:        q.PointReduction(),
+		AreaKm2:               q.Area * km2,
+		SimplifiedAreaKm2:     q.SimplifiedArea * km2,
+		AreaChange:            q.AreaChange(),
+		PerimeterKm:           q.Perimeter * km,
+		SimplifiedPerimeterKm: q.SimplifiedPerimeter * km,
+		PerimeterChange:       q.PerimeterChange(),
+		HausdorffM:            q.Hausdorff * r.meters,
+	}
+}
 
-		if len(StateFilter) > 0 {
-			shp.Records = slices.DeleteFunc(shp.Records, func(record shapefile.Record) bool {
-				state, found := common.StateAbbrFips[record.Attrs["STATEFP"]]
-				return !found || slices.Contains(StateFilter, state)
-			})
+// write writes the report to path, as CSV with the totals on a last line named "total" when path has a .csv
+// extension, and as a JSON object of the features and the totals otherwise.
+func (r *qualityReport) write(path string) error {
+	if r == nil {
+		return nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	total := r.row("", r.total)
+	if !strings.EqualFold(filepath.Ext(path), ".csv") {
+		w := bufio.NewWriter(file)
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(struct {
+			Features []qualityRow `json:"features"`
+			Total    qualityRow   `json:"total"`
+		}{append([]qualityRow{}, r.rows...), total})
+		if err != nil {
+			return err
 		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		return file.Close()
+	}
+
+	w := csv.NewWriter(file)
+	w.Write(qualityColumns)
+	total.Id = "total"
+	for _, row := range append(r.rows, total) {
+		w.Write([]string{
+			row.Id,
+			strconv.Itoa(row.Points),
+			strconv.Itoa(row.SimplifiedPoints),
+			formatQuality(row.PointReduction),
+			formatQuality(row.AreaKm2),
+			formatQuality(row.SimplifiedAreaKm2),
+			formatQuality(row.AreaChange),
+			formatQuality(row.PerimeterKm),
+			formatQuality(row.SimplifiedPerimeterKm),
+			formatQuality(row.PerimeterChange),
+			formatQuality(row.HausdorffM),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// formatQuality formats a measure of a report with the shortest representation that reads back the same.
+func formatQuality(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// readCodePage applies the code page of the .cpg file, when there is one, to the dbase file. A code page that
+// is not supported is reported as a warning and the text is decoded with the language driver of the header
+// instead, or as Latin-1 when it has none.
+func readCodePage(in source, db *dbase.Dbase) error {
+	file, err := in.open(".cpg")
+	if errors.Is(err, fs.ErrNotExist) && CpgPath == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-		if PreProject {
-			shp.Project()
+	cp, err := dbase.ParseCpg(file)
+	if errors.Is(err, dbase.ErrUnsupportedCodePage) {
+		if db.CodePage == nil {
+			db.CodePage = dbase.Latin1
 		}
+		fmt.Fprintf(os.Stderr, "%s: %v, decoding text as %s\n", in.name, err, db.CodePage.Name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("code page of %s: %w", in.name, err)
+	}
+	db.CodePage = cp
+	return nil
+}
+
+// readMemo attaches the memo file of the dbase file, when it has one, so that memo fields hold their text.
+// Tables missing their memo file keep the block numbers instead.
+func readMemo(in source, db *dbase.Dbase) error {
+	ext := db.Header.MemoExt()
+	if ext == "" {
+		return nil
+	}
+	file, err := in.open(ext)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
 
-		var out *os.File
-		if OutFile != "" {
-			out, err = os.Create(OutFile)
+	// Memos are read by block number, which needs random access that archives do not offer.
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	if db.Memo, err = dbase.NewMemoFile(bytes.NewReader(data), &db.Header); err != nil {
+		return fmt.Errorf("memo file of %s: %w", in.name, err)
+	}
+	return nil
+}
+
+// utf8Fields returns the fields of the dbase file with its text fields widened to hold their longest value
+// once encoded as UTF-8, up to 254 bytes. Text decoded from a single byte code page takes more bytes as UTF-8,
+// e.g. the accents of Windows-1252 take two, so the dbase file is read once ahead of the conversion.
+func utf8Fields(in source, db *dbase.Dbase) ([]dbase.FieldDescriptor, error) {
+	fields := slices.Clone(db.Fields)
+	if db.CodePage == dbase.UTF8 {
+		return fields, nil
+	}
+
+	file, err := in.open(".dbf")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := dbase.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", in.name, err)
+	}
+	rows.CodePage = db.CodePage
+
+	lengths := make(map[string]int, len(fields))
+	for record, err := range rows.Records() {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", in.name, err)
+		}
+		for name, value := range record.Values {
+			if text, ok := value.(string); ok {
+				lengths[name] = max(lengths[name], len(text))
+			}
+		}
+	}
+	for i, field := range fields {
+		if field.Type == dbase.Character || field.Type == dbase.Varchar {
+			fields[i].Length = uint8(min(max(int(field.Length), lengths[field.GetName()]), 254))
+		}
+	}
+	return fields, nil
+}
+
+// readCrs reads the coordinate system of the input from the .prj file. Without a .prj file the input is
+// assumed to be longitude and latitude, which is checked against the bounding box when projecting.
+func readCrs(in source, header shapefile.Header) (*crs.CRS, error) {
+	file, err := in.open(".prj")
+	if errors.Is(err, fs.ErrNotExist) && PrjPath == "" {
+		mbr := header.Shape.Mbr
+		if PreProject && (mbr.Start.X < -180 || mbr.End.X > 360 || mbr.Start.Y < -90 || mbr.End.Y > 90) {
+			return nil, fmt.Errorf("cannot project %s: coordinates are not longitude and latitude and there is no .prj file", in.name)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	input, err := crs.Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("projection of %s: %w", in.name, err)
+	}
+	return input, nil
+}
+
+// convertRecords filters and projects the records of the stream one at a time. Coordinates are converted
+// with toLonLat, when set, before projecting them.
+func convertRecords(stream *shapefile.Stream, toLonLat func(common.Point) common.Point) iter.Seq2[shapefile.Record, error] {
+	return func(yield func(shapefile.Record, error) bool) {
+		for record, err := range stream.Records() {
 			if err != nil {
+				yield(record, err)
+				return
+			}
+			if filtered(record) {
+				continue
+			}
+			if PreProject {
+				if toLonLat != nil {
+					record.Geometry.Transform(toLonLat)
+				}
+				record.Project()
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
+
+// simplified is a record along with the value a worker made of it, see simplifyAll.
+type simplified[T any] struct {
+	record shapefile.Record
+	value  T
+	// Whether the rings of the record were found valid, see reportIntersections
+	valid bool
+	// How much shape the record lost, when a report was asked for
+	quality *simplification.Quality
+}
+
+// simplifyAll runs fn on every record on Workers goroutines, defaulting to GOMAXPROCS, and yields the results
+// in the order of the records, so that the output is the same whatever the number of workers. fn fills in the
+// item of a record and may change the record.
+func simplifyAll[T any](records iter.Seq2[shapefile.Record, error], fn func(*simplified[T]) error) iter.Seq2[simplified[T], error] {
+	workers := Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if workers == 1 {
+		return func(yield func(simplified[T], error) bool) {
+			for record, err := range records {
+				if err != nil {
+					yield(simplified[T]{record: record}, err)
+					return
+				}
+				item := simplified[T]{record: record, valid: true}
+				err = fn(&item)
+				if !yield(item, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+
+	// job is a record handed to a worker, which sends the result back on its own channel.
+	type job struct {
+		item   simplified[T]
+		err    error
+		result chan job
+	}
+
+	return func(yield func(simplified[T], error) bool) {
+		done := make(chan struct{})
+		jobs := make(chan job)
+		// Jobs are queued in order as they are handed out, which bounds how far workers get ahead.
+		pending := make(chan job, workers*2)
+		defer func() {
+			// The records are not read anymore once this returns, so stopping early waits for the reader.
+			close(done)
+			for range pending {
+			}
+		}()
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := range jobs {
+					j.err = fn(&j.item)
+					j.result <- j
+				}
+			}()
+		}
+
+		go func() {
+			defer close(pending)
+			defer func() {
+				close(jobs)
+				wg.Wait()
+			}()
+			for record, err := range records {
+				j := job{item: simplified[T]{record: record, valid: true}, err: err, result: make(chan job, 1)}
+				if err != nil {
+					// Errors skip the workers but keep their place in the queue.
+					j.result <- j
+				}
+				select {
+				case pending <- j:
+				case <-done:
+					return
+				}
+				if err != nil {
+					return
+				}
+				select {
+				case jobs <- j:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for j := range pending {
+			j = <-j.result
+			if !yield(j.item, j.err) || j.err != nil {
+				return
+			}
+		}
+	}
+}
+
+// filtered reports whether a record should be left out of the output based on the state filter.
+func filtered(record shapefile.Record) bool {
+	if len(StateFilter) == 0 {
+		return false
+	}
+	state, found := common.StateAbbrFips[record.Attrs.String("STATEFP")]
+	return !found || slices.Contains(StateFilter, state)
+}
+
+// writeMap encodes the polygon records as a msgpack Map. Only the simplified counties are kept in memory,
+// unless borders are simplified along with the neighboring counties, see Map.SimplifyTopology, or the map
+// is simplified to a point or byte budget, see Map.SimplifyBudget, or ranked into levels, see Map.AddLevels.
+func writeMap(out io.Writer, output *crs.CRS, records iter.Seq2[shapefile.Record, error], simplifier simplification.Simplifier, report *qualityReport) error {
+	var m common.Map
+	if output != nil {
+		m.Crs = output.WKT()
+	}
+	m.Mbr.Start.X = math.MaxFloat64
+	m.Mbr.Start.Y = math.MaxFloat64
+	m.Mbr.End.X = -math.MaxFloat64
+	m.Mbr.End.Y = -math.MaxFloat64
+
+	whole := Topology || SimplifyPoints > 0 || SimplifyBytes > 0 || Levels
+	check, err := validation()
+	if err != nil {
+		return err
+	}
+	counties := simplifyAll(records, func(item *simplified[*common.County]) error {
+		county, ok := item.record.ToCounty()
+		if !ok {
+			return nil
+		}
+		item.value = &county
+		if whole {
+			return nil
+		}
+		var err error
+		item.valid, err = county.SimplifyValidated(simplifier, SimplifyPercentage, check)
+		if report != nil {
+			quality := simplification.Measure(item.record.Coordinates(), county.Coordinates())
+			item.quality = &quality
+		}
+		return err
+	})
+	for item, err := range counties {
+		if err != nil {
+			return err
+		}
+
+		county := item.value
+		if county == nil {
+			continue
+		}
+		reportIntersections(item.record, item.valid)
+		report.add(item.record, item.quality)
+		if !whole && len(county.Parts) == 0 {
+			// Every ring was below the minimum area.
+			continue
+		}
+
+		m.Mbr.Start.X = min(m.Mbr.Start.X, county.Mbr.Start.X)
+		m.Mbr.End.X = max(m.Mbr.End.X, county.Mbr.End.X)
+		m.Mbr.Start.Y = min(m.Mbr.Start.Y, county.Mbr.Start.Y)
+		m.Mbr.End.Y = max(m.Mbr.End.Y, county.Mbr.End.Y)
+		m.Counties = append(m.Counties, *county)
+	}
+	switch {
+	case Levels:
+		if err := m.AddLevels(simplifier); err != nil {
+			return err
+		}
+	case Topology:
+		if err := m.SimplifyTopology(simplifier, SimplifyPercentage); err != nil {
+			return err
+		}
+	case SimplifyPoints > 0:
+		if err := m.SimplifyBudget(simplifier, SimplifyPoints); err != nil {
+			return err
+		}
+	case SimplifyBytes > 0:
+		points := m.PointBudget(SimplifyBytes)
+		if points < 1 {
+			return fmt.Errorf("a budget of %d bytes leaves no room for any point", SimplifyBytes)
+		}
+		if err := m.SimplifyBudget(simplifier, points); err != nil {
+			return err
+		}
+	}
+
+	writer := msgp.NewWriter(out)
+	if err := m.EncodeMsg(writer); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// writeGeoJson writes every record as a GeoJSON feature as soon as it has been simplified.
+func writeGeoJson(out io.Writer, output *crs.CRS, records iter.Seq2[shapefile.Record, error], simplifier simplification.Simplifier, report *qualityReport) error {
+	writer := bufio.NewWriter(out)
+	encoder := common.NewGeoJsonEncoder(writer)
+	if output != nil {
+		switch code := output.Code(); code {
+		case "EPSG:4326":
+			// WGS84 longitude and latitude is the GeoJSON default and needs no label.
+		case "":
+			encoder.Crs = common.NewGeoJsonCrs(output.Name)
+		default:
+			encoder.Crs = common.NewGeoJsonCrs("urn:ogc:def:crs:" + strings.Replace(code, ":", "::", 1))
+		}
+	}
+	check, err := validation()
+	if err != nil {
+		return err
+	}
+
+	// Shared borders can only be found once every feature has been read, so the features are held back until
+	// then.
+	var collection common.GeoJson
+	features := simplifyAll(records, func(item *simplified[common.GeoJsonFeature]) error {
+		item.value = item.record.ToGeoJsonFeature()
+		if Topology {
+			return nil
+		}
+		var err error
+		item.valid, err = item.value.SimplifyValidated(simplifier, SimplifyPercentage, check)
+		// Only lines and polygons are simplified, anything else is left out of the report.
+		if report != nil && item.record.Coordinates() != nil {
+			quality := simplification.Measure(item.record.Coordinates(), item.value.Coordinates())
+			item.quality = &quality
+		}
+		return err
+	})
+	for item, err := range features {
+		if err != nil {
+			return err
+		}
+
+		if Topology {
+			collection.Features = append(collection.Features, item.value)
+			continue
+		}
+		reportIntersections(item.record, item.valid)
+		report.add(item.record, item.quality)
+		if err := encoder.Encode(item.value); err != nil {
+			return err
+		}
+	}
+	if Topology {
+		if err := collection.SimplifyTopology(simplifier, SimplifyPercentage); err != nil {
+			return err
+		}
+		for _, feature := range collection.Features {
+			if err := encoder.Encode(feature); err != nil {
 				return err
 			}
-		} else {
-			out = os.Stdout
 		}
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
 
-		if strings.HasSuffix(OutFile, "msgpk") {
-			m := shp.ToMap()
-			err = m.SimplifyInPlace(simplifier, SimplifyPercentage)
+// writeShapefile writes the records to a .shp, .shx and, when known, .dbf and .prj file sharing the base path.
+func writeShapefile(base string, st shapefile.ShapeType, fields []dbase.FieldDescriptor, output *crs.CRS, records iter.Seq2[shapefile.Record, error], simplifier simplification.Simplifier, report *qualityReport) error {
+	if output != nil {
+		if err := os.WriteFile(base+".prj", []byte(output.WKT()), 0644); err != nil {
+			return err
+		}
+	}
+
+	shp, err := os.Create(base + ".shp")
+	if err != nil {
+		return err
+	}
+	defer shp.Close()
+
+	shx, err := os.Create(base + ".shx")
+	if err != nil {
+		return err
+	}
+	defer shx.Close()
+
+	writer, err := shapefile.NewWriter(shp, shx, st)
+	if err != nil {
+		return err
+	}
+	check, err := validation()
+	if err != nil {
+		return err
+	}
+
+	var attributes *dbase.Writer
+	if fields != nil {
+		// Attributes are always written as UTF-8, whatever the code page of the input was, see utf8Fields.
+		if err := os.WriteFile(base+".cpg", []byte("UTF-8"), 0644); err != nil {
+			return err
+		}
+
+		dbf, err := os.Create(base + ".dbf")
+		if err != nil {
+			return err
+		}
+		defer dbf.Close()
+
+		// The table is written as dBase III, whatever the format of the input was.
+		fields, columns := dbase.ExportFields(fields)
+		attributes, err = dbase.NewWriter(dbf, fields)
+		if err != nil {
+			return err
+		}
+		attributes.Columns = columns
+
+		if slices.ContainsFunc(fields, func(fd dbase.FieldDescriptor) bool { return fd.Type == dbase.Memo }) {
+			dbt, err := os.Create(base + ".dbt")
 			if err != nil {
 				return err
 			}
+			defer dbt.Close()
 
-			writer := msgp.NewWriter(out)
-			err = m.EncodeMsg(writer)
-			if err != nil {
+			if attributes.Memo, err = dbase.NewMemoWriter(dbt); err != nil {
 				return err
 			}
-			return writer.Flush()
 		}
+	}
 
-		geojson := shp.ToGeoJson()
-		err = geojson.SimplifyInPlace(simplifier, SimplifyPercentage)
+	simplifiedRecords := simplifyAll(records, func(item *simplified[struct{}]) error {
+		// The record is simplified in place, so its original rings are kept aside to measure it.
+		var original [][]float64
+		if report != nil {
+			original = item.record.Coordinates()
+		}
+		var err error
+		item.valid, err = item.record.SimplifyValidated(simplifier, SimplifyPercentage, check)
+		// Only lines and polygons are simplified, anything else is left out of the report.
+		if original != nil {
+			quality := simplification.Measure(original, item.record.Coordinates())
+			item.quality = &quality
+		}
+		return err
+	})
+	for item, err := range simplifiedRecords {
 		if err != nil {
 			return err
 		}
-		encoder := json.NewEncoder(out)
-		return encoder.Encode(geojson)
-	},
+		reportIntersections(item.record, item.valid)
+		report.add(item.record, item.quality)
+
+		if err := writer.Write(item.record.Geometry); err != nil {
+			return err
+		}
+		if attributes != nil {
+			if err := attributes.Write(item.record.Attrs); err != nil {
+				return err
+			}
+		}
+	}
+
+	if attributes != nil {
+		if err := attributes.Close(); err != nil {
+			return err
+		}
+		if attributes.Memo != nil {
+			if err := attributes.Memo.Close(); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Close()
 }
 
 func init() {
-	ConvertCmd.Flags().StringVarP(&ShpPath, "shp", "s", "", "Path of the shapefile")
+	ConvertCmd.Flags().StringVarP(&ShpPath, "shp", "s", "", "Path of the shapefile, or of a '.zip' archive holding one or more shapefiles")
 	ConvertCmd.MarkFlagRequired("shp")
-	ConvertCmd.Flags().StringVarP(&DbfPath, "dbf", "d", "", "Path of the dbase file")
+	ConvertCmd.Flags().StringVarP(&DbfPath, "dbf", "d", "", "Path of the dbase file. Defaults to the '.dbf' file of the layer when reading an archive")
+	ConvertCmd.Flags().StringArrayVar(&LayerNames, "layer", nil, "Layers of the '.zip' archive to convert, by name. Defaults to every layer, each written to the output path suffixed with the layer name")
+	ConvertCmd.Flags().StringVar(&CpgPath, "cpg", "", "Path of the code page file. Defaults to the '.cpg' file next to the dbase file")
+	ConvertCmd.Flags().StringVar(&PrjPath, "prj", "", "Path of the projection file. Defaults to the '.prj' file next to the shapefile")
 	ConvertCmd.Flags().Float64VarP(&SimplifyPercentage, "sp", "p", 1.0, "A float between 0 and 1 that represents the approximate percentage of remaining points")
+	ConvertCmd.Flags().Float64Var(&SimplifyTolerance, "tolerance", 0, "Keep the points that matter more than this tolerance: a distance in meters for Douglas-Peucker or an effective area in square kilometers for Visvalingam-Whyatt. Longitude and latitude are measured at the middle latitude of the layer, distances along its parallel. Combined with '--sp' the points kept by either are kept")
+	ConvertCmd.Flags().Float64Var(&MinimumArea, "min-area", 0, "Drop rings enclosing less than this area in square kilometers when simplifying, along with the holes of dropped outer rings. Measured like '--tolerance'")
+	ConvertCmd.Flags().IntVar(&SimplifyPoints, "points", 0, "Simplify the whole map down to at most this many points, removing the least important points of any county first. Only applies to msgpack output")
+	ConvertCmd.Flags().IntVar(&SimplifyBytes, "bytes", 0, "Simplify the whole map until its msgpack encoding fits in this many bytes, like '--points'")
+	ConvertCmd.Flags().BoolVar(&Levels, "levels", false, "Keep the full geometry along with a detail level for every point, ranked with '--sa', so that the server can simplify the map at any detail. Only applies to msgpack output")
 	ConvertCmd.Flags().StringVarP(&SimplifyAlgorithm, "sa", "a", "doug", "The algorithm to use when simplifying. 'vis' for Visvalingam-Whyatt or 'doug' for Douglas-Peucker)")
+	ConvertCmd.Flags().StringVar(&Intersections, "intersections", "ignore", "What to do about rings crossing themselves or each other after simplifying. 'report' prints the features to stderr and 'repair' restores removed points until they no longer cross")
+	ConvertCmd.Flags().StringVar(&Report, "report", "", "Path to write how much shape every feature lost to simplifying: point reduction, area and perimeter change and Hausdorff distance, along with the totals. A '.csv' extension writes CSV and anything else JSON")
+	ConvertCmd.Flags().IntVar(&Workers, "workers", 0, "Number of records simplified at once. Defaults to the number of CPUs. The output is the same whatever the number")
+	ConvertCmd.Flags().BoolVar(&Topology, "topology", false, "Whether borders shared by neighboring polygons are simplified once so that they stay coincident. Holds every feature in memory")
 	ConvertCmd.Flags().BoolVar(&PreProject, "project", false, "Whether the program should pre-project the points from latitude and longitude.")
 	ConvertCmd.Flags().StringArrayVar(&StateFilter, "state-filter", []string{"PR", "GU", "AS", "VI", "MP"}, "States to filter out of the output based on their STATEFP value.")
-	ConvertCmd.Flags().StringVarP(&OutFile, "output", "o", "", "Output file path")
+	ConvertCmd.Flags().StringVarP(&OutFile, "output", "o", "", "Output file path. A '.msgpk' extension writes a map, a '.shp' extension writes a shapefile bundle and anything else writes GeoJSON")
 }
@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nilptrderef/gogeo/internal/common"
+	"github.com/nilptrderef/gogeo/internal/dbase"
+	"github.com/nilptrderef/gogeo/internal/shapefile"
+	"github.com/nilptrderef/gogeo/internal/simplification"
+)
+
+// edge returns the points from (x0, y0) towards (x1, y1), without the last one, wiggled across the edge.
+// The wiggle only depends on where a point lies so that neighboring counties share their borders exactly.
+func edge(x0, y0, x1, y1 float64, points int) []common.Point {
+	var edge []common.Point
+	for i := range points {
+		t := float64(i) / float64(points)
+		x, y := x0+(x1-x0)*t, y0+(y1-y0)*t
+		wiggle := 0.02 * math.Sin(t*math.Pi) * math.Sin(x*37+y*53)
+		if x0 == x1 {
+			x += wiggle
+		} else {
+			y += wiggle
+		}
+		edge = append(edge, common.Point{X: x, Y: y})
+	}
+	return edge
+}
+
+// writeCounties writes a grid of rows by columns counties in longitude and latitude, each border holding the
+// given number of points, along with their attributes. Every seventh county has a lake. It returns the paths
+// of the .shp and .dbf files.
+func writeCounties(tb testing.TB, rows, columns, points int) (string, string) {
+	tb.Helper()
+	dir := tb.TempDir()
+	shp, err := os.Create(filepath.Join(dir, "counties.shp"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer shp.Close()
+	shx, err := os.Create(filepath.Join(dir, "counties.shx"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer shx.Close()
+	writer, err := shapefile.NewWriter(shp, shx, shapefile.PolygonType)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	const size = 0.5
+	var attrs []map[string]any
+	for r := range rows {
+		for c := range columns {
+			x0, y0 := -110+float64(c)*size, 30+float64(r)*size
+			x1, y1 := x0+size, y0+size
+			// Outer rings run clockwise.
+			var ring []common.Point
+			ring = append(ring, edge(x0, y0, x0, y1, points)...)
+			ring = append(ring, edge(x0, y1, x1, y1, points)...)
+			ring = append(ring, edge(x1, y1, x1, y0, points)...)
+			ring = append(ring, edge(x1, y0, x0, y0, points)...)
+			ring = append(ring, ring[0])
+			parts := []uint32{0}
+
+			n := len(attrs)
+			if n%7 == 0 {
+				// Holes run counter-clockwise.
+				cx, cy := x0+size/2, y0+size/2
+				parts = append(parts, uint32(len(ring)))
+				ring = append(ring, edge(cx-0.1, cy-0.1, cx+0.1, cy-0.1, points/4+1)...)
+				ring = append(ring, edge(cx+0.1, cy-0.1, cx+0.1, cy+0.1, points/4+1)...)
+				ring = append(ring, edge(cx+0.1, cy+0.1, cx-0.1, cy+0.1, points/4+1)...)
+				ring = append(ring, edge(cx-0.1, cy+0.1, cx-0.1, cy-0.1, points/4+1)...)
+				ring = append(ring, ring[parts[1]])
+			}
+
+			polygon := &shapefile.Polygon{Multipart: shapefile.Multipart{Type: shapefile.PolygonType, Parts: parts, Points: ring}}
+			if err := writer.Write(polygon); err != nil {
+				tb.Fatal(err)
+			}
+			attrs = append(attrs, map[string]any{
+				"STATEFP":  "08",
+				"COUNTYFP": fmt.Sprintf("%03d", n+1),
+				"GEOID":    fmt.Sprintf("08%03d", n+1),
+				"NAME":     fmt.Sprintf("County %d", n+1),
+				"ALAND":    int64(n) * 1000003,
+			})
+		}
+	}
+	if err := writer.Close(); err != nil {
+		tb.Fatal(err)
+	}
+
+	dbfPath := filepath.Join(dir, "counties.dbf")
+	dbf, err := os.Create(dbfPath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer dbf.Close()
+	fields, err := dbase.Descriptors(dbase.InferSchema([]string{"STATEFP", "COUNTYFP", "GEOID", "NAME", "ALAND"}, attrs))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	table, err := dbase.NewWriter(dbf, fields)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, row := range attrs {
+		if err := table.Write(row); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := table.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return shp.Name(), dbfPath
+}
+
+// useFlags sets the flags of a conversion, restoring them once the test is over.
+func useFlags(tb testing.TB, shp, dbf string, percentage float64, intersections string) {
+	shpPath, dbfPath, sp, inter, filter, workers := ShpPath, DbfPath, SimplifyPercentage, Intersections, StateFilter, Workers
+	tb.Cleanup(func() {
+		ShpPath, DbfPath, SimplifyPercentage, Intersections, StateFilter, Workers = shpPath, dbfPath, sp, inter, filter, workers
+	})
+	ShpPath, DbfPath, SimplifyPercentage, Intersections, StateFilter = shp, dbf, percentage, intersections, nil
+}
+
+// convertFiles converts to the output extension on the given number of workers and returns the bytes of
+// every file written, by extension, quality report included.
+func convertFiles(t *testing.T, ext string, workers int, simplifier simplification.Simplifier) map[string][]byte {
+	t.Helper()
+	Workers = workers
+	dir := t.TempDir()
+	if err := convert(fileSource(), filepath.Join(dir, "out"+ext), filepath.Join(dir, "report.csv"), simplifier); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := make(map[string][]byte)
+	for _, file := range files {
+		data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		written[filepath.Ext(file.Name())] = data
+	}
+	return written
+}
+
+func TestConvertWorkers(t *testing.T) {
+	shp, dbf := writeCounties(t, 6, 9, 12)
+
+	tests := []struct {
+		name          string
+		simplifier    simplification.Simplifier
+		intersections string
+	}{
+		{"douglas", simplification.DouglasPeuckerSimplifier{}, "ignore"},
+		{"visvalingam", simplification.VisvalingamSimplifier{}, "ignore"},
+		{"repair", simplification.DouglasPeuckerSimplifier{}, "repair"},
+	}
+	for _, test := range tests {
+		for _, ext := range []string{".msgpk", ".json", ".shp"} {
+			t.Run(test.name+ext, func(t *testing.T) {
+				useFlags(t, shp, dbf, 0.2, test.intersections)
+				want := convertFiles(t, ext, 1, test.simplifier)
+				if rows := bytes.Count(want[".csv"], []byte("\n")); rows != 6*9+2 {
+					t.Fatalf("report has %d lines, expected a row for each of the 54 counties", rows)
+				}
+				got := convertFiles(t, ext, 8, test.simplifier)
+				if len(got) != len(want) {
+					t.Fatalf("wrote %d files on 8 workers, %d on one", len(got), len(want))
+				}
+				for file, data := range want {
+					if !bytes.Equal(got[file], data) {
+						t.Errorf("%s differs between 1 and 8 workers", file)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkConvert(b *testing.B) {
+	// About as many counties as the United States, with the points of the 1:500,000 cartographic boundaries.
+	shp, dbf := writeCounties(b, 40, 80, 60)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			useFlags(b, shp, dbf, 0.1, "ignore")
+			Workers = workers
+			out := filepath.Join(b.TempDir(), "out.msgpk")
+			for b.Loop() {
+				if err := convert(fileSource(), out, "", simplification.VisvalingamSimplifier{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadCodePage(t *testing.T) {
+	tests := []struct {
+		cpg  string
+		db   *dbase.CodePage
+		want *dbase.CodePage
+	}{
+		{"UTF-8", dbase.CP850, dbase.UTF8},
+		{"ANSI 1252", nil, dbase.Windows1252},
+		// Code pages which are not supported fall back to the language driver, or to Latin-1.
+		{"Shift_JIS", dbase.CP850, dbase.CP850},
+		{"Shift_JIS", nil, dbase.Latin1},
+	}
+	for _, test := range tests {
+		in := source{name: "test.shp", open: func(ext string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(test.cpg)), nil
+		}}
+		db := &dbase.Dbase{CodePage: test.db}
+		if err := readCodePage(in, db); err != nil {
+			t.Errorf("%s: %v", test.cpg, err)
+			continue
+		}
+		if db.CodePage != test.want {
+			t.Errorf("%s: decoding as %v, want %s", test.cpg, db.CodePage, test.want.Name)
+		}
+	}
+}
+
+func TestConvertCodePage(t *testing.T) {
+	shp, dbf := writeCounties(t, 1, 3, 4)
+
+	// Replace the attributes with names in Windows-1252 which fill their field, and take more bytes as UTF-8.
+	names := []string{"Ñandú", "Peñas", "Soria"}
+	file, err := os.Create(dbf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	table, err := dbase.NewWriter(file, []dbase.FieldDescriptor{{Name: "NAME", Type: dbase.Character, Length: 5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		encoded := strings.NewReplacer("Ñ", "\xd1", "ú", "\xfa", "ñ", "\xf1").Replace(name)
+		if err := table.Write(map[string]any{"NAME": encoded}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(strings.TrimSuffix(dbf, ".dbf")+".cpg", []byte("1252"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	useFlags(t, shp, dbf, 0.2, "ignore")
+	written := convertFiles(t, ".shp", 1, simplification.DouglasPeuckerSimplifier{})
+	if cpg := string(written[".cpg"]); cpg != "UTF-8" {
+		t.Errorf("wrote code page %q", cpg)
+	}
+	rows, err := dbase.NewReader(bytes.NewReader(written[".dbf"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if length := rows.Fields[0].Length; length != 7 {
+		t.Errorf("NAME written with length %d, want 7", length)
+	}
+	rows.CodePage = dbase.UTF8
+	var got []string
+	for record, err := range rows.Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, record.Values["NAME"].(string))
+	}
+	if !slices.Equal(got, names) {
+		t.Errorf("wrote names %q, want %q", got, names)
+	}
+}
+
+func TestConvertReportLeavesOutPoints(t *testing.T) {
+	shp, dbf := writeTowns(t)
+	for _, ext := range []string{".json", ".shp"} {
+		useFlags(t, shp, dbf, 0.5, "ignore")
+		written := convertFiles(t, ext, 2, simplification.DouglasPeuckerSimplifier{})
+		// Only the header and the totals, which measured nothing.
+		want := strings.Join(qualityColumns, ",") + "\ntotal,0,0,0,0,0,0,0,0,0,0\n"
+		if report := string(written[".csv"]); report != want {
+			t.Errorf("%s: reported\n%s\nwant\n%s", ext, report, want)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nilptrderef/gogeo/internal/common"
+	"github.com/nilptrderef/gogeo/internal/dbase"
+	"github.com/nilptrderef/gogeo/internal/shapefile"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	DbfFormat  string
+	DbfColumns []string
+	DbfWhere   []string
+	DbfLayer   string
+	DbfOutput  string
+	DbfDeleted bool
+)
+
+var DbfCmd = &cobra.Command{
+	Use:   "dbf",
+	Short: "Print the schema of a '.dbf' file or dump its rows as CSV or JSON",
+	Long: `Prints the fields, record count and last update of a dbase file, such as the attributes of a
+TIGER/Line shapefile, so that the columns of a vintage can be checked before writing a pipeline. With
+--format csv or json the rows are dumped instead, optionally limited to some columns and to the rows
+matching every --where filter.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in, closer, err := dbfSource()
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+
+		file, err := in.open(".dbf")
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		rows, err := dbase.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return fmt.Errorf("%s: %w", in.name, err)
+		}
+		if err := readCodePage(in, rows.Dbase); err != nil {
+			return err
+		}
+
+		out := io.Writer(os.Stdout)
+		if DbfOutput != "" {
+			file, err := os.Create(DbfOutput)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out = file
+		}
+
+		switch DbfFormat {
+		case "schema":
+			return writeSchema(out, in.name, rows)
+		case "csv", "json":
+		default:
+			return fmt.Errorf("invalid format %q, expected 'schema', 'csv' or 'json'", DbfFormat)
+		}
+
+		if err := readMemo(in, rows.Dbase); err != nil {
+			return err
+		}
+		columns, err := selectColumns(rows.Schema(), DbfColumns)
+		if err != nil {
+			return err
+		}
+		filters, err := parseWhere(rows.Schema(), DbfWhere)
+		if err != nil {
+			return err
+		}
+		rows.IncludeDeleted = DbfDeleted
+
+		selected := filterRows(rows.Records(), filters)
+		if DbfDeleted {
+			columns = append(columns, deletedColumn)
+			selected = markDeleted(selected)
+		}
+		if DbfFormat == "csv" {
+			return writeCsv(out, columns, selected)
+		}
+		return writeJsonRows(out, columns, selected)
+	},
+}
+
+// dbfSource opens the dbase file passed on the command line, or the one of a layer when it is a '.zip'
+// archive. The closer releases the archive once the table has been read.
+func dbfSource() (source, io.Closer, error) {
+	if !strings.EqualFold(filepath.Ext(DbfPath), ".zip") {
+		return source{name: DbfPath, open: fileSource().open}, io.NopCloser(nil), nil
+	}
+
+	archive, err := shapefile.OpenArchive(DbfPath)
+	if err != nil {
+		return source{}, nil, err
+	}
+
+	var layer *shapefile.Layer
+	switch {
+	case DbfLayer != "":
+		layer, err = archive.Layer(DbfLayer)
+	case len(archive.Layers) > 1:
+		err = fmt.Errorf("%s has %d layers, pick one with --layer", DbfPath, len(archive.Layers))
+	default:
+		layer = archive.Layers[0]
+	}
+	if err != nil {
+		archive.Close()
+		return source{}, nil, err
+	}
+
+	return source{name: layer.Name + ".dbf", open: func(ext string) (io.ReadCloser, error) {
+		if ext == ".cpg" && CpgPath != "" {
+			return os.Open(CpgPath)
+		}
+		return layer.Open(ext)
+	}}, archive, nil
+}
+
+// writeSchema prints the header of the table followed by its fields. Records are read to count the deleted
+// ones, which the header does not keep track of.
+func writeSchema(out io.Writer, name string, rows *dbase.Reader) error {
+	rows.IncludeDeleted = true
+	deleted := 0
+	for row, err := range rows.Records() {
+		if err != nil {
+			return err
+		}
+		if row.Deleted {
+			deleted++
+		}
+	}
+
+	codePage := "unknown"
+	if rows.CodePage != nil {
+		codePage = rows.CodePage.Name
+	}
+	memo := "none"
+	if ext := rows.Header.MemoExt(); ext != "" {
+		memo = ext
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "File:\t%s\n", name)
+	fmt.Fprintf(w, "Format:\t%s (version 0x%02X)\n", rows.Header.Format(), rows.Header.Version)
+	fmt.Fprintf(w, "Updated:\t%s\n", rows.Header.Updated().Format(time.DateOnly))
+	fmt.Fprintf(w, "Records:\t%d (%d deleted)\n", rows.Header.RecordCount, deleted)
+	fmt.Fprintf(w, "Record length:\t%d bytes\n", rows.Header.RecordLength)
+	fmt.Fprintf(w, "Code page:\t%s\n", codePage)
+	fmt.Fprintf(w, "Memo file:\t%s\n", memo)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out)
+	return writeFieldTable(out, rows.Schema())
+}
+
+// writeFieldTable prints a line per field with its type, size and the Go type of its values.
+func writeFieldTable(out io.Writer, fields []dbase.Field) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tLENGTH\tDECIMALS\tNULLABLE\tVALUES")
+	for _, field := range fields {
+		fmt.Fprintf(w, "%s\t%c %s\t%d\t%d\t%t\t%s\n", field.Name, field.Type, field.Type, field.Length, field.Decimals, field.Nullable, field.GoType())
+	}
+	return w.Flush()
+}
+
+// selectColumns resolves the names of the columns to dump, ignoring case. Every field is dumped when no
+// names are given.
+func selectColumns(fields []dbase.Field, names []string) ([]string, error) {
+	if len(names) == 0 {
+		columns := make([]string, len(fields))
+		for i, field := range fields {
+			columns[i] = field.Name
+		}
+		return columns, nil
+	}
+
+	columns := make([]string, len(names))
+	for i, name := range names {
+		column, err := findField(fields, name)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+func findField(fields []dbase.Field, name string) (string, error) {
+	for _, field := range fields {
+		if strings.EqualFold(field.Name, strings.TrimSpace(name)) {
+			return field.Name, nil
+		}
+	}
+	return "", fmt.Errorf("table has no field %q", name)
+}
+
+// rowFilter keeps the rows whose column, formatted as text, is equal to value, or differs from it when negated.
+type rowFilter struct {
+	column string
+	value  string
+	negate bool
+}
+
+// parseWhere parses filters of the form FIELD=value or FIELD!=value. The first operator ends the name, so
+// the value may hold either.
+func parseWhere(fields []dbase.Field, where []string) ([]rowFilter, error) {
+	filters := make([]rowFilter, len(where))
+	for i, expr := range where {
+		var filter rowFilter
+		name, value, found := strings.Cut(expr, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid filter %q, expected FIELD=value or FIELD!=value", expr)
+		}
+		if strings.HasSuffix(name, "!") {
+			name, filter.negate = strings.TrimSuffix(name, "!"), true
+		}
+
+		column, err := findField(fields, name)
+		if err != nil {
+			return nil, err
+		}
+		filter.column, filter.value = column, value
+		filters[i] = filter
+	}
+	return filters, nil
+}
+
+// filterRows keeps the rows matching every filter.
+func filterRows(rows iter.Seq2[dbase.Record, error], filters []rowFilter) iter.Seq2[dbase.Record, error] {
+	return func(yield func(dbase.Record, error) bool) {
+		for row, err := range rows {
+			if err != nil {
+				yield(row, err)
+				return
+			}
+
+			values := common.Properties(row.Values)
+			matches := true
+			for _, filter := range filters {
+				if (values.String(filter.column) == filter.value) == filter.negate {
+					matches = false
+					break
+				}
+			}
+			if matches && !yield(row, nil) {
+				return
+			}
+		}
+	}
+}
+
+// deletedColumn is the column added to the dumped rows by --deleted, true for the rows marked as deleted.
+const deletedColumn = "_deleted"
+
+// markDeleted sets the deletedColumn of the rows.
+func markDeleted(rows iter.Seq2[dbase.Record, error]) iter.Seq2[dbase.Record, error] {
+	return func(yield func(dbase.Record, error) bool) {
+		for row, err := range rows {
+			if err == nil {
+				row.Values[deletedColumn] = row.Deleted
+			}
+			if !yield(row, err) {
+				return
+			}
+		}
+	}
+}
+
+// writeCsv writes the columns of the rows with a header line. Values are formatted like Properties.String.
+func writeCsv(out io.Writer, columns []string, rows iter.Seq2[dbase.Record, error]) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(columns); err != nil {
+		return err
+	}
+
+	line := make([]string, len(columns))
+	for row, err := range rows {
+		if err != nil {
+			return err
+		}
+		values := common.Properties(row.Values)
+		for i, column := range columns {
+			line[i] = values.String(column)
+		}
+		if err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeJsonRows writes the rows as an array of objects, one per line, whose keys keep the order of the
+// columns. Numbers and logicals keep their type, blank values are null and dates are formatted as text.
+func writeJsonRows(out io.Writer, columns []string, rows iter.Seq2[dbase.Record, error]) error {
+	w := bufio.NewWriter(out)
+	w.WriteString("[")
+
+	first := true
+	for row, err := range rows {
+		if err != nil {
+			return err
+		}
+		if !first {
+			w.WriteString(",")
+		}
+		first = false
+
+		w.WriteString("\n{")
+		for i, column := range columns {
+			value := row.Values[column]
+			if _, ok := value.(time.Time); ok {
+				value = common.Properties(row.Values).String(column)
+			}
+			key, _ := json.Marshal(column)
+			data, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("record %d: %w", row.Number, err)
+			}
+			if i > 0 {
+				w.WriteString(",")
+			}
+			w.Write(key)
+			w.WriteString(":")
+			w.Write(data)
+		}
+		w.WriteString("}")
+	}
+
+	w.WriteString("\n]\n")
+	return w.Flush()
+}
+
+func init() {
+	DbfCmd.Flags().StringVarP(&DbfPath, "dbf", "d", "", "Path of the dbase file, or of a '.zip' archive holding a shapefile")
+	DbfCmd.MarkFlagRequired("dbf")
+	DbfCmd.Flags().StringVar(&DbfLayer, "layer", "", "Layer of the '.zip' archive to read, by name. Only needed when the archive holds several layers")
+	DbfCmd.Flags().StringVar(&CpgPath, "cpg", "", "Path of the code page file. Defaults to the '.cpg' file next to the dbase file")
+	DbfCmd.Flags().StringVarP(&DbfFormat, "format", "f", "schema", "What to print. 'schema' for the header and fields, 'csv' or 'json' to dump the rows")
+	DbfCmd.Flags().StringSliceVarP(&DbfColumns, "columns", "c", nil, "Comma separated fields to dump, in order. Defaults to every field")
+	DbfCmd.Flags().StringArrayVarP(&DbfWhere, "where", "w", nil, "Only dump rows where FIELD=value, or FIELD!=value. Values are compared as text and every filter has to match")
+	DbfCmd.Flags().BoolVar(&DbfDeleted, "deleted", false, "Whether rows marked as deleted are dumped as well, with a '_deleted' column telling them apart")
+	DbfCmd.Flags().StringVarP(&DbfOutput, "output", "o", "", "Output file path. Defaults to stdout")
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nilptrderef/gogeo/internal/dbase"
+)
+
+var dbfFields = []dbase.Field{
+	{Name: "GEOID", Type: dbase.Character, Length: 5},
+	{Name: "NAME", Type: dbase.Character, Length: 20},
+	{Name: "ALAND", Type: dbase.Numeric, Length: 14},
+	{Name: "UPDATED", Type: dbase.Date, Length: 8},
+}
+
+// dbfRows returns the records of a small county table, the second of them deleted.
+func dbfRows() []dbase.Record {
+	day := time.Date(2020, time.March, 9, 0, 0, 0, 0, time.UTC)
+	return []dbase.Record{
+		{Number: 1, Values: map[string]any{"GEOID": "08001", "NAME": "Adams", "ALAND": int64(3021798693), "UPDATED": day}},
+		{Number: 2, Deleted: true, Values: map[string]any{"GEOID": "08003", "NAME": "Alamosa", "ALAND": int64(1871464832), "UPDATED": nil}},
+		{Number: 3, Values: map[string]any{"GEOID": "08005", "NAME": "a=b", "ALAND": nil, "UPDATED": day}},
+	}
+}
+
+// records iterates over the rows, failing with err after them when it is set.
+func records(rows []dbase.Record, err error) iter.Seq2[dbase.Record, error] {
+	return func(yield func(dbase.Record, error) bool) {
+		for _, row := range rows {
+			if !yield(row, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(dbase.Record{}, err)
+		}
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  []string
+	}{
+		{nil, []string{"GEOID", "NAME", "ALAND", "UPDATED"}},
+		{[]string{"NAME"}, []string{"NAME"}},
+		{[]string{"aland", " geoid "}, []string{"ALAND", "GEOID"}},
+		{[]string{"NAME", "NAME"}, []string{"NAME", "NAME"}},
+		{[]string{"NAME", "STATEFP"}, nil},
+		{[]string{""}, nil},
+	}
+	for _, test := range tests {
+		got, err := selectColumns(dbfFields, test.names)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%q selected %q", test.names, got)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, test.want) {
+			t.Errorf("%q selected %q, %v, want %q", test.names, got, err, test.want)
+		}
+	}
+}
+
+func TestParseWhere(t *testing.T) {
+	tests := []struct {
+		where []string
+		want  []rowFilter
+	}{
+		{nil, []rowFilter{}},
+		{[]string{"NAME=Adams"}, []rowFilter{{column: "NAME", value: "Adams"}}},
+		{[]string{"name!=Adams"}, []rowFilter{{column: "NAME", value: "Adams", negate: true}}},
+		{[]string{"NAME="}, []rowFilter{{column: "NAME", value: ""}}},
+		// Only the first operator splits the filter, the value may hold more.
+		{[]string{"NAME=a=b"}, []rowFilter{{column: "NAME", value: "a=b"}}},
+		{[]string{"NAME!=a!=b"}, []rowFilter{{column: "NAME", value: "a!=b", negate: true}}},
+		{[]string{"NAME=a!=b"}, []rowFilter{{column: "NAME", value: "a!=b"}}},
+		{[]string{"NAME!=!"}, []rowFilter{{column: "NAME", value: "!", negate: true}}},
+		{[]string{"GEOID=08001", "ALAND!=0"}, []rowFilter{{column: "GEOID", value: "08001"}, {column: "ALAND", value: "0", negate: true}}},
+		{[]string{"NAME"}, nil},
+		{[]string{"STATEFP=08"}, nil},
+		{[]string{"=Adams"}, nil},
+	}
+	for _, test := range tests {
+		got, err := parseWhere(dbfFields, test.where)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("%q parsed as %+v", test.where, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q parsed as %+v, %v, want %+v", test.where, got, err, test.want)
+		}
+	}
+}
+
+func TestFilterRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []rowFilter
+		want    []int
+	}{
+		{"no filter", nil, []int{1, 2, 3}},
+		{"equal", []rowFilter{{column: "NAME", value: "Adams"}}, []int{1}},
+		{"not equal", []rowFilter{{column: "NAME", value: "Adams", negate: true}}, []int{2, 3}},
+		{"value with =", []rowFilter{{column: "NAME", value: "a=b"}}, []int{3}},
+		{"number", []rowFilter{{column: "ALAND", value: "1871464832"}}, []int{2}},
+		{"blank", []rowFilter{{column: "ALAND", value: ""}}, []int{3}},
+		{"date", []rowFilter{{column: "UPDATED", value: "2020-03-09"}}, []int{1, 3}},
+		{"every filter", []rowFilter{{column: "UPDATED", value: "2020-03-09"}, {column: "GEOID", value: "08001", negate: true}}, []int{3}},
+		{"nothing", []rowFilter{{column: "NAME", value: "adams"}}, nil},
+	}
+	for _, test := range tests {
+		var got []int
+		for row, err := range filterRows(records(dbfRows(), nil), test.filters) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, row.Number)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: kept rows %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	// Errors are passed on, after the rows read before them.
+	failure := errors.New("truncated table")
+	var got []int
+	var err error
+	for row, rowErr := range filterRows(records(dbfRows(), failure), []rowFilter{{column: "NAME", value: "Alamosa", negate: true}}) {
+		if rowErr != nil {
+			err = rowErr
+			break
+		}
+		got = append(got, row.Number)
+	}
+	if !slices.Equal(got, []int{1, 3}) || err != failure {
+		t.Errorf("kept rows %v before %v", got, err)
+	}
+}
+
+func TestWriteJsonRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		deleted bool
+		rows    []dbase.Record
+		want    string
+	}{
+		{"no rows", []string{"NAME"}, false, nil, "[\n]\n"},
+		{
+			"every column", []string{"GEOID", "NAME", "ALAND", "UPDATED"}, false, dbfRows()[:1],
+			"[\n" + `{"GEOID":"08001","NAME":"Adams","ALAND":3021798693,"UPDATED":"2020-03-09"}` + "\n]\n",
+		},
+		{
+			"column order and blanks", []string{"ALAND", "UPDATED", "NAME"}, false, dbfRows()[1:],
+			"[\n" + `{"ALAND":1871464832,"UPDATED":null,"NAME":"Alamosa"},` + "\n" + `{"ALAND":null,"UPDATED":"2020-03-09","NAME":"a=b"}` + "\n]\n",
+		},
+		{
+			"deleted", []string{"GEOID", deletedColumn}, true, dbfRows(),
+			"[\n" + `{"GEOID":"08001","_deleted":false},` + "\n" + `{"GEOID":"08003","_deleted":true},` + "\n" + `{"GEOID":"08005","_deleted":false}` + "\n]\n",
+		},
+	}
+	for _, test := range tests {
+		rows := records(test.rows, nil)
+		if test.deleted {
+			rows = markDeleted(rows)
+		}
+		var out bytes.Buffer
+		if err := writeJsonRows(&out, test.columns, rows); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if out.String() != test.want {
+			t.Errorf("%s: wrote\n%s\nwant\n%s", test.name, out.String(), test.want)
+		}
+	}
+
+	if err := writeJsonRows(&bytes.Buffer{}, []string{"NAME"}, records(dbfRows(), errors.New("truncated table"))); err == nil {
+		t.Error("wrote the rows of a truncated table")
+	}
+}
+
+func TestWriteCsvDeleted(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeCsv(&out, []string{"NAME", "ALAND", deletedColumn}, markDeleted(records(dbfRows(), nil))); err != nil {
+		t.Fatal(err)
+	}
+	want := "NAME,ALAND,_deleted\nAdams,3021798693,false\nAlamosa,1871464832,true\na=b,,false\n"
+	if out.String() != want {
+		t.Errorf("wrote\n%s\nwant\n%s", out.String(), want)
+	}
+}
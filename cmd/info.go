@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nilptrderef/gogeo/internal/crs"
+	"github.com/nilptrderef/gogeo/internal/dbase"
+	"github.com/nilptrderef/gogeo/internal/shapefile"
+
+	"github.com/spf13/cobra"
+)
+
+var InfoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Summarize a shapefile, its coordinate system and its attribute table",
+	Long: `Reads every record of a shapefile and reports its header, the number of records of each shape type,
+statistics of their parts and points, the coordinate system of the '.prj' file and the fields of the
+'.dbf' file. Records whose row of the '.dbf' file is marked as deleted are counted but left out of the
+statistics. Records that cannot be parsed are reported after the summary of the records before them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !strings.EqualFold(filepath.Ext(ShpPath), ".zip") {
+			// Unlike convert, the dbase file next to the shapefile is read without being asked for.
+			if DbfPath == "" {
+				sibling := strings.TrimSuffix(ShpPath, filepath.Ext(ShpPath)) + ".dbf"
+				if _, err := os.Stat(sibling); err == nil {
+					DbfPath = sibling
+				}
+			}
+			return info(os.Stdout, fileSource())
+		}
+
+		archive, err := shapefile.OpenArchive(ShpPath)
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+
+		layers := archive.Layers
+		if len(LayerNames) > 0 {
+			layers = nil
+			for _, name := range LayerNames {
+				layer, err := archive.Layer(name)
+				if err != nil {
+					return err
+				}
+				layers = append(layers, layer)
+			}
+		}
+
+		for i, layer := range layers {
+			if i > 0 {
+				fmt.Println()
+			}
+			if err := info(os.Stdout, archiveSource(layer)); err != nil {
+				return fmt.Errorf("%s: %w", layer.Name, err)
+			}
+		}
+		return nil
+	},
+}
+
+// stats accumulates the minimum, maximum and total of a count over the records.
+type stats struct {
+	min, max, total, records int
+}
+
+func (s *stats) add(n int) {
+	if s.records == 0 || n < s.min {
+		s.min = n
+	}
+	s.max = max(s.max, n)
+	s.total += n
+	s.records++
+}
+
+func (s stats) String() string {
+	if s.records == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%d total, %d to %d per record, %.1f on average", s.total, s.min, s.max, float64(s.total)/float64(s.records))
+}
+
+// shapeCounts returns the number of parts and points of a shape. Points and multipoints have no parts.
+func shapeCounts(shape shapefile.Shape) (parts, points int, ok bool) {
+	switch shape := shape.(type) {
+	case *shapefile.PointShape:
+		return 0, 1, false
+	case *shapefile.MultiPointShape:
+		return 0, len(shape.Points), false
+	case *shapefile.PolylineShape:
+		return len(shape.Parts), len(shape.Points), true
+	case *shapefile.Polygon:
+		return len(shape.Parts), len(shape.Points), true
+	case *shapefile.MultiPatchShape:
+		return len(shape.Parts), len(shape.Points), true
+	}
+	return 0, 0, false
+}
+
+// info prints the summary of a single layer. The records read before a parse error are summarized before
+// the error is returned.
+func info(out io.Writer, in source) error {
+	file, err := in.open(".shp")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var dbf io.Reader
+	dfile, err := in.open(".dbf")
+	if err == nil {
+		defer dfile.Close()
+		dbf = bufio.NewReader(dfile)
+	} else if !errors.Is(err, fs.ErrNotExist) || DbfPath != "" {
+		return err
+	}
+
+	stream, err := shapefile.NewStream(bufio.NewReader(file), dbf)
+	if err != nil {
+		return err
+	}
+	if stream.Dbase != nil {
+		if err := readCodePage(in, stream.Dbase); err != nil {
+			return err
+		}
+	}
+
+	var projection *crs.CRS
+	prj, err := in.open(".prj")
+	if err == nil {
+		projection, err = crs.Parse(prj)
+		prj.Close()
+		if err != nil {
+			return fmt.Errorf("projection of %s: %w", in.name, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) || PrjPath != "" {
+		return err
+	}
+
+	types := map[shapefile.ShapeType]int{}
+	var parts, points stats
+	var nulls []uint32
+	var parseErr error
+	for record, err := range stream.Records() {
+		if err != nil {
+			parseErr = err
+			break
+		}
+
+		st := record.Geometry.GetType()
+		types[st]++
+		if st == shapefile.Null {
+			nulls = append(nulls, record.Number)
+			continue
+		}
+		p, n, multipart := shapeCounts(record.Geometry)
+		if multipart {
+			parts.add(p)
+		}
+		points.add(n)
+	}
+
+	header := stream.Header
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "File:\t%s\n", in.name)
+	fmt.Fprintf(w, "File length:\t%d bytes\n", int64(header.File.FileLength)*2)
+	fmt.Fprintf(w, "Shape type:\t%s\n", header.Shape.Type)
+	fmt.Fprintf(w, "Bounding box:\tX %g to %g, Y %g to %g\n", header.Shape.Mbr.Start.X, header.Shape.Mbr.End.X, header.Shape.Mbr.Start.Y, header.Shape.Mbr.End.Y)
+	if header.Shape.Type.HasZ() {
+		fmt.Fprintf(w, "Z range:\t%g to %g\n", header.Shape.Zrange.Min, header.Shape.Zrange.Max)
+	}
+	if header.Shape.Type.HasM() {
+		fmt.Fprintf(w, "M range:\t%g to %g\n", header.Shape.Mrange.Min, header.Shape.Mrange.Max)
+	}
+	if projection != nil {
+		code := projection.Code()
+		if code == "" {
+			code = "no authority code"
+		}
+		fmt.Fprintf(w, "CRS:\t%s (%s)\n", projection, code)
+	} else {
+		fmt.Fprintf(w, "CRS:\tnone, assumed to be longitude and latitude\n")
+	}
+
+	total := stream.Deleted
+	for _, count := range types {
+		total += count
+	}
+	if stream.Dbase != nil {
+		fmt.Fprintf(w, "Records:\t%d (%d deleted)\n", total, stream.Deleted)
+	} else {
+		fmt.Fprintf(w, "Records:\t%d\n", total)
+	}
+	for _, st := range slices.Sorted(maps.Keys(types)) {
+		fmt.Fprintf(w, "  %s:\t%d\n", st, types[st])
+	}
+	if parts.records > 0 {
+		fmt.Fprintf(w, "Parts:\t%s\n", parts)
+	}
+	fmt.Fprintf(w, "Points:\t%s\n", points)
+	if len(nulls) > 0 {
+		fmt.Fprintf(w, "Null records:\t%s\n", recordList(nulls))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if stream.Dbase != nil {
+		fmt.Fprintln(out)
+		if err := writeFields(out, stream.Dbase); err != nil {
+			return err
+		}
+	}
+
+	return parseErr
+}
+
+// recordList formats record numbers, leaving out the middle of long lists.
+func recordList(numbers []uint32) string {
+	const limit = 10
+	texts := make([]string, 0, limit+1)
+	for i, number := range numbers {
+		if i == limit {
+			texts = append(texts, fmt.Sprintf("and %d more", len(numbers)-limit))
+			break
+		}
+		texts = append(texts, fmt.Sprint(number))
+	}
+	return strings.Join(texts, ", ")
+}
+
+// writeFields prints the fields of the attribute table of a shapefile.
+func writeFields(out io.Writer, db *dbase.Dbase) error {
+	codePage := "unknown"
+	if db.CodePage != nil {
+		codePage = db.CodePage.Name
+	}
+	fmt.Fprintf(out, "Attributes: %d records of %s, code page %s\n", db.Header.RecordCount, db.Header.Format(), codePage)
+	return writeFieldTable(out, db.Schema())
+}
+
+func init() {
+	InfoCmd.Flags().StringVarP(&ShpPath, "shp", "s", "", "Path of the shapefile, or of a '.zip' archive holding one or more shapefiles")
+	InfoCmd.MarkFlagRequired("shp")
+	InfoCmd.Flags().StringVarP(&DbfPath, "dbf", "d", "", "Path of the dbase file. Defaults to the '.dbf' file next to the shapefile")
+	InfoCmd.Flags().StringArrayVar(&LayerNames, "layer", nil, "Layers of the '.zip' archive to summarize, by name. Defaults to every layer")
+	InfoCmd.Flags().StringVar(&CpgPath, "cpg", "", "Path of the code page file. Defaults to the '.cpg' file next to the dbase file")
+	InfoCmd.Flags().StringVar(&PrjPath, "prj", "", "Path of the projection file. Defaults to the '.prj' file next to the shapefile")
+}
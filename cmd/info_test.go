@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nilptrderef/gogeo/internal/common"
+	"github.com/nilptrderef/gogeo/internal/crs"
+	"github.com/nilptrderef/gogeo/internal/dbase"
+	"github.com/nilptrderef/gogeo/internal/shapefile"
+)
+
+// writeTowns writes a point shapefile of four records, the third of them null, with a .prj file and a .dbf
+// file whose second row is marked as deleted. It returns the paths of the .shp and .dbf files.
+func writeTowns(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	shp, err := os.Create(filepath.Join(dir, "towns.shp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shp.Close()
+	shx, err := os.Create(filepath.Join(dir, "towns.shx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shx.Close()
+	writer, err := shapefile.NewWriter(shp, shx, shapefile.PointType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, shape := range []shapefile.Shape{
+		&shapefile.PointShape{Type: shapefile.PointType, Point: common.Point{X: -105, Y: 39.75}},
+		&shapefile.PointShape{Type: shapefile.PointType, Point: common.Point{X: -104.5, Y: 38.75}},
+		&shapefile.NullShape{},
+		&shapefile.PointShape{Type: shapefile.PointType, Point: common.Point{X: -106.75, Y: 39.5}},
+	} {
+		if err := writer.Write(shape); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "towns.prj"), []byte(crs.NAD83), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dbfPath := filepath.Join(dir, "towns.dbf")
+	dbf, err := os.Create(dbfPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbf.Close()
+	table, err := dbase.NewWriter(dbf, []dbase.FieldDescriptor{{Name: "NAME", Type: dbase.Character, Length: 12}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Denver", "Pueblo", "Nowhere", "Aspen"} {
+		if err := table.Write(map[string]any{"NAME": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbf.WriteAt([]byte("*"), int64(table.Header.HeaderLength)+int64(table.Header.RecordLength)); err != nil {
+		t.Fatal(err)
+	}
+	return shp.Name(), dbfPath
+}
+
+func TestInfo(t *testing.T) {
+	shp, dbf := writeTowns(t)
+	useFlags(t, shp, dbf, 0, "ignore")
+
+	var out bytes.Buffer
+	if err := info(&out, fileSource()); err != nil {
+		t.Fatal(err)
+	}
+	// The deleted record is counted but left out of the statistics.
+	want := `File:          towns.shp
+File length:   196 bytes
+Shape type:    Point
+Bounding box:  X -106.75 to -104.5, Y 38.75 to 39.75
+CRS:           GCS_North_American_1983: geographic, datum D_North_American_1983 (EPSG:4269)
+Records:       4 (1 deleted)
+  Null:        1
+  Point:       2
+Points:        2 total, 1 to 1 per record, 1.0 on average
+Null records:  3
+
+Attributes: 4 records of dBase III/IV, code page unknown
+NAME  TYPE         LENGTH  DECIMALS  NULLABLE  VALUES
+NAME  C Character  12      0         false     string
+`
+	if got := strings.ReplaceAll(out.String(), shp, "towns.shp"); got != want {
+		t.Errorf("printed\n%s\nwant\n%s", got, want)
+	}
+}
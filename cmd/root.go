@@ -25,4 +25,6 @@ func Execute() {
 func init() {
 	RootCmd.AddCommand(serve.ServeCmd)
 	RootCmd.AddCommand(ConvertCmd)
+	RootCmd.AddCommand(DbfCmd)
+	RootCmd.AddCommand(InfoCmd)
 }
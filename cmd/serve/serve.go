@@ -7,11 +7,15 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/nilptrderef/gogeo/frontend"
+	"github.com/nilptrderef/gogeo/internal/common"
 	"github.com/spf13/cobra"
+	"github.com/tinylib/msgp/msgp"
 )
 
 var (
@@ -72,5 +76,67 @@ func Data(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	io.Copy(w, file)
+	// Maps converted with --levels can be served at any detail, from 0 for the full geometry to 255.
+	query := r.URL.Query().Get("level")
+	if query == "" {
+		io.Copy(w, file)
+		return
+	}
+	level, err := strconv.ParseUint(query, 10, 8)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "level must be a number from 0 to 255"}`))
+		return
+	}
+
+	data, err := cache.atLevel(file, byte(level))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "failed to decode msgpk file"}`))
+		return
+	}
+	w.Write(data)
+}
+
+// levelCache holds the map last decoded for ?level= along with its encoding at every level asked for so far,
+// so that the map is only decoded again once the file changes.
+type levelCache struct {
+	mu sync.Mutex
+	// Modification time and size of the file the map was decoded from
+	modTime time.Time
+	size    int64
+	m       *common.Map
+	encoded map[byte][]byte
+}
+
+var cache levelCache
+
+// atLevel returns the map of file encoded at level, see common.Map.AtLevel.
+func (c *levelCache) atLevel(file *os.File, level byte) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.m == nil || !info.ModTime().Equal(c.modTime) || info.Size() != c.size {
+		var m common.Map
+		if err := m.DecodeMsg(msgp.NewReader(file)); err != nil {
+			return nil, err
+		}
+		c.m, c.modTime, c.size = &m, info.ModTime(), info.Size()
+		c.encoded = make(map[byte][]byte)
+	}
+
+	if data, found := c.encoded[level]; found {
+		return data, nil
+	}
+	detailed := c.m.AtLevel(level)
+	data, err := detailed.MarshalMsg(nil)
+	if err != nil {
+		return nil, err
+	}
+	c.encoded[level] = data
+	return data, nil
 }
@@ -0,0 +1,188 @@
+package common
+
+import (
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/nilptrderef/gogeo/internal/simplification"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// SimplifyBudget simplifies every county at once down to at most budget points, closing points included,
+// rather than each ring by a percentage. Every point of the map is ranked with the metric of the simplifier
+// and the least important points are removed first, wherever they are, so that large counties keep their
+// detail while tiny islands disappear. Holes that collapse are dropped, and so are polygons whose outer ring
+// collapses, but every county keeps the triangle of its most important polygon, which may exceed the budget
+// by a few points.
+func (m Map) SimplifyBudget(simplifier simplification.Simplifier, budget int) error {
+	if simplifier == nil {
+		return nil
+	}
+	if budget < 0 {
+		return errors.New("point budget has to be positive")
+	}
+
+	ranks, all, err := m.rank(simplifier)
+	if err != nil {
+		return err
+	}
+	if len(all) <= budget {
+		return nil
+	}
+
+	// Points ranked above a cutoff are kept. Lowering the cutoff never removes points, so the lowest cutoff
+	// within the budget is searched among the ranks, counting the closing points and triangles kept as well.
+	slices.Sort(all)
+	all = slices.Compact(all)
+	fits := sort.Search(len(all), func(i int) bool {
+		_, points := m.keepRanked(ranks, all[len(all)-1-i])
+		return points > budget
+	})
+	cutoff := all[len(all)-1]
+	if fits > 0 {
+		cutoff = all[len(all)-fits]
+	}
+
+	parts, _ := m.keepRanked(ranks, cutoff)
+	for i := range m.Counties {
+		m.Counties[i].Parts = parts[i]
+		m.Counties[i].Levels = nil
+	}
+	return nil
+}
+
+// rank ranks every point of the map with the simplifier, by county, polygon and ring. all holds every rank.
+func (m Map) rank(simplifier simplification.Simplifier) (ranks [][][][]float64, all []float64, err error) {
+	ranks = make([][][][]float64, len(m.Counties))
+	for i, county := range m.Counties {
+		ranks[i] = make([][][]float64, len(county.Parts))
+		for j, rings := range county.Parts {
+			ranks[i][j] = make([][]float64, len(rings))
+			for k, ring := range rings {
+				if ranks[i][j][k], err = simplifier.Rank(ring); err != nil {
+					return nil, nil, err
+				}
+				all = append(all, ranks[i][j][k]...)
+			}
+		}
+	}
+	return ranks, all, nil
+}
+
+// keepRanked returns the polygons of every county with the points ranked above cutoff, and the number of
+// points they are made of.
+func (m Map) keepRanked(ranks [][][][]float64, cutoff float64) ([][]Rings, int) {
+	parts := make([][]Rings, len(m.Counties))
+	points := 0
+	for i, county := range m.Counties {
+		best, bestRank := -1, 0.0
+		for j, rings := range county.Parts {
+			if len(rings) == 0 {
+				continue
+			}
+			if len(ranks[i][j][0]) > 0 {
+				if highest := slices.Max(ranks[i][j][0]); best < 0 || highest > bestRank {
+					best, bestRank = j, highest
+				}
+			}
+
+			shell := keepRing(rings[0], ranks[i][j][0], cutoff)
+			if shell == nil {
+				continue
+			}
+			polygon := Rings{shell}
+			for k := 1; k < len(rings); k++ {
+				if hole := keepRing(rings[k], ranks[i][j][k], cutoff); hole != nil {
+					polygon = append(polygon, hole)
+				}
+			}
+			parts[i] = append(parts[i], polygon)
+		}
+
+		if len(parts[i]) == 0 && best >= 0 {
+			parts[i] = []Rings{{keepTop(county.Parts[best][0], ranks[i][best][0], 3)}}
+		}
+		for _, polygon := range parts[i] {
+			for _, ring := range polygon {
+				points += len(ring) / 2
+			}
+		}
+	}
+	return parts, points
+}
+
+// PointBudget returns the number of points the map can keep to be encoded as msgpack in at most bytes, at 18
+// bytes per point. The rest of the map, such as the names of the counties, counts against the budget too.
+func (m Map) PointBudget(bytes int) int {
+	points := 0
+	for _, county := range m.Counties {
+		for _, rings := range county.Parts {
+			for _, ring := range rings {
+				points += len(ring) / 2
+			}
+		}
+	}
+
+	pointSize := 2 * msgp.Float64Size
+	overhead := m.Msgsize() - points*pointSize
+	return (bytes - overhead) / pointSize
+}
+
+// distinct returns the number of points of a ring without its closing point, and whether it had one.
+func distinct(ring Coordinates) (int, bool) {
+	n := len(ring) / 2
+	if n > 1 && ring[0] == ring[len(ring)-2] && ring[1] == ring[len(ring)-1] {
+		return n - 1, true
+	}
+	return n, false
+}
+
+// keepRing returns the points of a ring ranked above cutoff, closed again when the ring was closed, or nil
+// when fewer than three points remain.
+func keepRing(ring Coordinates, ranks []float64, cutoff float64) Coordinates {
+	n, closed := distinct(ring)
+	kept := make(Coordinates, 0, len(ring))
+	for j := range n {
+		if ranks[j] > cutoff {
+			kept = append(kept, ring[j*2], ring[j*2+1])
+		}
+	}
+	if len(kept) < 6 {
+		return nil
+	}
+	if closed {
+		kept = append(kept, kept[0], kept[1])
+	}
+	return kept
+}
+
+// keepTop returns the count highest ranked points of a ring, in order and closed again when the ring was
+// closed.
+func keepTop(ring Coordinates, ranks []float64, count int) Coordinates {
+	n, closed := distinct(ring)
+	indices := make([]int, n)
+	for j := range indices {
+		indices[j] = j
+	}
+	slices.SortStableFunc(indices, func(a, b int) int {
+		switch {
+		case ranks[a] > ranks[b]:
+			return -1
+		case ranks[a] < ranks[b]:
+			return 1
+		}
+		return 0
+	})
+	indices = indices[:min(count, n)]
+	slices.Sort(indices)
+
+	kept := make(Coordinates, 0, (len(indices)+1)*2)
+	for _, j := range indices {
+		kept = append(kept, ring[j*2], ring[j*2+1])
+	}
+	if closed && len(kept) > 0 {
+		kept = append(kept, kept[0], kept[1])
+	}
+	return kept
+}
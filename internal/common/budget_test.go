@@ -0,0 +1,119 @@
+package common
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nilptrderef/gogeo/internal/simplification"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// mapPoints returns the number of points of every ring of the map, closing points included.
+func mapPoints(m Map) int {
+	points := 0
+	for _, county := range m.Counties {
+		for _, ring := range county.Coordinates() {
+			points += len(ring) / 2
+		}
+	}
+	return points
+}
+
+func TestSimplifyBudget(t *testing.T) {
+	simplifiers := map[string]simplification.Simplifier{
+		"douglas":     simplification.DouglasPeuckerSimplifier{},
+		"visvalingam": simplification.VisvalingamSimplifier{},
+	}
+	for name, simplifier := range simplifiers {
+		t.Run(name, func(t *testing.T) {
+			original := junctionMap()
+			total := mapPoints(original)
+			ranks, all, err := original.rank(simplifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(all)
+			all = slices.Compact(all)
+
+			// The three counties keep a triangle each whatever the budget.
+			for budget := 12; budget <= total+10; budget += 7 {
+				m := junctionMap()
+				if err := m.SimplifyBudget(simplifier, budget); err != nil {
+					t.Fatal(err)
+				}
+				points := mapPoints(m)
+				if budget >= total {
+					if points != total {
+						t.Errorf("budget of %d points simplified the map of %d points to %d", budget, total, points)
+					}
+					continue
+				}
+				if points > budget {
+					t.Errorf("budget of %d points kept %d", budget, points)
+				}
+
+				// Keeping the points of the next lower rank as well would exceed the budget.
+				lower := -1
+				for i := len(all) - 1; i >= 0; i-- {
+					if _, kept := original.keepRanked(ranks, all[i]); kept > budget {
+						lower = i
+						break
+					}
+				}
+				if _, kept := original.keepRanked(ranks, all[lower+1]); kept != points {
+					t.Errorf("budget of %d points kept %d, the lowest cutoff within the budget keeps %d", budget, points, kept)
+				}
+
+				for i, county := range m.Counties {
+					if len(county.Parts) == 0 {
+						t.Errorf("budget of %d points dropped county %s", budget, county.Id)
+					}
+					for j, ring := range county.Coordinates() {
+						// Rings may start at another point once their first point is removed.
+						if !ordered(ring[:len(ring)-2], original.Counties[i].Coordinates()) {
+							t.Errorf("budget of %d points changed ring %d of county %s", budget, j, county.Id)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPointBudget(t *testing.T) {
+	original := junctionMap()
+	size := original.Msgsize()
+	for _, bytes := range []int{size / 4, size / 2, size} {
+		m := junctionMap()
+		if err := m.SimplifyBudget(simplification.VisvalingamSimplifier{}, m.PointBudget(bytes)); err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := m.MarshalMsg(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(encoded) > bytes {
+			t.Errorf("budget of %d bytes encoded in %d bytes", bytes, len(encoded))
+		}
+	}
+	// The map fits in its own size, and every point more takes 18 bytes.
+	if points := original.PointBudget(size + 2*msgp.Float64Size); points != mapPoints(original)+1 {
+		t.Errorf("the map of %d points and 18 bytes more fit %d points", mapPoints(original), points)
+	}
+}
+
+// ordered reports whether the points of ring appear in one of the rings, in the same order.
+func ordered(ring Coordinates, rings [][]float64) bool {
+	for _, original := range rings {
+		k := 0
+		for i := 0; i+1 < len(original) && k+1 < len(ring); i += 2 {
+			if original[i] == ring[k] && original[i+1] == ring[k+1] {
+				k += 2
+			}
+		}
+		if k == len(ring) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,17 +1,37 @@
 package common
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"math"
 	"strconv"
+	"time"
 
 	"github.com/nilptrderef/gogeo/internal/simplification"
 )
 
 type GeoJson struct {
 	Type     string           `json:"type"`
+	Crs      *GeoJsonCrs      `json:"crs,omitempty"`
 	Features []GeoJsonFeature `json:"features"`
 }
 
+// GeoJsonCrs is the named coordinate reference system member of the 2008 GeoJSON specification. RFC 7946
+// dropped it in favour of always using WGS84, but it is still the only way to label projected output.
+type GeoJsonCrs struct {
+	Type       string `json:"type"`
+	Properties struct {
+		Name string `json:"name"`
+	} `json:"properties"`
+}
+
+func NewGeoJsonCrs(name string) *GeoJsonCrs {
+	crs := &GeoJsonCrs{Type: "name"}
+	crs.Properties.Name = name
+	return crs
+}
+
 func (geojson GeoJson) ToMap() Map {
 	var m Map
 	m.Mbr.Start.X = math.MaxFloat64
@@ -20,37 +40,38 @@ func (geojson GeoJson) ToMap() Map {
 	m.Mbr.End.Y = -math.MaxFloat64
 
 	for _, feature := range geojson.Features {
-		var county County
-		county.Mbr.Start.X = math.MaxFloat64
-		county.Mbr.Start.Y = math.MaxFloat64
-		county.Mbr.End.X = -math.MaxFloat64
-		county.Mbr.End.Y = -math.MaxFloat64
-
-		county.Id = feature.Properties["GEOID"]
-		county.Name = feature.Properties["NAMELSAD"]
-		county.State = StateAbbrFips[feature.Properties["STATEFP"]]
-
-		lat, _ := strconv.ParseFloat(feature.Properties["INTPTLAT"], 32)
-		county.InternalLat = float32(lat)
-		lon, _ := strconv.ParseFloat(feature.Properties["INTPTLON"], 32)
-		county.InternalLon = float32(lon)
-
-		for _, part := range feature.Geometry.Coordinates {
-			coordinates := make([]float64, len(part)*2)
-			for i, point := range part {
-				coordinates[i*2] = point[0]
-				county.Mbr.Start.X = min(county.Mbr.Start.X, point[0])
-				county.Mbr.End.X = max(county.Mbr.End.X, point[0])
-				m.Mbr.Start.X = min(m.Mbr.Start.X, point[0])
-				m.Mbr.End.X = max(m.Mbr.End.X, point[0])
-
-				coordinates[i*2+1] = point[1]
-				county.Mbr.Start.Y = min(county.Mbr.Start.Y, point[1])
-				county.Mbr.End.Y = max(county.Mbr.End.Y, point[1])
-				m.Mbr.Start.Y = min(m.Mbr.Start.Y, point[1])
-				m.Mbr.End.Y = max(m.Mbr.End.Y, point[1])
+		county := NewCounty(feature.Properties)
+
+		var polygons [][][][]float64
+		switch geometry := feature.Geometry.(type) {
+		case GeoJsonPolygon:
+			polygons = [][][][]float64{geometry.Coordinates}
+		case GeoJsonMultiPolygon:
+			polygons = geometry.Coordinates
+		default:
+			continue
+		}
+
+		for _, polygon := range polygons {
+			rings := make(Rings, 0, len(polygon))
+			for _, part := range polygon {
+				coordinates := make([]float64, len(part)*2)
+				for i, point := range part {
+					coordinates[i*2] = point[0]
+					county.Mbr.Start.X = min(county.Mbr.Start.X, point[0])
+					county.Mbr.End.X = max(county.Mbr.End.X, point[0])
+					m.Mbr.Start.X = min(m.Mbr.Start.X, point[0])
+					m.Mbr.End.X = max(m.Mbr.End.X, point[0])
+
+					coordinates[i*2+1] = point[1]
+					county.Mbr.Start.Y = min(county.Mbr.Start.Y, point[1])
+					county.Mbr.End.Y = max(county.Mbr.End.Y, point[1])
+					m.Mbr.Start.Y = min(m.Mbr.Start.Y, point[1])
+					m.Mbr.End.Y = max(m.Mbr.End.Y, point[1])
+				}
+				rings = append(rings, coordinates)
 			}
-			county.Parts = append(county.Parts, coordinates)
+			county.Parts = append(county.Parts, rings)
 		}
 
 		m.Counties = append(m.Counties, county)
@@ -65,28 +86,302 @@ func (geojson *GeoJson) SimplifyInPlace(simplifier simplification.Simplifier, pe
 	}
 
 	for i := range geojson.Features {
-		for j := range geojson.Features[i].Geometry.Coordinates {
-			var err error
-			geojson.Features[i].Geometry.Coordinates[j], err = simplifier.SimplifyPoints(geojson.Features[i].Geometry.Coordinates[j], percentage)
+		if err := geojson.Features[i].SimplifyInPlace(simplifier, percentage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type GeoJsonFeature struct {
+	Type       string          `json:"type"`
+	Properties Properties      `json:"properties,omitempty"`
+	Geometry   GeoJsonGeometry `json:"geometry"`
+}
+
+// Properties are the attributes of a feature keyed by field name. Values keep the types they were decoded
+// with from the dbase file, such as string, int64, float64, bool or time.Time, and blank values are nil.
+type Properties map[string]any
+
+// String returns the property as text. Numbers are formatted without any padding, dates as YYYY-MM-DD, date
+// times as RFC 3339 and missing or blank properties are empty.
+func (p Properties) String(key string) string {
+	switch v := p[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.Equal(v.Truncate(24 * time.Hour)) {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Float returns the property as a number. Text is parsed as well, since some numbers such as the internal
+// points of the TIGER/Line files are stored as text. ok is false when the property is missing or not a number.
+func (p Properties) Float(key string) (float64, bool) {
+	switch v := p[key].(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func (feature *GeoJsonFeature) SimplifyInPlace(simplifier simplification.Simplifier, percentage float64) error {
+	_, err := feature.SimplifyValidated(simplifier, percentage, simplification.NoValidation)
+	return err
+}
+
+// SimplifyValidated simplifies the feature like SimplifyInPlace, then checks or repairs the rings of all of
+// its polygons together, see simplification.Validate. valid is false when rings cross themselves or each other.
+// Lines are simplified with fixed ends and are not checked. Positions keep any values beyond X and Y.
+func (feature *GeoJsonFeature) SimplifyValidated(simplifier simplification.Simplifier, percentage float64, validation simplification.Validation) (valid bool, err error) {
+	if simplifier == nil {
+		return true, nil
+	}
+
+	var polygons [][][][]float64
+	switch geometry := feature.Geometry.(type) {
+	case GeoJsonPolygon:
+		polygons = [][][][]float64{geometry.Coordinates}
+	case GeoJsonMultiPolygon:
+		polygons = geometry.Coordinates
+	case GeoJsonLineString:
+		geometry.Coordinates, err = simplifyLine(simplifier, geometry.Coordinates, percentage)
+		feature.Geometry = geometry
+		return true, err
+	case GeoJsonMultiLineString:
+		for i := range geometry.Coordinates {
+			if geometry.Coordinates[i], err = simplifyLine(simplifier, geometry.Coordinates[i], percentage); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		return true, nil
+	}
+
+	var refs []*[][]float64
+	var positions [][][]float64
+	var original, simplified [][]float64
+	for _, polygon := range polygons {
+		for i := range polygon {
+			refs = append(refs, &polygon[i])
+			positions = append(positions, polygon[i])
+			original = append(original, flatten(polygon[i]))
+			polygon[i], err = simplifier.SimplifyPoints(polygon[i], percentage)
 			if err != nil {
-				return err
+				return false, err
 			}
+			simplified = append(simplified, flatten(polygon[i]))
+		}
+	}
+	if validation == simplification.RepairIntersections {
+		var repaired [][]float64
+		repaired, valid = simplification.Repair(original, simplified)
+		for i, ref := range refs {
+			*ref = keepPositions(positions[i], repaired[i])
+		}
+	} else {
+		_, valid = simplification.Validate(original, simplified, validation)
+	}
+
+	feature.dropEmptyRings()
+	return valid, nil
+}
+
+// dropEmptyRings removes the rings of the polygons of the feature that simplifying left empty, see
+// withoutEmptyRings.
+func (feature *GeoJsonFeature) dropEmptyRings() {
+	switch geometry := feature.Geometry.(type) {
+	case GeoJsonPolygon:
+		kept := withoutEmptyRings([][][][]float64{geometry.Coordinates})
+		geometry.Coordinates = [][][]float64{}
+		if len(kept) > 0 {
+			geometry.Coordinates = kept[0]
+		}
+		feature.Geometry = geometry
+	case GeoJsonMultiPolygon:
+		geometry.Coordinates = withoutEmptyRings(geometry.Coordinates)
+		feature.Geometry = geometry
+	}
+}
+
+// Coordinates returns the X and Y values of every ring of a polygon feature or line of a line feature as flat
+// coordinates [x, y, x, y, ...]. Features of any other geometry have none.
+func (feature *GeoJsonFeature) Coordinates() [][]float64 {
+	var lines [][][]float64
+	switch geometry := feature.Geometry.(type) {
+	case GeoJsonPolygon:
+		lines = geometry.Coordinates
+	case GeoJsonMultiPolygon:
+		for _, polygon := range geometry.Coordinates {
+			lines = append(lines, polygon...)
+		}
+	case GeoJsonLineString:
+		lines = [][][]float64{geometry.Coordinates}
+	case GeoJsonMultiLineString:
+		lines = geometry.Coordinates
+	}
+
+	var coordinates [][]float64
+	for _, line := range lines {
+		coordinates = append(coordinates, flatten(line))
+	}
+	return coordinates
+}
+
+// simplifyLine simplifies the positions of a line, see simplification.Simplifier.SimplifyLine. Positions keep
+// any values beyond X and Y.
+func simplifyLine(simplifier simplification.Simplifier, positions [][]float64, percentage float64) ([][]float64, error) {
+	simplified, err := simplifier.SimplifyLine(flatten(positions), percentage)
+	if err != nil {
+		return nil, err
+	}
+	return keepPositions(positions, simplified), nil
+}
+
+// keepPositions returns the positions remaining in the coordinates, which are an ordered subset of them.
+func keepPositions(positions [][]float64, coordinates []float64) [][]float64 {
+	kept := make([][]float64, 0, len(coordinates)/2)
+	k := 0
+	for _, position := range positions {
+		if k+1 < len(coordinates) && position[0] == coordinates[k] && position[1] == coordinates[k+1] {
+			kept = append(kept, position)
+			k += 2
+		}
+	}
+	return kept
+}
+
+// flatten returns the X and Y values of the positions as flat coordinates [x, y, x, y, ...].
+func flatten(positions [][]float64) []float64 {
+	coordinates := make([]float64, 0, len(positions)*2)
+	for _, position := range positions {
+		coordinates = append(coordinates, position[0], position[1])
+	}
+	return coordinates
+}
+
+// GeoJsonEncoder writes a FeatureCollection to w one feature at a time. Close must be called to terminate
+// the collection.
+type GeoJsonEncoder struct {
+	// Written ahead of the features when set before the first call to Encode
+	Crs *GeoJsonCrs
+
+	w     io.Writer
+	count int
+}
+
+func NewGeoJsonEncoder(w io.Writer) *GeoJsonEncoder {
+	return &GeoJsonEncoder{w: w}
+}
+
+func (e *GeoJsonEncoder) Encode(feature GeoJsonFeature) error {
+	prefix := ","
+	if e.count == 0 {
+		var err error
+		if prefix, err = e.header(); err != nil {
+			return err
 		}
 	}
+
+	data, err := json.Marshal(feature)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(e.w, prefix); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(data); err != nil {
+		return err
+	}
+	e.count++
 	return nil
 }
 
-type GeoJsonFeature struct {
-	Type       string            `json:"type"`
-	Properties map[string]string `json:"properties,omitempty"`
-	Geometry   GeoJsonPolygon    `json:"geometry"`
+func (e *GeoJsonEncoder) Close() error {
+	suffix := "]}\n"
+	if e.count == 0 {
+		header, err := e.header()
+		if err != nil {
+			return err
+		}
+		suffix = header + suffix
+	}
+	_, err := io.WriteString(e.w, suffix)
+	return err
 }
 
+func (e *GeoJsonEncoder) header() (string, error) {
+	if e.Crs == nil {
+		return `{"type":"FeatureCollection","features":[`, nil
+	}
+	crs, err := json.Marshal(e.Crs)
+	if err != nil {
+		return "", err
+	}
+	return `{"type":"FeatureCollection","crs":` + string(crs) + `,"features":[`, nil
+}
+
+// GeoJsonGeometry is one of the GeoJSON geometry objects. A nil geometry is encoded as null.
+type GeoJsonGeometry interface {
+	GetType() string
+}
+
+type GeoJsonPoint struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+func (g GeoJsonPoint) GetType() string { return g.Type }
+
+type GeoJsonMultiPoint struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+func (g GeoJsonMultiPoint) GetType() string { return g.Type }
+
+type GeoJsonLineString struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+func (g GeoJsonLineString) GetType() string { return g.Type }
+
+type GeoJsonMultiLineString struct {
+	Type        string        `json:"type"`
+	Coordinates [][][]float64 `json:"coordinates"`
+}
+
+func (g GeoJsonMultiLineString) GetType() string { return g.Type }
+
 type GeoJsonPolygon struct {
 	Type        string        `json:"type"`
 	Coordinates [][][]float64 `json:"coordinates"`
 }
 
+func (g GeoJsonPolygon) GetType() string { return g.Type }
+
+type GeoJsonMultiPolygon struct {
+	Type        string          `json:"type"`
+	Coordinates [][][][]float64 `json:"coordinates"`
+}
+
+func (g GeoJsonMultiPolygon) GetType() string { return g.Type }
+
 var StateAbbrFips = map[string]string{
 	"02": "AK",
 	"28": "MS",
@@ -1,6 +1,10 @@
 package common
 
-import "github.com/nilptrderef/gogeo/internal/simplification"
+import (
+	"math"
+
+	"github.com/nilptrderef/gogeo/internal/simplification"
+)
 
 //go:generate msgp -tests=false
 
@@ -22,6 +26,8 @@ type Range struct {
 type Map struct {
 	Mbr      Rectangle `msg:"minimum_bounding_rectangle"`
 	Counties Counties  `msg:"counties"`
+	// Well-known text of the coordinate system of the map, empty when unknown
+	Crs string `msg:"crs"`
 }
 
 type Counties []County
@@ -32,25 +38,117 @@ func (m Map) SimplifyInPlace(simplifier simplification.Simplifier, percentage fl
 	}
 
 	for i := range m.Counties {
-		for j := range m.Counties[i].Parts {
-			var err error
-			m.Counties[i].Parts[j], err = simplifier.Simplify(m.Counties[i].Parts[j], percentage)
-			if err != nil {
-				return err
-			}
+		if err := m.Counties[i].SimplifyInPlace(simplifier, percentage); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
 type County struct {
-	Id          string        `msg:"id"`
-	Name        string        `msg:"name"`
-	State       string        `msg:"state"`
-	InternalLat float32       `msg:"intlat"`
-	InternalLon float32       `msg:"intlon"`
-	Mbr         Rectangle     `msg:"minimum_bounding_rectangle"`
-	Parts       []Coordinates `msg:"coordinates"`
+	Id          string    `msg:"id"`
+	Name        string    `msg:"name"`
+	State       string    `msg:"state"`
+	InternalLat float32   `msg:"intlat"`
+	InternalLon float32   `msg:"intlon"`
+	Mbr         Rectangle `msg:"minimum_bounding_rectangle"`
+	// Polygons of the county, each made of an outer ring followed by its holes
+	Parts []Rings `msg:"coordinates"`
+	// Detail level of every point of Parts, by polygon and ring, when the map was converted with levels, see
+	// Map.AddLevels
+	Levels [][][]byte `msg:"levels,omitempty"`
+}
+
+// NewCounty creates a county without any geometry from the properties of a TIGER/Line county or ZCTA.
+func NewCounty(properties Properties) County {
+	county := County{
+		Id:    properties.String("GEOID"),
+		Name:  properties.String("NAMELSAD"),
+		State: StateAbbrFips[properties.String("STATEFP")],
+	}
+	county.Mbr.Start.X = math.MaxFloat64
+	county.Mbr.Start.Y = math.MaxFloat64
+	county.Mbr.End.X = -math.MaxFloat64
+	county.Mbr.End.Y = -math.MaxFloat64
+
+	lat, _ := properties.Float("INTPTLAT")
+	county.InternalLat = float32(lat)
+	lon, _ := properties.Float("INTPTLON")
+	county.InternalLon = float32(lon)
+	return county
+}
+
+func (c *County) SimplifyInPlace(simplifier simplification.Simplifier, percentage float64) error {
+	_, err := c.SimplifyValidated(simplifier, percentage, simplification.NoValidation)
+	return err
 }
 
+// SimplifyValidated simplifies the county like SimplifyInPlace, then checks or repairs the rings of all of
+// its polygons together, see simplification.Validate. valid is false when rings cross themselves or each other.
+// The levels of the county no longer match its points and are removed.
+func (c *County) SimplifyValidated(simplifier simplification.Simplifier, percentage float64, validation simplification.Validation) (valid bool, err error) {
+	if simplifier == nil {
+		return true, nil
+	}
+
+	var original, simplified [][]float64
+	for _, rings := range c.Parts {
+		for i := range rings {
+			original = append(original, rings[i])
+			rings[i], err = simplifier.Simplify(rings[i], percentage)
+			if err != nil {
+				return false, err
+			}
+			simplified = append(simplified, rings[i])
+		}
+	}
+
+	validated, valid := simplification.Validate(original, simplified, validation)
+	k := 0
+	for _, rings := range c.Parts {
+		for i := range rings {
+			rings[i] = validated[k]
+			k++
+		}
+	}
+
+	c.Parts = withoutEmptyRings(c.Parts)
+	// Levels belong to the points before simplifying.
+	c.Levels = nil
+	return valid, nil
+}
+
+// withoutEmptyRings removes the rings below the minimum area of the simplifier, which come back empty.
+// Polygons go along with their outer ring.
+func withoutEmptyRings[P ~[]R, R ~[]E, E any](polygons []P) []P {
+	kept := polygons[:0]
+	for _, polygon := range polygons {
+		if len(polygon) == 0 || len(polygon[0]) == 0 {
+			continue
+		}
+		rings := polygon[:1]
+		for _, hole := range polygon[1:] {
+			if len(hole) > 0 {
+				rings = append(rings, hole)
+			}
+		}
+		kept = append(kept, rings)
+	}
+	return kept
+}
+
+// Coordinates returns every ring of the county, polygon after polygon.
+func (c *County) Coordinates() [][]float64 {
+	var rings [][]float64
+	for _, polygon := range c.Parts {
+		for _, ring := range polygon {
+			rings = append(rings, ring)
+		}
+	}
+	return rings
+}
+
+// Rings is a polygon as flat coordinate lists, the outer ring first and its holes after it.
+type Rings []Coordinates
+
 type Coordinates []float64
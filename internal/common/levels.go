@@ -0,0 +1,69 @@
+package common
+
+import (
+	"sort"
+
+	"github.com/nilptrderef/gogeo/internal/simplification"
+)
+
+// AddLevels keeps the full geometry of the map but stores the detail level of each of its points in Levels, so
+// that any detail can be picked later without converting again, see AtLevel. Points are ranked across the
+// whole map with the metric of the simplifier, like SimplifyBudget, and levels split them evenly: keeping the
+// points of level L or above keeps about 1 - L/256 of the points.
+func (m Map) AddLevels(simplifier simplification.Simplifier) error {
+	ranks, all, err := m.rank(simplifier)
+	if err != nil {
+		return err
+	}
+	sort.Float64s(all)
+
+	for i := range m.Counties {
+		levels := make([][][]byte, len(ranks[i]))
+		for j := range ranks[i] {
+			levels[j] = make([][]byte, len(ranks[i][j]))
+			for k, ring := range ranks[i][j] {
+				levels[j][k] = make([]byte, len(ring))
+				for p, rank := range ring {
+					// Equal ranks share a level, so that points removed together stay together.
+					lower := sort.SearchFloat64s(all, rank)
+					levels[j][k][p] = byte(min(255, lower*256/len(all)))
+				}
+			}
+		}
+		m.Counties[i].Levels = levels
+	}
+	return nil
+}
+
+// AtLevel returns a copy of the map keeping the points whose level is level or above. Rings and polygons that
+// collapse are dropped like SimplifyBudget does, but every county keeps the triangle of its most important
+// polygon. Counties without levels are kept whole, and the copy has no levels.
+func (m Map) AtLevel(level byte) Map {
+	ranks := make([][][][]float64, len(m.Counties))
+	for i, county := range m.Counties {
+		ranks[i] = make([][][]float64, len(county.Parts))
+		for j, rings := range county.Parts {
+			ranks[i][j] = make([][]float64, len(rings))
+			for k, ring := range rings {
+				rank := make([]float64, len(ring)/2)
+				for p := range rank {
+					rank[p] = 255
+					if j < len(county.Levels) && k < len(county.Levels[j]) && p < len(county.Levels[j][k]) {
+						rank[p] = float64(county.Levels[j][k][p])
+					}
+				}
+				ranks[i][j][k] = rank
+			}
+		}
+	}
+
+	parts, _ := m.keepRanked(ranks, float64(level)-1)
+	detailed := m
+	detailed.Counties = make(Counties, len(m.Counties))
+	for i, county := range m.Counties {
+		county.Parts = parts[i]
+		county.Levels = nil
+		detailed.Counties[i] = county
+	}
+	return detailed
+}
@@ -0,0 +1,91 @@
+package common
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/nilptrderef/gogeo/internal/simplification"
+)
+
+func TestAtLevel(t *testing.T) {
+	original := junctionMap()
+	m := junctionMap()
+	if err := m.AddLevels(simplification.VisvalingamSimplifier{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Levels keep the full geometry, with a level for every point.
+	for i, county := range m.Counties {
+		if !slices.EqualFunc(county.Coordinates(), original.Counties[i].Coordinates(), slices.Equal) {
+			t.Fatalf("adding levels changed county %s", county.Id)
+		}
+		for j, rings := range county.Parts {
+			for k, ring := range rings {
+				if len(county.Levels[j][k]) != len(ring)/2 {
+					t.Errorf("ring %d of polygon %d of county %s has %d points and %d levels", k, j, county.Id, len(ring)/2, len(county.Levels[j][k]))
+				}
+			}
+		}
+	}
+
+	// Level 0 is the full geometry, and every level keeps a subset of the points of the level below.
+	previous := m.AtLevel(0)
+	for i, county := range previous.Counties {
+		if !slices.EqualFunc(county.Coordinates(), original.Counties[i].Coordinates(), slices.Equal) {
+			t.Errorf("county %s at level 0 is not the original geometry", county.Id)
+		}
+	}
+	total := mapPoints(original)
+	for level := 1; level < 256; level++ {
+		current := m.AtLevel(byte(level))
+		for i, county := range current.Counties {
+			if county.Levels != nil {
+				t.Fatalf("county %s at level %d kept its levels", county.Id, level)
+			}
+			if len(county.Parts) == 0 {
+				t.Fatalf("county %s at level %d has no polygon left", county.Id, level)
+			}
+			below := countyPoints(previous.Counties[i])
+			for pt := range countyPoints(county) {
+				if !below[pt] {
+					t.Errorf("county %s at level %d has point %v missing from level %d", county.Id, level, pt, level-1)
+				}
+			}
+		}
+
+		if level == 128 {
+			// About half of the points are kept, give or take the closing points and the triangles kept.
+			if points := mapPoints(current); points < total*2/5 || points > total*3/5 {
+				t.Errorf("level 128 kept %d of %d points", points, total)
+			}
+		}
+		previous = current
+	}
+}
+
+func TestSimplifyingClearsLevels(t *testing.T) {
+	simplifier := simplification.DouglasPeuckerSimplifier{}
+	simplify := map[string]func(m Map) error{
+		"county": func(m Map) error {
+			_, err := m.Counties[0].SimplifyValidated(simplifier, 0.3, simplification.NoValidation)
+			return err
+		},
+		"map":      func(m Map) error { return m.SimplifyInPlace(simplifier, 0.3) },
+		"topology": func(m Map) error { return m.SimplifyTopology(simplifier, 0.3) },
+		"budget":   func(m Map) error { return m.SimplifyBudget(simplifier, 40) },
+	}
+	for name, fn := range simplify {
+		t.Run(name, func(t *testing.T) {
+			m := junctionMap()
+			if err := m.AddLevels(simplifier); err != nil {
+				t.Fatal(err)
+			}
+			if err := fn(m); err != nil {
+				t.Fatal(err)
+			}
+			if m.Counties[0].Levels != nil {
+				t.Error("simplified county kept the levels of its original points")
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package common
+
+import (
+	"github.com/nilptrderef/gogeo/internal/simplification"
+)
+
+// topology splits a set of rings into arcs, the runs of points between junctions, so that a border shared by
+// two rings is stored, and simplified, only once. A junction is a point whose neighbors are not the same in
+// every ring it is part of, such as the point where the borders of three counties meet. Points are matched by
+// exact coordinates, which holds for the TIGER/Line files since neighboring polygons are cut from the same
+// edges.
+type topology struct {
+	// Rings without their closing point
+	rings [][]Point
+	// Whether the ring repeated its first point at the end
+	closed []bool
+	arcs   []*arc
+	// Arcs making up each ring, in order
+	refs [][]arcRef
+}
+
+// arc is a run of points shared by one or more rings. Rings without any junction are a single closed arc,
+// which starts and ends at the same point.
+type arc struct {
+	points []Point
+	keep   []bool
+	// Whether the simplifier dropped the whole arc, a closed arc enclosing less than its minimum area
+	dropped bool
+}
+
+// arcRef places an arc in a ring: point j of the arc is point start+j of the ring, or start+len-1-j when the
+// ring walks the arc in reverse, wrapping around the end of the ring.
+type arcRef struct {
+	arc      int
+	start    int
+	reversed bool
+}
+
+func newTopology(rings [][]Point) *topology {
+	t := &topology{
+		rings:  make([][]Point, len(rings)),
+		closed: make([]bool, len(rings)),
+		refs:   make([][]arcRef, len(rings)),
+	}
+	for i, ring := range rings {
+		if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+			ring = ring[:len(ring)-1]
+			t.closed[i] = true
+		}
+		t.rings[i] = ring
+	}
+
+	junctions := t.junctions()
+	byKey := map[[2]Point]int{}
+	for i, ring := range t.rings {
+		n := len(ring)
+		if n < 3 {
+			continue
+		}
+
+		var cuts []int
+		for j, pt := range ring {
+			if junctions[pt] {
+				cuts = append(cuts, j)
+			}
+		}
+		if len(cuts) == 0 {
+			// Closed arcs start at their smallest point so that every ring walking them finds the same arc.
+			start := 0
+			for j := range ring {
+				if less(ring[j], ring[start]) {
+					start = j
+				}
+			}
+			cuts = []int{start}
+		}
+
+		for k, start := range cuts {
+			end := cuts[(k+1)%len(cuts)]
+			length := (end-start+n)%n + 1
+			if length == 1 {
+				// A single cut spans the whole ring back to itself.
+				length = n + 1
+			}
+			points := make([]Point, length)
+			for j := range points {
+				points[j] = ring[(start+j)%n]
+			}
+			t.refs[i] = append(t.refs[i], t.addArc(byKey, points, start))
+		}
+	}
+	return t
+}
+
+// junctions finds the points whose neighbors differ between the rings they are part of.
+func (t *topology) junctions() map[Point]bool {
+	neighbors := map[Point][2]Point{}
+	junctions := map[Point]bool{}
+	for _, ring := range t.rings {
+		n := len(ring)
+		for j, pt := range ring {
+			pair := [2]Point{ring[(j-1+n)%n], ring[(j+1)%n]}
+			if less(pair[1], pair[0]) {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			if seen, found := neighbors[pt]; !found {
+				neighbors[pt] = pair
+			} else if seen != pair {
+				junctions[pt] = true
+			}
+		}
+	}
+	return junctions
+}
+
+// addArc finds the arc made of points, in either direction, and adds it when it is new. The first two points
+// of an arc identify it, since the points between its ends are no junctions and so have the same neighbors in
+// every ring.
+func (t *topology) addArc(byKey map[[2]Point]int, points []Point, start int) arcRef {
+	last := len(points) - 1
+	forward := [2]Point{points[0], points[1]}
+	backward := [2]Point{points[last], points[last-1]}
+
+	ref := arcRef{start: start}
+	key := forward
+	if less(backward[0], forward[0]) || (backward[0] == forward[0] && less(backward[1], forward[1])) {
+		key = backward
+		ref.reversed = true
+	}
+
+	if index, found := byKey[key]; found {
+		ref.arc = index
+		return ref
+	}
+
+	stored := make([]Point, len(points))
+	copy(stored, points)
+	if ref.reversed {
+		for i, j := 0, last; i < j; i, j = i+1, j-1 {
+			stored[i], stored[j] = stored[j], stored[i]
+		}
+	}
+	ref.arc = len(t.arcs)
+	byKey[key] = ref.arc
+	t.arcs = append(t.arcs, &arc{points: stored})
+	return ref
+}
+
+// simplify simplifies every arc once. The ends of an arc are always kept, since that is where it meets the
+// arcs of the neighboring rings, so arcs are simplified as open lines. Rings that would collapse to fewer
+// than three points keep their arcs whole instead, unless they are a single closed arc the simplifier
+// dropped for being below its minimum area.
+func (t *topology) simplify(simplifier simplification.Simplifier, percentage float64) error {
+	for _, a := range t.arcs {
+		coordinates := make([]float64, 0, len(a.points)*2)
+		for _, pt := range a.points {
+			coordinates = append(coordinates, pt.X, pt.Y)
+		}
+		// Arcs running between junctions are lines, while the arcs of rings without any junction are rings.
+		simplify := simplifier.SimplifyLine
+		if a.points[0] == a.points[len(a.points)-1] {
+			simplify = simplifier.Simplify
+		}
+		simplified, err := simplify(coordinates, percentage)
+		if err != nil {
+			return err
+		}
+
+		// The remaining points are an ordered subset of the arc.
+		a.keep = make([]bool, len(a.points))
+		if len(simplified) == 0 && len(coordinates) > 0 {
+			a.dropped = true
+			continue
+		}
+		k := 0
+		for j, pt := range a.points {
+			if k < len(simplified) && pt.X == simplified[k] && pt.Y == simplified[k+1] {
+				a.keep[j] = true
+				k += 2
+			}
+		}
+		a.keep[0] = true
+		a.keep[len(a.keep)-1] = true
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := range t.rings {
+			if len(t.rings[i]) < 3 || t.dropped(i) || len(t.kept(i)) >= 3 {
+				continue
+			}
+			for _, ref := range t.refs[i] {
+				for j := range t.arcs[ref.arc].keep {
+					if !t.arcs[ref.arc].keep[j] {
+						t.arcs[ref.arc].keep[j] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dropped reports whether ring i was dropped as a whole, which only happens to rings made of a single closed
+// arc.
+func (t *topology) dropped(i int) bool {
+	return len(t.refs[i]) == 1 && t.arcs[t.refs[i][0].arc].dropped
+}
+
+// kept returns the positions of the points of ring i that remain after simplifying, in order and without
+// the closing point.
+func (t *topology) kept(i int) []int {
+	n := len(t.rings[i])
+	if len(t.refs[i]) == 0 {
+		positions := make([]int, n)
+		for j := range positions {
+			positions[j] = j
+		}
+		return positions
+	}
+
+	var positions []int
+	for _, ref := range t.refs[i] {
+		a := t.arcs[ref.arc]
+		length := len(a.points)
+		// The last point of an arc is the first point of the next one.
+		for j := 0; j < length-1; j++ {
+			index := j
+			if ref.reversed {
+				index = length - 1 - j
+			}
+			if a.keep[index] {
+				positions = append(positions, (ref.start+j)%n)
+			}
+		}
+	}
+	return positions
+}
+
+// indices returns the positions of the points of ring i to keep in the original ring, closing it again when
+// it was closed. Rings are cut at their junctions, so the simplified ring may start at another point.
+func (t *topology) indices(i int) []int {
+	positions := t.kept(i)
+	if t.closed[i] && len(positions) > 0 {
+		positions = append(positions, positions[0])
+	}
+	return positions
+}
+
+// less orders points by X, then by Y.
+func less(a, b Point) bool {
+	return a.X < b.X || (a.X == b.X && a.Y < b.Y)
+}
+
+// SimplifyTopology simplifies the counties like SimplifyInPlace, except that borders shared by neighboring
+// counties are simplified once and used by both, so they stay coincident without slivers or gaps. Shared
+// borders are found by exact coordinates, so counties have to be simplified before they are projected or
+// otherwise changed independently.
+func (m Map) SimplifyTopology(simplifier simplification.Simplifier, percentage float64) error {
+	if simplifier == nil {
+		return nil
+	}
+
+	var refs []*Coordinates
+	var rings [][]Point
+	for i := range m.Counties {
+		for _, polygon := range m.Counties[i].Parts {
+			for j := range polygon {
+				ring := make([]Point, len(polygon[j])/2)
+				for k := range ring {
+					ring[k] = Point{X: polygon[j][k*2], Y: polygon[j][k*2+1]}
+				}
+				refs = append(refs, &polygon[j])
+				rings = append(rings, ring)
+			}
+		}
+	}
+
+	t := newTopology(rings)
+	if err := t.simplify(simplifier, percentage); err != nil {
+		return err
+	}
+	for i, ref := range refs {
+		original := *ref
+		simplified := make(Coordinates, 0, len(original))
+		for _, index := range t.indices(i) {
+			simplified = append(simplified, original[index*2], original[index*2+1])
+		}
+		*ref = simplified
+	}
+	for i := range m.Counties {
+		m.Counties[i].Parts = withoutEmptyRings(m.Counties[i].Parts)
+		m.Counties[i].Levels = nil
+	}
+	return nil
+}
+
+// SimplifyTopology simplifies the polygons of the features like SimplifyInPlace, keeping borders shared by
+// neighboring features coincident, see Map.SimplifyTopology. Positions keep any values beyond X and Y.
+func (geojson *GeoJson) SimplifyTopology(simplifier simplification.Simplifier, percentage float64) error {
+	if simplifier == nil {
+		return nil
+	}
+
+	var refs []*[][]float64
+	var rings [][]Point
+	addPolygon := func(polygon [][][]float64) {
+		for j := range polygon {
+			ring := make([]Point, len(polygon[j]))
+			for k, position := range polygon[j] {
+				ring[k] = Point{X: position[0], Y: position[1]}
+			}
+			refs = append(refs, &polygon[j])
+			rings = append(rings, ring)
+		}
+	}
+	for _, feature := range geojson.Features {
+		switch geometry := feature.Geometry.(type) {
+		case GeoJsonPolygon:
+			addPolygon(geometry.Coordinates)
+		case GeoJsonMultiPolygon:
+			for _, polygon := range geometry.Coordinates {
+				addPolygon(polygon)
+			}
+		}
+	}
+
+	t := newTopology(rings)
+	if err := t.simplify(simplifier, percentage); err != nil {
+		return err
+	}
+	for i, ref := range refs {
+		original := *ref
+		simplified := make([][]float64, 0, len(original))
+		for _, index := range t.indices(i) {
+			simplified = append(simplified, original[index])
+		}
+		*ref = simplified
+	}
+	for i := range geojson.Features {
+		geojson.Features[i].dropEmptyRings()
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package common
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/nilptrderef/gogeo/internal/simplification"
+)
+
+// border returns the points from a towards b, without b, wiggled across the line between them.
+func border(a, b Point, points int) []Point {
+	var border []Point
+	for i := range points {
+		t := float64(i) / float64(points)
+		x, y := a.X+(b.X-a.X)*t, a.Y+(b.Y-a.Y)*t
+		wiggle := 0.1 * math.Sin(t*math.Pi) * math.Sin(x*37+y*53)
+		if a.X == b.X {
+			x += wiggle
+		} else {
+			y += wiggle
+		}
+		border = append(border, Point{X: x, Y: y})
+	}
+	return border
+}
+
+// reversed returns the points from the end of a border back to its start, with the start and without the end.
+func reversed(border []Point, end Point) []Point {
+	points := append([]Point{end}, border[1:]...)
+	slices.Reverse(points)
+	return points
+}
+
+// closed joins the parts into a ring, repeating its first point at the end.
+func closed(parts ...[]Point) Coordinates {
+	var ring Coordinates
+	for _, part := range parts {
+		for _, pt := range part {
+			ring = append(ring, pt.X, pt.Y)
+		}
+	}
+	return append(ring, ring[0], ring[1])
+}
+
+// square returns the clockwise ring of a square with wiggled sides.
+func square(x, y, size float64, points int) Coordinates {
+	return closed(
+		border(Point{X: x, Y: y}, Point{X: x, Y: y + size}, points),
+		border(Point{X: x, Y: y + size}, Point{X: x + size, Y: y + size}, points),
+		border(Point{X: x + size, Y: y + size}, Point{X: x + size, Y: y}, points),
+		border(Point{X: x + size, Y: y}, Point{X: x, Y: y}, points),
+	)
+}
+
+// junctionMap returns three counties meeting at (2, 2): A on the left, B at the top right and C at the bottom
+// right. A also has a large island and a small one.
+func junctionMap() Map {
+	const points = 20
+	junction := Point{X: 2, Y: 2}
+	top, bottom, right := Point{X: 2, Y: 4}, Point{X: 2, Y: 0}, Point{X: 4, Y: 2}
+	ab := border(top, junction, points)
+	ac := border(junction, bottom, points)
+	bc := border(junction, right, points)
+
+	// Rings run clockwise.
+	a := closed(
+		border(Point{X: 0, Y: 0}, Point{X: 0, Y: 4}, points),
+		border(Point{X: 0, Y: 4}, top, points),
+		ab, ac,
+		border(bottom, Point{X: 0, Y: 0}, points),
+	)
+	b := closed(
+		border(top, Point{X: 4, Y: 4}, points),
+		border(Point{X: 4, Y: 4}, right, points),
+		reversed(bc, right),
+		reversed(ab, junction),
+	)
+	c := closed(
+		bc,
+		border(right, Point{X: 4, Y: 0}, points),
+		border(Point{X: 4, Y: 0}, bottom, points),
+		reversed(ac, bottom),
+	)
+	return Map{Counties: Counties{
+		{Id: "A", Parts: []Rings{{a}, {square(-3, 0, 1, points)}, {square(-1, 3, 0.1, 3)}}},
+		{Id: "B", Parts: []Rings{{b}}},
+		{Id: "C", Parts: []Rings{{c}}},
+	}}
+}
+
+// countyPoints returns the set of points of every ring of a county.
+func countyPoints(county County) map[Point]bool {
+	points := map[Point]bool{}
+	for _, ring := range county.Coordinates() {
+		for i := 0; i+1 < len(ring); i += 2 {
+			points[Point{X: ring[i], Y: ring[i+1]}] = true
+		}
+	}
+	return points
+}
+
+func TestSimplifyTopology(t *testing.T) {
+	simplifiers := map[string]simplification.Simplifier{
+		"douglas":     simplification.DouglasPeuckerSimplifier{MinimumArea: 0.05},
+		"visvalingam": simplification.VisvalingamSimplifier{MinimumArea: 0.05},
+	}
+	for name, simplifier := range simplifiers {
+		t.Run(name, func(t *testing.T) {
+			original := junctionMap()
+			m := junctionMap()
+			if err := m.SimplifyTopology(simplifier, 0.2); err != nil {
+				t.Fatal(err)
+			}
+
+			// The small island is dropped, the large one is kept.
+			if len(m.Counties[0].Parts) != 2 {
+				t.Fatalf("county A has %d polygons after simplifying, want 2", len(m.Counties[0].Parts))
+			}
+			for i, county := range m.Counties {
+				for _, rings := range county.Parts {
+					for _, ring := range rings {
+						if len(ring) < 8 || ring[0] != ring[len(ring)-2] || ring[1] != ring[len(ring)-1] {
+							t.Errorf("county %s has a ring of %d coordinates that is not closed", county.Id, len(ring))
+						}
+					}
+				}
+				if before, after := len(countyPoints(original.Counties[i])), len(countyPoints(county)); after >= before {
+					t.Errorf("county %s kept %d of its %d points", county.Id, after, before)
+				}
+			}
+
+			// Points shared by two counties are kept by both or by neither.
+			for i := range m.Counties {
+				for j := i + 1; j < len(m.Counties); j++ {
+					kept := [2]map[Point]bool{countyPoints(m.Counties[i]), countyPoints(m.Counties[j])}
+					shared := 0
+					for pt := range countyPoints(original.Counties[i]) {
+						if !countyPoints(original.Counties[j])[pt] {
+							continue
+						}
+						shared++
+						if kept[0][pt] != kept[1][pt] {
+							t.Errorf("point %v shared by %s and %s is kept by only one of them", pt, m.Counties[i].Id, m.Counties[j].Id)
+						}
+					}
+					if shared == 0 {
+						t.Errorf("counties %s and %s share no point", m.Counties[i].Id, m.Counties[j].Id)
+					}
+				}
+			}
+
+			junction := Point{X: 2, Y: 2}
+			for _, county := range m.Counties {
+				if !countyPoints(county)[junction] {
+					t.Errorf("county %s lost the junction of the three counties", county.Id)
+				}
+			}
+		})
+	}
+}
+
+func TestSimplifyTopologyGeoJson(t *testing.T) {
+	var geojson GeoJson
+	for _, county := range junctionMap().Counties {
+		var polygons [][][][]float64
+		for _, rings := range county.Parts {
+			var polygon [][][]float64
+			for _, ring := range rings {
+				var positions [][]float64
+				for i := 0; i+1 < len(ring); i += 2 {
+					positions = append(positions, []float64{ring[i], ring[i+1]})
+				}
+				polygon = append(polygon, positions)
+			}
+			polygons = append(polygons, polygon)
+		}
+		geojson.Features = append(geojson.Features, GeoJsonFeature{Geometry: GeoJsonMultiPolygon{Coordinates: polygons}})
+	}
+
+	simplifier := simplification.DouglasPeuckerSimplifier{MinimumArea: 0.05}
+	if err := geojson.SimplifyTopology(simplifier, 0.2); err != nil {
+		t.Fatal(err)
+	}
+	m := junctionMap()
+	if err := m.SimplifyTopology(simplifier, 0.2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Features are simplified the same way as counties.
+	for i := range geojson.Features {
+		got := geojson.Features[i].Coordinates()
+		want := m.Counties[i].Coordinates()
+		if len(got) != len(want) {
+			t.Fatalf("feature %d has %d rings, county has %d", i, len(got), len(want))
+		}
+		for j := range got {
+			if !slices.Equal(got[j], want[j]) {
+				t.Errorf("ring %d of feature %d is %v, county ring is %v", j, i, got[j], want[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package crs
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/nilptrderef/gogeo/internal/common"
+)
+
+// CRS describes the coordinate reference system of a shapefile, as found in its .prj file.
+type CRS struct {
+	Name string
+	// Projected systems have planar coordinates in LinearUnit, geographic ones have longitude and
+	// latitude in AngularUnit.
+	Projected bool
+	Datum     string
+	Spheroid  Spheroid
+	// Longitude of the prime meridian in AngularUnit
+	PrimeMeridian float64
+	AngularUnit   Unit
+	LinearUnit    Unit
+	Projection    string
+	// Projection parameters keyed by their lower case name, e.g. "central_meridian"
+	Parameters map[string]float64
+	// Authority code such as "EPSG:4269", empty when the .prj does not include one
+	Authority string
+
+	wkt *Node
+}
+
+type Spheroid struct {
+	Name              string
+	SemiMajorAxis     float64
+	InverseFlattening float64
+}
+
+type Unit struct {
+	Name string
+	// Size of the unit in radians for angular units and in meters for linear units
+	Factor float64
+}
+
+// Parse reads the well-known text of a .prj file.
+func Parse(r io.Reader) (*CRS, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(strings.TrimSpace(string(data)))
+}
+
+func ParseString(s string) (*CRS, error) {
+	node, err := ParseWKT(s)
+	if err != nil {
+		return nil, err
+	}
+
+	crs := &CRS{Name: node.Name(), Parameters: map[string]float64{}, wkt: node}
+	crs.Authority = authority(node)
+
+	geogcs := node
+	switch node.Keyword {
+	case "GEOGCS":
+	case "PROJCS":
+		crs.Projected = true
+		if geogcs = node.Child("GEOGCS"); geogcs == nil {
+			return nil, fmt.Errorf("projected coordinate system %q has no GEOGCS", crs.Name)
+		}
+
+		crs.Projection = node.Child("PROJECTION").Name()
+		for _, child := range node.Children {
+			if child.Keyword != "PARAMETER" {
+				continue
+			}
+			value, err := child.Number(1)
+			if err != nil {
+				return nil, fmt.Errorf("parameter %q: %w", child.Name(), err)
+			}
+			crs.Parameters[strings.ToLower(child.Name())] = value
+		}
+
+		if crs.LinearUnit, err = unit(node.Child("UNIT")); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported coordinate system %s", node.Keyword)
+	}
+
+	datum := geogcs.Child("DATUM")
+	if datum == nil {
+		return nil, fmt.Errorf("geographic coordinate system %q has no DATUM", geogcs.Name())
+	}
+	crs.Datum = datum.Name()
+
+	if spheroid := datum.Child("SPHEROID"); spheroid != nil {
+		crs.Spheroid.Name = spheroid.Name()
+		if crs.Spheroid.SemiMajorAxis, err = spheroid.Number(1); err != nil {
+			return nil, fmt.Errorf("spheroid %q: %w", spheroid.Name(), err)
+		}
+		if crs.Spheroid.InverseFlattening, err = spheroid.Number(2); err != nil {
+			return nil, fmt.Errorf("spheroid %q: %w", spheroid.Name(), err)
+		}
+	}
+
+	if primem := geogcs.Child("PRIMEM"); primem != nil {
+		if crs.PrimeMeridian, err = primem.Number(1); err != nil {
+			return nil, fmt.Errorf("prime meridian %q: %w", primem.Name(), err)
+		}
+	}
+
+	if crs.AngularUnit, err = unit(geogcs.Child("UNIT")); err != nil {
+		return nil, err
+	}
+	return crs, nil
+}
+
+func unit(node *Node) (Unit, error) {
+	if node == nil {
+		return Unit{}, fmt.Errorf("coordinate system has no UNIT")
+	}
+	factor, err := node.Number(1)
+	if err != nil || factor <= 0 {
+		return Unit{}, fmt.Errorf("invalid unit %q", node.Name())
+	}
+	return Unit{Name: node.Name(), Factor: factor}, nil
+}
+
+func authority(node *Node) string {
+	if auth := node.Child("AUTHORITY"); auth != nil && len(auth.Values) >= 2 {
+		return auth.Values[0] + ":" + auth.Values[1]
+	}
+	return ""
+}
+
+// Code returns the authority code of the coordinate system. Systems without an AUTHORITY element are
+// recognized by datum when they are the plain NAD83 or WGS84 geographic systems. Unknown systems return "".
+func (c *CRS) Code() string {
+	if c.Authority != "" {
+		return c.Authority
+	}
+	if c.Projected {
+		return ""
+	}
+	switch strings.ToUpper(strings.TrimPrefix(c.Datum, "D_")) {
+	case "NORTH_AMERICAN_1983", "NORTH_AMERICAN_DATUM_1983":
+		return "EPSG:4269"
+	case "WGS_1984", "WORLD_GEODETIC_SYSTEM_1984":
+		return "EPSG:4326"
+	}
+	return ""
+}
+
+// wgs84SemiMajorAxis is the equatorial radius of WGS84, and of GRS 1980, in meters.
+const wgs84SemiMajorAxis = 6378137
+
+// MetersPerUnit returns the length of a unit of the coordinates in meters. For geographic systems it is the
+// length of a unit of longitude at the equator, which overstates the length of longitudes away from it, see
+// MetersPerUnitAt.
+func (c *CRS) MetersPerUnit() float64 {
+	if c.Projected {
+		return c.LinearUnit.Factor
+	}
+	radius := c.Spheroid.SemiMajorAxis
+	if radius == 0 {
+		radius = wgs84SemiMajorAxis
+	}
+	return c.AngularUnit.Factor * radius
+}
+
+// MetersPerUnitAt returns the length of a unit of the coordinates in meters around the Y coordinate y. For
+// geographic systems y is a latitude in the angular unit and the length is that of a unit of longitude along
+// its parallel, which shrinks with the cosine of the latitude, down to a hundredth of the length at the
+// equator near the poles, while a unit of latitude keeps the length of MetersPerUnit. A square unit covers
+// about MetersPerUnit times MetersPerUnitAt square meters. Projected systems return MetersPerUnit.
+func (c *CRS) MetersPerUnitAt(y float64) float64 {
+	if c.Projected {
+		return c.MetersPerUnit()
+	}
+	return c.MetersPerUnit() * max(math.Cos(y*c.AngularUnit.Factor), 0.01)
+}
+
+// WKT returns the well-known text of the coordinate system, suitable for a .prj file.
+func (c *CRS) WKT() string {
+	return c.wkt.String()
+}
+
+func (c *CRS) String() string {
+	kind := "geographic"
+	if c.Projected {
+		kind = fmt.Sprintf("projected (%s, %s)", c.Projection, c.LinearUnit.Name)
+	}
+	return fmt.Sprintf("%s: %s, datum %s", c.Name, kind, c.Datum)
+}
+
+// ToLonLat returns a function converting coordinates of this system into longitude and latitude in degrees,
+// the input expected by the Albers projection, or nil when the coordinates already are. Geographic systems
+// are converted from their angular unit and prime meridian. Of the projected systems only Mercator can be
+// converted back, any other projection returns an error.
+func (c *CRS) ToLonLat() (func(common.Point) common.Point, error) {
+	degrees := c.AngularUnit.Factor * 180 / math.Pi
+	if !c.Projected {
+		if math.Abs(degrees-1) < 1e-9 && c.PrimeMeridian == 0 {
+			return nil, nil
+		}
+		return func(pt common.Point) common.Point {
+			return common.Point{X: (pt.X + c.PrimeMeridian) * degrees, Y: pt.Y * degrees}
+		}, nil
+	}
+
+	projection := strings.ToLower(c.Projection)
+	if !strings.Contains(projection, "mercator") || strings.Contains(projection, "transverse") {
+		return nil, fmt.Errorf("coordinates are already projected with %s", c.Projection)
+	}
+
+	a := c.Spheroid.SemiMajorAxis
+	e := 0.0
+	// The web mercator variants project ellipsoidal coordinates as if they were on a sphere.
+	if c.Spheroid.InverseFlattening != 0 && !strings.Contains(projection, "auxiliary_sphere") && !strings.Contains(projection, "pseudo") {
+		f := 1 / c.Spheroid.InverseFlattening
+		e = math.Sqrt(2*f - f*f)
+	}
+
+	k0 := 1.0
+	if scale, ok := c.Parameters["scale_factor"]; ok && scale != 0 {
+		k0 = scale
+	}
+	if phi1, ok := c.Parameters["standard_parallel_1"]; ok {
+		sin := math.Sin(common.DegreesToRadian(phi1))
+		k0 = math.Cos(common.DegreesToRadian(phi1)) / math.Sqrt(1-e*e*sin*sin)
+	}
+	lam0 := c.Parameters["central_meridian"] + c.PrimeMeridian*degrees
+	fe := c.Parameters["false_easting"]
+	fn := c.Parameters["false_northing"]
+	meters := c.LinearUnit.Factor
+
+	return func(pt common.Point) common.Point {
+		x := (pt.X - fe) * meters
+		y := (pt.Y - fn) * meters
+
+		lon := lam0 + x/(a*k0)*180/math.Pi
+		t := math.Exp(-y / (a * k0))
+		phi := math.Pi/2 - 2*math.Atan(t)
+		for range 8 {
+			sin := e * math.Sin(phi)
+			phi = math.Pi/2 - 2*math.Atan(t*math.Pow((1-sin)/(1+sin), e/2))
+		}
+		return common.Point{X: lon, Y: phi * 180 / math.Pi}
+	}, nil
+}
+
+// NAD83 is the geographic coordinate system used by every US Census TIGER/Line shapefile.
+const NAD83 = `GEOGCS["GCS_North_American_1983",DATUM["D_North_American_1983",SPHEROID["GRS_1980",6378137,298.257222101]],PRIMEM["Greenwich",0],UNIT["Degree",0.017453292519943295]]`
+
+// AlbersUSA describes the output of shapefile.ProjectPoint for the contiguous states. The projection is
+// spherical with coordinates in kilometers. Alaska and Hawaii are scaled and moved insets which cannot be
+// described by a coordinate system.
+const AlbersUSA = `PROJCS["USA_Contiguous_Albers_Equal_Area_Conic_Sphere",GEOGCS["GCS_Sphere",DATUM["D_Sphere",SPHEROID["Sphere",6378000,0]],PRIMEM["Greenwich",0],UNIT["Degree",0.017453292519943295]],PROJECTION["Albers"],PARAMETER["False_Easting",0],PARAMETER["False_Northing",0],PARAMETER["Central_Meridian",-96],PARAMETER["Standard_Parallel_1",29.5],PARAMETER["Standard_Parallel_2",45.5],PARAMETER["Latitude_Of_Origin",23],UNIT["Kilometer",1000]]`
@@ -0,0 +1,118 @@
+package crs
+
+import (
+	"math"
+	"testing"
+
+	"github.com/nilptrderef/gogeo/internal/common"
+)
+
+const (
+	webMercator   = `PROJCS["WGS_1984_Web_Mercator_Auxiliary_Sphere",GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Mercator_Auxiliary_Sphere"],PARAMETER["False_Easting",0.0],PARAMETER["False_Northing",0.0],PARAMETER["Central_Meridian",0.0],PARAMETER["Standard_Parallel_1",0.0],PARAMETER["Auxiliary_Sphere_Type",0.0],UNIT["Meter",1.0]]`
+	worldMercator = `PROJCS["WGS_1984_World_Mercator",GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Mercator"],PARAMETER["False_Easting",500000.0],PARAMETER["False_Northing",0.0],PARAMETER["Central_Meridian",-100.0],PARAMETER["Standard_Parallel_1",0.0],UNIT["Foot_US",0.3048006096012192]]`
+	utm13         = `PROJCS["NAD_1983_UTM_Zone_13N",GEOGCS["GCS_North_American_1983",DATUM["D_North_American_1983",SPHEROID["GRS_1980",6378137.0,298.257222101]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]],PROJECTION["Transverse_Mercator"],PARAMETER["False_Easting",500000.0],PARAMETER["False_Northing",0.0],PARAMETER["Central_Meridian",-105.0],PARAMETER["Scale_Factor",0.9996],PARAMETER["Latitude_Of_Origin",0.0],UNIT["Meter",1.0]]`
+	// Paris prime meridian and coordinates in grads
+	ntfParis = `GEOGCS["NTF (Paris)",DATUM["Nouvelle_Triangulation_Francaise",SPHEROID["Clarke 1880 (IGN)",6378249.2,293.4660212936269]],PRIMEM["Paris",2.33722917],UNIT["grad",0.01570796326794897]]`
+)
+
+// mercator projects a longitude and latitude in degrees on the ellipsoid of eccentricity e, or on the sphere
+// when e is 0, into meters.
+func mercator(pt common.Point, a, e, lam0 float64) common.Point {
+	phi := common.DegreesToRadian(pt.Y)
+	sin := e * math.Sin(phi)
+	return common.Point{
+		X: a * common.DegreesToRadian(pt.X-lam0),
+		Y: a * math.Log(math.Tan(math.Pi/4+phi/2)*math.Pow((1-sin)/(1+sin), e/2)),
+	}
+}
+
+func TestToLonLat(t *testing.T) {
+	const a = 6378137.0
+	f := 1 / 298.257223563
+	e := math.Sqrt(2*f - f*f)
+	const feet = 0.3048006096012192
+
+	tests := []struct {
+		name    string
+		wkt     string
+		project func(common.Point) common.Point
+	}{
+		{"ntf paris", ntfParis, func(pt common.Point) common.Point {
+			return common.Point{X: pt.X/0.9 - 2.33722917, Y: pt.Y / 0.9}
+		}},
+		{"web mercator", webMercator, func(pt common.Point) common.Point {
+			return mercator(pt, a, 0, 0)
+		}},
+		{"world mercator", worldMercator, func(pt common.Point) common.Point {
+			projected := mercator(pt, a, e, -100)
+			return common.Point{X: projected.X/feet + 500000, Y: projected.Y / feet}
+		}},
+	}
+	for _, test := range tests {
+		c, err := ParseString(test.wkt)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		toLonLat, err := c.ToLonLat()
+		if err != nil || toLonLat == nil {
+			t.Fatalf("%s: no conversion to longitude and latitude, %v", test.name, err)
+		}
+		for _, want := range []common.Point{{X: -104.99, Y: 39.74}, {X: 2.35, Y: 48.86}, {X: -155.5, Y: -19.6}, {X: 0, Y: 0}} {
+			got := toLonLat(test.project(want))
+			if math.Abs(got.X-want.X) > 1e-7 || math.Abs(got.Y-want.Y) > 1e-7 {
+				t.Errorf("%s: %v converted back to %v", test.name, want, got)
+			}
+		}
+	}
+
+	for _, wkt := range []string{NAD83, tigerPrj} {
+		c, err := ParseString(wkt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if toLonLat, err := c.ToLonLat(); err != nil || toLonLat != nil {
+			t.Errorf("%s needs converting to longitude and latitude, %v", c.Name, err)
+		}
+	}
+
+	for _, wkt := range []string{AlbersUSA, utm13} {
+		c, err := ParseString(wkt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ToLonLat(); err == nil {
+			t.Errorf("%s converted back to longitude and latitude", c.Name)
+		}
+	}
+}
+
+func TestMetersPerUnit(t *testing.T) {
+	tests := []struct {
+		wkt string
+		y   float64
+		// Meters of a unit at the equator and at y
+		want, wantAt float64
+	}{
+		{NAD83, 0, 111319.49, 111319.49},
+		{NAD83, 60, 111319.49, 55659.75},
+		{NAD83, -60, 111319.49, 55659.75},
+		// Near the poles a unit of longitude stops shrinking.
+		{NAD83, 90, 111319.49, 1113.19},
+		{ntfParis, 50, 100189.30, 70844.54},
+		{AlbersUSA, 45, 1000, 1000},
+		{worldMercator, 4e6, 0.3048, 0.3048},
+		{utm13, 4e6, 1, 1},
+	}
+	for _, test := range tests {
+		c, err := ParseString(test.wkt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.MetersPerUnit(); math.Abs(got-test.want) > 0.01 {
+			t.Errorf("%s: a unit is %v meters, want %v", c.Name, got, test.want)
+		}
+		if got := c.MetersPerUnitAt(test.y); math.Abs(got-test.wantAt) > 0.01 {
+			t.Errorf("%s: a unit at %v is %v meters, want %v", c.Name, test.y, got, test.wantAt)
+		}
+	}
+}
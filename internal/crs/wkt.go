@@ -0,0 +1,209 @@
+package crs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Node is a single element of a well-known text string, e.g. UNIT["Degree",0.0174532925199433] is a node
+// with the keyword UNIT and the values "Degree" and "0.0174532925199433".
+type Node struct {
+	Keyword string
+	// Quoted strings and numbers, in the order they appear
+	Values []string
+	// Nested elements, in the order they appear
+	Children []*Node
+	// Whether each value was quoted and whether the node had brackets, used to write the node back out
+	quoted []bool
+	bare   bool
+}
+
+// ParseWKT parses a single well-known text element along with everything nested in it.
+func ParseWKT(s string) (*Node, error) {
+	p := &wktParser{s: s}
+	node, err := p.node()
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("unexpected %q after WKT element at offset %d", p.s[p.pos:], p.pos)
+	}
+	return node, nil
+}
+
+// Child returns the first child with the given keyword, or nil when there is none.
+func (n *Node) Child(keyword string) *Node {
+	for _, child := range n.Children {
+		if strings.EqualFold(child.Keyword, keyword) {
+			return child
+		}
+	}
+	return nil
+}
+
+// Name returns the first value of the node, which for most elements is its name.
+func (n *Node) Name() string {
+	if n == nil || len(n.Values) == 0 {
+		return ""
+	}
+	return n.Values[0]
+}
+
+// Number parses value i of the node as a float.
+func (n *Node) Number(i int) (float64, error) {
+	if n == nil || i >= len(n.Values) {
+		return 0, fmt.Errorf("missing value %d", i)
+	}
+	return strconv.ParseFloat(n.Values[i], 64)
+}
+
+func (n *Node) String() string {
+	var sb strings.Builder
+	n.write(&sb)
+	return sb.String()
+}
+
+func (n *Node) write(sb *strings.Builder) {
+	sb.WriteString(n.Keyword)
+	if n.bare {
+		return
+	}
+	sb.WriteByte('[')
+	first := true
+	for i, value := range n.Values {
+		if !first {
+			sb.WriteByte(',')
+		}
+		first = false
+		if i < len(n.quoted) && n.quoted[i] {
+			sb.WriteString(`"` + strings.ReplaceAll(value, `"`, `""`) + `"`)
+		} else {
+			sb.WriteString(value)
+		}
+	}
+	for _, child := range n.Children {
+		if !first {
+			sb.WriteByte(',')
+		}
+		first = false
+		child.write(sb)
+	}
+	sb.WriteByte(']')
+}
+
+type wktParser struct {
+	s   string
+	pos int
+}
+
+func (p *wktParser) space() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *wktParser) node() (*Node, error) {
+	p.space()
+	start := p.pos
+	for p.pos < len(p.s) && (isLetter(p.s[p.pos]) || p.s[p.pos] == '_' || (p.pos > start && isDigit(p.s[p.pos]))) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, fmt.Errorf("expected a WKT keyword at offset %d", start)
+	}
+	node := &Node{Keyword: strings.ToUpper(p.s[start:p.pos])}
+
+	p.space()
+	if p.pos >= len(p.s) || (p.s[p.pos] != '[' && p.s[p.pos] != '(') {
+		// Some elements, such as the axis directions NORTH or EAST, appear without brackets.
+		node.bare = true
+		return node, nil
+	}
+	closing := byte(']')
+	if p.s[p.pos] == '(' {
+		closing = ')'
+	}
+	p.pos++
+
+	for {
+		p.space()
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unterminated %s element", node.Keyword)
+		}
+
+		switch c := p.s[p.pos]; {
+		case c == '"':
+			value, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			node.Values = append(node.Values, value)
+			node.quoted = append(node.quoted, true)
+		case c == '-' || c == '+' || c == '.' || isDigit(c):
+			start := p.pos
+			p.pos++
+			for p.pos < len(p.s) && (isDigit(p.s[p.pos]) || strings.IndexByte(".eE+-", p.s[p.pos]) >= 0) {
+				p.pos++
+			}
+			value := p.s[start:p.pos]
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("invalid number %q at offset %d", value, start)
+			}
+			node.Values = append(node.Values, value)
+			node.quoted = append(node.quoted, false)
+		default:
+			child, err := p.node()
+			if err != nil {
+				return nil, err
+			}
+			node.Children = append(node.Children, child)
+		}
+
+		p.space()
+		if p.pos >= len(p.s) {
+			return nil, fmt.Errorf("unterminated %s element", node.Keyword)
+		}
+		switch p.s[p.pos] {
+		case ',':
+			p.pos++
+		case closing:
+			p.pos++
+			return node, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q in %s element at offset %d", p.s[p.pos], node.Keyword, p.pos)
+		}
+	}
+}
+
+// quoted reads a quoted string. Quotes inside the string are escaped by doubling them.
+func (p *wktParser) quoted() (string, error) {
+	start := p.pos
+	p.pos++
+	var sb strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		if c != '"' {
+			sb.WriteByte(c)
+			continue
+		}
+		if p.pos < len(p.s) && p.s[p.pos] == '"' {
+			sb.WriteByte('"')
+			p.pos++
+			continue
+		}
+		return sb.String(), nil
+	}
+	return "", fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
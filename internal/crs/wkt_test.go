@@ -0,0 +1,79 @@
+package crs
+
+import (
+	"reflect"
+	"testing"
+)
+
+// tigerPrj is the .prj file shipped with every TIGER/Line shapefile.
+const tigerPrj = `GEOGCS["GCS_North_American_1983",DATUM["D_North_American_1983",SPHEROID["GRS_1980",6378137,298.257222101]],PRIMEM["Greenwich",0],UNIT["Degree",0.017453292519943295]]`
+
+func TestParseWKT(t *testing.T) {
+	node, err := ParseWKT(tigerPrj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Keyword != "GEOGCS" || node.Name() != "GCS_North_American_1983" || len(node.Children) != 3 {
+		t.Fatalf("parsed %s[%q] with %d children", node.Keyword, node.Values, len(node.Children))
+	}
+	spheroid := node.Child("datum").Child("SPHEROID")
+	if want := []string{"GRS_1980", "6378137", "298.257222101"}; spheroid == nil || !reflect.DeepEqual(spheroid.Values, want) {
+		t.Fatalf("spheroid %v, want values %q", spheroid, want)
+	}
+	if factor, err := node.Child("UNIT").Number(1); err != nil || factor != 0.017453292519943295 {
+		t.Errorf("unit factor %v, %v", factor, err)
+	}
+	if _, err := node.Child("PRIMEM").Number(2); err == nil {
+		t.Error("read a missing value")
+	}
+	if node.Child("PROJECTION") != nil || node.Child("PROJECTION").Name() != "" {
+		t.Error("found a missing child")
+	}
+	if got := node.String(); got != tigerPrj {
+		t.Errorf("wrote %s", got)
+	}
+
+	tests := []struct {
+		wkt  string
+		want string
+	}{
+		// Keywords are upper cased, spaces dropped and parentheses written as brackets.
+		{`geogcs ( "WGS 84" , unit ( "degree" , 0.0174532925199433 ) )`, `GEOGCS["WGS 84",UNIT["degree",0.0174532925199433]]`},
+		// Axis directions have no brackets.
+		{`AXIS["Easting",EAST]`, `AXIS["Easting",EAST]`},
+		// Quotes are escaped by doubling them.
+		{`DATUM["The ""best"" datum",-1.5e-3]`, `DATUM["The ""best"" datum",-1.5e-3]`},
+	}
+	for _, test := range tests {
+		node, err := ParseWKT(test.wkt)
+		if err != nil {
+			t.Errorf("%s: %v", test.wkt, err)
+			continue
+		}
+		if got := node.String(); got != test.want {
+			t.Errorf("%s written as %s, want %s", test.wkt, got, test.want)
+		}
+	}
+	if node, _ := ParseWKT(`DATUM["The ""best"" datum"]`); node.Name() != `The "best" datum` {
+		t.Errorf("quoted name read as %q", node.Name())
+	}
+}
+
+func TestParseWKTErrors(t *testing.T) {
+	for _, wkt := range []string{
+		"",
+		`"GEOGCS"`,
+		`GEOGCS["NAD83"`,
+		`GEOGCS["NAD83",UNIT["Degree",0.01]`,
+		`GEOGCS["NAD83]`,
+		`GEOGCS["NAD83"]]`,
+		`GEOGCS["NAD83") `,
+		`GEOGCS["NAD83" UNIT["Degree",1]]`,
+		`UNIT["Degree",1.2.3]`,
+		tigerPrj + ` GEOGCS["NAD83"]`,
+	} {
+		if node, err := ParseWKT(wkt); err == nil {
+			t.Errorf("%s parsed as %s", wkt, node)
+		}
+	}
+}
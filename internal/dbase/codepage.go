@@ -0,0 +1,163 @@
+package dbase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// CodePage converts the text of a dbase file into UTF-8. A nil code page is used when the encoding is
+// unknown, it keeps valid UTF-8 as is and treats anything else as Windows-1252.
+type CodePage struct {
+	Name string
+	// Characters of the bytes 0x80 to 0xFF, nil for UTF-8
+	high []rune
+}
+
+var (
+	UTF8 = &CodePage{Name: "UTF-8"}
+
+	Latin1 = &CodePage{Name: "ISO-8859-1", high: func() []rune {
+		high := make([]rune, 128)
+		for i := range high {
+			high[i] = rune(0x80 + i)
+		}
+		return high
+	}()}
+
+	Windows1252 = &CodePage{Name: "Windows-1252", high: []rune(
+		"€\u0081‚ƒ„…†‡ˆ‰Š‹Œ\u008DŽ\u008F" +
+			"\u0090‘’“”•–—˜™š›œ\u009DžŸ" +
+			string(Latin1.high[0x20:]),
+	)}
+
+	CP437 = &CodePage{Name: "CP437", high: []rune(
+		"ÇüéâäàåçêëèïîìÄÅ" +
+			"ÉæÆôöòûùÿÖÜ¢£¥₧ƒ" +
+			"áíóúñÑªº¿⌐¬½¼¡«»" +
+			"░▒▓│┤╡╢╖╕╣║╗╝╜╛┐" +
+			"└┴┬├─┼╞╟╚╔╩╦╠═╬╧" +
+			"╨╤╥╙╘╒╓╫╪┘┌█▄▌▐▀" +
+			"αßΓπΣσµτΦΘΩδ∞φε∩" +
+			"≡±≥≤⌠⌡÷≈°∙·√ⁿ²■ ",
+	)}
+
+	CP850 = &CodePage{Name: "CP850", high: []rune(
+		"ÇüéâäàåçêëèïîìÄÅ" +
+			"ÉæÆôöòûùÿÖÜø£Ø×ƒ" +
+			"áíóúñÑªº¿®¬½¼¡«»" +
+			"░▒▓│┤ÁÂÀ©╣║╗╝¢¥┐" +
+			"└┴┬├─┼ãÃ╚╔╩╦╠═╬¤" +
+			"ðÐÊËÈıÍÎÏ┘┌█▄¦Ì▀" +
+			"ÓßÔÒõÕµþÞÚÛÙýÝ¯´" +
+			"­±‗¾¶§÷¸°¨·¹³²■ ",
+	)}
+)
+
+// ErrUnsupportedCodePage is returned for code page names which are not known. Callers usually fall back to
+// the language driver of the header rather than refusing the file.
+var ErrUnsupportedCodePage = errors.New("unsupported code page")
+
+// Decode converts text of the code page into UTF-8.
+func (cp *CodePage) Decode(s string) string {
+	if cp == nil {
+		if utf8.ValidString(s) {
+			return s
+		}
+		cp = Windows1252
+	}
+	if cp.high == nil {
+		return strings.ToValidUTF8(s, string(utf8.RuneError))
+	}
+
+	ascii := true
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s) + len(s)/2)
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x80 {
+			sb.WriteByte(s[i])
+		} else {
+			sb.WriteRune(cp.high[s[i]-0x80])
+		}
+	}
+	return sb.String()
+}
+
+// LookupCodePage finds a code page by the names commonly found in .cpg files, e.g. "UTF-8", "1252",
+// "ANSI 1252", "ISO 8859-1" or "OEM 850". Other names return an error wrapping ErrUnsupportedCodePage.
+func LookupCodePage(name string) (*CodePage, error) {
+	normalized := strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToUpper(strings.TrimSpace(name)))
+	for _, prefix := range []string{"WINDOWS", "ANSI", "OEM", "ISO", "CP", "IBM"} {
+		normalized = strings.TrimPrefix(normalized, prefix)
+	}
+
+	switch normalized {
+	case "UTF8":
+		return UTF8, nil
+	case "1252":
+		return Windows1252, nil
+	case "88591", "LATIN1":
+		return Latin1, nil
+	case "437":
+		return CP437, nil
+	case "850":
+		return CP850, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnsupportedCodePage, strings.TrimSpace(name))
+}
+
+// ParseCpg reads the code page named by a .cpg file.
+func ParseCpg(r io.Reader) (*CodePage, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return LookupCodePage(string(data))
+}
+
+// languageDrivers maps the language driver byte of the header to its code page. Drivers of code pages that
+// cannot be decoded are left out.
+var languageDrivers = map[uint8]*CodePage{
+	0x01: CP437,       // US MS-DOS
+	0x02: CP850,       // International MS-DOS
+	0x03: Windows1252, // Windows ANSI
+	0x09: CP437,       // Dutch
+	0x0A: CP850,       // Dutch
+	0x0B: CP437,       // Finnish
+	0x0D: CP437,       // French
+	0x0E: CP850,       // French
+	0x0F: CP437,       // German
+	0x10: CP850,       // German
+	0x11: CP437,       // Italian
+	0x12: CP850,       // Italian
+	0x14: CP850,       // Spanish
+	0x15: CP437,       // Swedish
+	0x16: CP850,       // Swedish
+	0x18: CP437,       // Spanish
+	0x19: CP437,       // English (Britain)
+	0x1A: CP850,       // English (Britain)
+	0x1B: CP437,       // English (US)
+	0x1D: CP850,       // French
+	0x25: CP850,       // Portuguese
+	0x37: CP850,       // English (US)
+	0x57: Windows1252, // ANSI, written by ESRI tools
+	0x58: Windows1252, // Western European
+	0x59: Windows1252, // Spanish
+}
+
+// LanguageDriverCodePage returns the code page of a language driver byte, or nil when it is unset or unknown.
+func LanguageDriverCodePage(driver uint8) *CodePage {
+	return languageDrivers[driver]
+}
@@ -0,0 +1,100 @@
+package dbase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCodePageDecode(t *testing.T) {
+	// "Caf\x82" and "\xe9" are the bytes on which the code pages disagree.
+	tests := []struct {
+		cp   *CodePage
+		text string
+		want string
+	}{
+		{CP437, "Caf\x82", "Café"},
+		{CP850, "Caf\x82", "Café"},
+		{Windows1252, "Caf\x82", "Caf‚"},
+		{Latin1, "Caf\x82", "Caf\u0082"},
+		{CP437, "\xe9\x9b\xff", "Θ¢\u00a0"},
+		{CP850, "\xe9\x9b\xff", "Úø\u00a0"},
+		{Windows1252, "\xe9\x9b\xff", "é›ÿ"},
+		{Latin1, "\xe9\x9b\xff", "é\u009bÿ"},
+		{Windows1252, "\x80 \x8e", "€ Ž"},
+		{CP437, "Plain ASCII", "Plain ASCII"},
+		{UTF8, "Doña Ana", "Doña Ana"},
+		{UTF8, "Do\xf1a Ana", "Do�a Ana"},
+		// Without a code page, valid UTF-8 is kept and anything else read as Windows-1252.
+		{nil, "Doña Ana", "Doña Ana"},
+		{nil, "Do\xf1a Ana", "Doña Ana"},
+	}
+	for _, test := range tests {
+		name := "nil"
+		if test.cp != nil {
+			name = test.cp.Name
+		}
+		if got := test.cp.Decode(test.text); got != test.want {
+			t.Errorf("%q decoded from %s as %q, want %q", test.text, name, got, test.want)
+		}
+	}
+
+	for _, cp := range []*CodePage{Latin1, Windows1252, CP437, CP850} {
+		if len(cp.high) != 128 {
+			t.Errorf("%s has %d characters for the 128 high bytes", cp.Name, len(cp.high))
+		}
+	}
+}
+
+func TestLookupCodePage(t *testing.T) {
+	tests := []struct {
+		name string
+		want *CodePage
+	}{
+		{"UTF-8", UTF8},
+		{"utf8", UTF8},
+		{"1252", Windows1252},
+		{"ANSI 1252", Windows1252},
+		{"Windows-1252", Windows1252},
+		{"ISO 8859-1", Latin1},
+		{"ISO-8859-1", Latin1},
+		{"OEM 850", CP850},
+		{"CP437", CP437},
+		{"IBM437", CP437},
+		{" 850\r\n", CP850},
+	}
+	for _, test := range tests {
+		if got, err := LookupCodePage(test.name); err != nil || got != test.want {
+			t.Errorf("LookupCodePage(%q) = %v, %v, want %s", test.name, got, err, test.want.Name)
+		}
+	}
+	for _, name := range []string{"", "1251", "Shift_JIS", "88592"} {
+		if cp, err := LookupCodePage(name); !errors.Is(err, ErrUnsupportedCodePage) {
+			t.Errorf("LookupCodePage(%q) = %v, %v", name, cp, err)
+		}
+	}
+
+	if cp, err := ParseCpg(strings.NewReader("UTF-8\n")); err != nil || cp != UTF8 {
+		t.Errorf("ParseCpg read %v, %v", cp, err)
+	}
+}
+
+func TestLanguageDriverCodePage(t *testing.T) {
+	tests := []struct {
+		driver uint8
+		want   *CodePage
+	}{
+		{0x00, nil},
+		{0x01, CP437},
+		{0x02, CP850},
+		{0x03, Windows1252},
+		{0x57, Windows1252},
+		// Russian MS-DOS, which has no table.
+		{0x26, nil},
+	}
+	for _, test := range tests {
+		if got := LanguageDriverCodePage(test.driver); got != test.want {
+			t.Errorf("driver %#x has code page %v, want %v", test.driver, got, test.want)
+		}
+	}
+}
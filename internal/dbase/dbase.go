@@ -2,37 +2,72 @@ package dbase
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
 type Dbase struct {
 	Header Header
 	Fields []FieldDescriptor
+	// Code page of the text fields. Parse sets it from the language driver of the header, a .cpg file
+	// should take precedence when there is one.
+	CodePage *CodePage
+	// Memo file holding the content of memo fields. It is a separate file, so it is left to the caller to
+	// open the one named by Header.MemoExt. Memo fields hold their block number when it is not set.
+	Memo *MemoFile
 }
 
+// Parse reads the header and field descriptors of a dbase file, leaving r at the first record. The layout
+// of the descriptors is picked from the version byte of the header, see Format. Descriptors are read up to
+// their terminator and anything between it and HeaderLength, such as the backlink of Visual FoxPro or the
+// field properties of dBase 7, is skipped.
 func Parse(r io.Reader) (*Dbase, error) {
 	db := &Dbase{}
 	if err := binary.Read(r, binary.LittleEndian, &db.Header); err != nil {
 		return nil, err
 	}
 
-	count := int(db.Header.HeaderLength-32-1) / 32
-	db.Fields = make([]FieldDescriptor, 0, count)
+	db.CodePage = LanguageDriverCodePage(db.Header.LanguageDriver)
 
-	for range count {
-		var fd FieldDescriptor
-		if err := binary.Read(r, binary.LittleEndian, &fd); err != nil {
+	format := db.Header.Format()
+	read := 32
+	size := 32
+	if format == DBase7 {
+		// dBase 7 names its language driver in the 36 bytes following the header.
+		if _, err := io.CopyN(io.Discard, r, 36); err != nil {
 			return nil, err
 		}
-		db.Fields = append(db.Fields, fd)
+		read += 36
+		size = 48
 	}
 
-	var terminator [1]byte
-	if _, err := r.Read(terminator[:]); err != nil {
-		// Missing terminator byte, soft failure.
+	buf := make([]byte, size)
+	for {
+		if read >= int(db.Header.HeaderLength) {
+			return nil, fmt.Errorf("field descriptors run past the header length of %d bytes", db.Header.HeaderLength)
+		}
+
+		// The terminator takes the place of the next field name, so it is checked before reading the rest.
+		if _, err := io.ReadFull(r, buf[:1]); err != nil {
+			return nil, err
+		}
+		read++
+		if buf[0] == 0x0D {
+			break
+		}
+		if _, err := io.ReadFull(r, buf[1:]); err != nil {
+			return nil, err
+		}
+		read += size - 1
+
+		db.Fields = append(db.Fields, parseDescriptor(buf, format))
 	}
 
+	if _, err := io.CopyN(io.Discard, r, int64(db.Header.HeaderLength)-int64(read)); err != nil {
+		return nil, err
+	}
 	return db, nil
 }
 
@@ -49,34 +84,173 @@ type Header struct {
 	EncryptionFlag        uint8
 	FreeRecordThread      [4]byte
 	Reserved2             [8]byte
-	MDXFlag               uint8
-	LanguageDriver        uint8
-	Reserved3             [2]byte
+	// Whether the table has a production index. Visual FoxPro uses it for its table flags, see HasMemo.
+	MDXFlag        uint8
+	LanguageDriver uint8
+	Reserved3      [2]byte
+}
+
+// Updated returns the date the table was last updated, whose year is stored as an offset from 1900.
+func (h *Header) Updated() time.Time {
+	return time.Date(1900+int(h.YY), time.Month(h.MM), int(h.DD), 0, 0, 0, 0, time.UTC)
+}
+
+// Format is the family of programs a table was written by, which decides the layout of its field
+// descriptors and the encoding of some field types.
+type Format int
+
+const (
+	// dBase III and IV, FoxBASE and FoxPro 2 share 32 byte descriptors.
+	DBase3 Format = iota
+	// dBase 7 has 48 byte descriptors with long field names.
+	DBase7
+	// Visual FoxPro has 32 byte descriptors with field flags, such as nullable fields.
+	VisualFoxPro
+)
+
+func (f Format) String() string {
+	switch f {
+	case DBase7:
+		return "dBase 7"
+	case VisualFoxPro:
+		return "Visual FoxPro"
+	default:
+		return "dBase III/IV"
+	}
+}
+
+// Format returns the format of the table based on its version byte.
+func (h *Header) Format() Format {
+	switch {
+	case h.Version == 0x30 || h.Version == 0x31 || h.Version == 0x32:
+		return VisualFoxPro
+	case h.Version&0x07 == 0x04:
+		return DBase7
+	default:
+		return DBase3
+	}
+}
+
+// HasMemo reports whether the table comes with a memo file holding the text of its memo fields.
+func (h *Header) HasMemo() bool {
+	if h.Format() == VisualFoxPro {
+		return h.MDXFlag&0x02 != 0
+	}
+	return h.Version&0x80 != 0
 }
 
+// MemoExt returns the extension of the memo file of the table, ".fpt" for the FoxPro formats and ".dbt"
+// otherwise, or "" when the table has no memo file.
+func (h *Header) MemoExt() string {
+	switch {
+	case !h.HasMemo():
+		return ""
+	case h.Format() == VisualFoxPro || h.Version == 0xF5:
+		return ".fpt"
+	default:
+		return ".dbt"
+	}
+}
+
+// Flags of Visual FoxPro fields
+const (
+	// SystemField marks hidden fields such as the null flags
+	SystemField uint8 = 0x01
+	// NullableField marks fields that may hold null values
+	NullableField uint8 = 0x02
+	// BinaryField marks character and memo fields holding binary data
+	BinaryField uint8 = 0x04
+)
+
 type FieldDescriptor struct {
+	// Up to 10 bytes, or 31 bytes for dBase 7
+	Name         string
+	Type         FieldType
+	Length       uint8
+	DecimalCount uint8
+	// Visual FoxPro field flags, such as NullableField
+	Flags          uint8
+	IndexFieldFlag uint8
+
+	// Format of the table, some field types are encoded differently by dBase 7 and Visual FoxPro.
+	format Format
+}
+
+func (fd *FieldDescriptor) GetName() string {
+	return strings.TrimSpace(fd.Name)
+}
+
+// descriptor is the 32 byte field descriptor of dBase III and IV as well as FoxPro. Visual FoxPro stores the
+// field flags in the first reserved byte.
+type descriptor struct {
 	Name           [11]byte
-	Type           uint8
+	Type           FieldType
 	Address        [4]byte
 	Length         uint8
 	DecimalCount   uint8
-	Reserved1      [2]byte
-	WorkAreaId     uint8
-	Reserved2      [2]byte
-	SetFieldsFlag  uint8
-	Reserved3      [7]byte
+	Flags          uint8
+	Reserved1      [12]byte
 	IndexFieldFlag uint8
 }
 
-func (fd *FieldDescriptor) GetName() string {
-	s := strings.TrimRight(string(fd.Name[:]), "\x00")
-	return strings.TrimSpace(s)
+// descriptor7 is the 48 byte field descriptor of dBase 7.
+type descriptor7 struct {
+	Name              [32]byte
+	Type              FieldType
+	Length            uint8
+	DecimalCount      uint8
+	Reserved1         [2]byte
+	IndexFieldFlag    uint8
+	Reserved2         [2]byte
+	NextAutoincrement uint32
+	Reserved3         [4]byte
+}
+
+func parseDescriptor(data []byte, format Format) FieldDescriptor {
+	name := func(b []byte) string {
+		if i := strings.IndexByte(string(b), 0); i >= 0 {
+			b = b[:i]
+		}
+		return string(b)
+	}
+
+	if format == DBase7 {
+		var d descriptor7
+		binary.Decode(data, binary.LittleEndian, &d)
+		return FieldDescriptor{
+			Name:           name(d.Name[:]),
+			Type:           d.Type,
+			Length:         d.Length,
+			DecimalCount:   d.DecimalCount,
+			IndexFieldFlag: d.IndexFieldFlag,
+			format:         format,
+		}
+	}
+
+	var d descriptor
+	binary.Decode(data, binary.LittleEndian, &d)
+	fd := FieldDescriptor{
+		Name:           name(d.Name[:]),
+		Type:           d.Type,
+		Length:         d.Length,
+		DecimalCount:   d.DecimalCount,
+		IndexFieldFlag: d.IndexFieldFlag,
+		format:         format,
+	}
+	if format == VisualFoxPro {
+		fd.Flags = d.Flags
+	}
+	return fd
 }
 
-func (fd *FieldDescriptor) Read(r io.Reader) (string, error) {
-	data := make([]byte, fd.Length)
-	if _, err := io.ReadFull(r, data); err != nil {
-		return "", err
+// writeDescriptor writes the 32 byte descriptor of the field, as used by dBase III tables.
+func writeDescriptor(w io.Writer, fd FieldDescriptor) error {
+	d := descriptor{
+		Type:           fd.Type,
+		Length:         fd.Length,
+		DecimalCount:   fd.DecimalCount,
+		IndexFieldFlag: fd.IndexFieldFlag,
 	}
-	return strings.TrimSpace(string(data)), nil
+	copy(d.Name[:len(d.Name)-1], fd.Name)
+	return binary.Write(w, binary.LittleEndian, d)
 }
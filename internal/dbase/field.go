@@ -0,0 +1,278 @@
+package dbase
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType is the type byte of a field descriptor.
+type FieldType uint8
+
+const (
+	Character FieldType = 'C'
+	Numeric   FieldType = 'N'
+	Float     FieldType = 'F'
+	Date      FieldType = 'D'
+	Logical   FieldType = 'L'
+	Memo      FieldType = 'M'
+	// Integer, Double, DateTime, Currency and the variable length types are the binary types of Visual
+	// FoxPro. dBase 7 stores its Integer differently, see Decode.
+	Integer   FieldType = 'I'
+	Double    FieldType = 'B'
+	DateTime  FieldType = 'T'
+	Currency  FieldType = 'Y'
+	General   FieldType = 'G'
+	Varchar   FieldType = 'V'
+	Varbinary FieldType = 'Q'
+	// NullFlags is the hidden field of Visual FoxPro holding a bit per nullable or variable length field.
+	NullFlags FieldType = '0'
+	// Autoincrement, Double7 and Timestamp are the binary types of dBase 7.
+	Autoincrement FieldType = '+'
+	Double7       FieldType = 'O'
+	Timestamp     FieldType = '@'
+)
+
+func (ft FieldType) String() string {
+	switch ft {
+	case Character:
+		return "Character"
+	case Numeric:
+		return "Numeric"
+	case Float:
+		return "Float"
+	case Date:
+		return "Date"
+	case Logical:
+		return "Logical"
+	case Memo:
+		return "Memo"
+	case Integer:
+		return "Integer"
+	case Double:
+		return "Double"
+	case DateTime:
+		return "DateTime"
+	case Currency:
+		return "Currency"
+	case General:
+		return "General"
+	case Varchar:
+		return "Varchar"
+	case Varbinary:
+		return "Varbinary"
+	case NullFlags:
+		return "NullFlags"
+	case Autoincrement:
+		return "Autoincrement"
+	case Double7:
+		return "Double"
+	case Timestamp:
+		return "Timestamp"
+	default:
+		return fmt.Sprintf("FieldType(%q)", rune(ft))
+	}
+}
+
+// Field is the schema of a single column, as described by its field descriptor.
+type Field struct {
+	Name     string
+	Type     FieldType
+	Length   int
+	Decimals int
+	// Whether the field may hold null values, only known for Visual FoxPro tables
+	Nullable bool
+}
+
+// GoType names the Go type of the values decoded from the field, blank values are always nil. Memo fields
+// hold the int64 block number of their text unless the memo file is read along with the table.
+func (f Field) GoType() string {
+	switch f.Type {
+	case Numeric:
+		if f.Decimals == 0 {
+			return "int64"
+		}
+		return "float64"
+	case Float, Double, Double7, Currency:
+		return "float64"
+	case Integer, Autoincrement:
+		return "int64"
+	case Memo:
+		return "string"
+	case General, Varbinary:
+		return "[]byte"
+	case Date, DateTime, Timestamp:
+		return "time.Time"
+	case Logical:
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+func (fd *FieldDescriptor) Field() Field {
+	return Field{
+		Name:     fd.GetName(),
+		Type:     fd.Type,
+		Length:   int(fd.Length),
+		Decimals: int(fd.DecimalCount),
+		Nullable: fd.Flags&NullableField != 0,
+	}
+}
+
+// Schema returns the fields of the table in the order they are stored. Hidden system fields, such as the
+// null flags of Visual FoxPro, are left out.
+func (db *Dbase) Schema() []Field {
+	fields := make([]Field, 0, len(db.Fields))
+	for i := range db.Fields {
+		if db.Fields[i].Flags&SystemField == 0 {
+			fields = append(fields, db.Fields[i].Field())
+		}
+	}
+	return fields
+}
+
+// Read reads the value of the field from the next bytes of a record, see Decode.
+func (fd *FieldDescriptor) Read(r io.Reader, cp *CodePage) (any, error) {
+	data := make([]byte, fd.Length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return fd.Decode(data, cp)
+}
+
+// julianUnixEpoch is the julian day number of 1970-01-01, used by the DateTime type.
+const julianUnixEpoch = 2440588
+
+// Decode converts the raw bytes of the field into a Go value based on the field type:
+//
+//   - Character and Varchar values are strings, decoded into UTF-8 with the code page and trimmed.
+//   - Numeric values without decimals are int64, with decimals they are float64 like Float values.
+//   - Date, DateTime and Timestamp values are a time.Time in UTC.
+//   - Logical values are a bool.
+//   - Integer and Autoincrement values are int64, Double and Currency values are float64.
+//   - Memo and General values are the int64 block number of their content in the memo file.
+//   - Varbinary and NullFlags values are the raw bytes.
+//
+// Blank values, and the '?' of an unset logical, are nil. Unknown field types are decoded like Character.
+func (fd *FieldDescriptor) Decode(data []byte, cp *CodePage) (any, error) {
+	text := strings.TrimSpace(strings.TrimRight(string(data), "\x00"))
+
+	switch fd.Type {
+	case Numeric, Float:
+		// Values too wide for the field are written as asterisks.
+		if text == "" || text == "." || strings.Trim(text, "*") == "" {
+			return nil, nil
+		}
+		if fd.Type == Numeric && fd.DecimalCount == 0 {
+			if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+				return value, nil
+			}
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: invalid number %q", fd.GetName(), text)
+		}
+		return value, nil
+	case Date:
+		if text == "" || strings.Trim(text, "0") == "" {
+			return nil, nil
+		}
+		value, err := time.Parse("20060102", text)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: invalid date %q", fd.GetName(), text)
+		}
+		return value, nil
+	case Logical:
+		switch text {
+		case "", "?":
+			return nil, nil
+		case "T", "t", "Y", "y":
+			return true, nil
+		case "F", "f", "N", "n":
+			return false, nil
+		}
+		return nil, fmt.Errorf("field %s: invalid logical %q", fd.GetName(), text)
+	case Integer, Autoincrement:
+		if len(data) != 4 {
+			return nil, fmt.Errorf("field %s: integer has length %d instead of 4", fd.GetName(), len(data))
+		}
+		if fd.format == DBase7 || fd.Type == Autoincrement {
+			// dBase 7 stores integers big endian with the sign bit flipped so that they sort bytewise,
+			// which leaves zero bytes free to mean blank.
+			bits := binary.BigEndian.Uint32(data)
+			if bits == 0 {
+				return nil, nil
+			}
+			return int64(int32(bits ^ 1<<31)), nil
+		}
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case Double:
+		// dBase IV uses the same type byte for binary memos, which are stored as a block number.
+		if len(data) != 8 {
+			return fd.block(data, text)
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case Double7:
+		if len(data) != 8 {
+			return nil, fmt.Errorf("field %s: double has length %d instead of 8", fd.GetName(), len(data))
+		}
+		// Positive numbers have their sign bit flipped and negative numbers every bit, for the same reason
+		// as the integers.
+		bits := binary.BigEndian.Uint64(data)
+		switch {
+		case bits == 0:
+			return nil, nil
+		case bits&(1<<63) != 0:
+			bits ^= 1 << 63
+		default:
+			bits = ^bits
+		}
+		return math.Float64frombits(bits), nil
+	case Currency:
+		if len(data) != 8 {
+			return nil, fmt.Errorf("field %s: currency has length %d instead of 8", fd.GetName(), len(data))
+		}
+		// Currency is a fixed point number with four decimals.
+		return float64(int64(binary.LittleEndian.Uint64(data))) / 10000, nil
+	case DateTime, Timestamp:
+		if len(data) != 8 {
+			return nil, fmt.Errorf("field %s: date time has length %d instead of 8", fd.GetName(), len(data))
+		}
+		day := int64(int32(binary.LittleEndian.Uint32(data[:4])))
+		if day == 0 {
+			return nil, nil
+		}
+		ms := int64(int32(binary.LittleEndian.Uint32(data[4:])))
+		return time.UnixMilli((day-julianUnixEpoch)*86400000 + ms).UTC(), nil
+	case Memo, General:
+		return fd.block(data, text)
+	case Varbinary, NullFlags:
+		return slices.Clone(data), nil
+	default:
+		return cp.Decode(text), nil
+	}
+}
+
+// block decodes the memo block number of a field, stored as text or, in Visual FoxPro, as a 4 byte integer.
+// Block 0 means the record has no memo.
+func (fd *FieldDescriptor) block(data []byte, text string) (any, error) {
+	var block int64
+	if len(data) == 4 {
+		block = int64(binary.LittleEndian.Uint32(data))
+	} else if text != "" {
+		var err error
+		if block, err = strconv.ParseInt(text, 10, 64); err != nil {
+			return nil, fmt.Errorf("field %s: invalid memo block %q", fd.GetName(), text)
+		}
+	}
+	if block == 0 {
+		return nil, nil
+	}
+	return block, nil
+}
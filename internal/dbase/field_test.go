@@ -0,0 +1,105 @@
+package dbase
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	le32 := func(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+	be32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	le64 := func(v uint64) []byte { return binary.LittleEndian.AppendUint64(nil, v) }
+	be64 := func(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+	dateTime := func(day int32, ms int32) []byte { return append(le32(uint32(day)), le32(uint32(ms))...) }
+	const minusTwo = 1<<32 - 2
+
+	tests := []struct {
+		name  string
+		field FieldDescriptor
+		cp    *CodePage
+		data  []byte
+		want  any
+	}{
+		{"character", FieldDescriptor{Type: Character}, nil, []byte(" Autauga  \x00\x00"), "Autauga"},
+		{"character code page", FieldDescriptor{Type: Character}, CP437, []byte("Caf\x82 "), "Café"},
+		{"character blank", FieldDescriptor{Type: Character}, nil, []byte("    "), ""},
+		{"varchar", FieldDescriptor{Type: Varchar}, CP850, []byte("\xe9"), "Ú"},
+		{"unknown type", FieldDescriptor{Type: 'X'}, nil, []byte("text "), "text"},
+		{"numeric", FieldDescriptor{Type: Numeric}, nil, []byte("   -123"), int64(-123)},
+		{"numeric decimals", FieldDescriptor{Type: Numeric, DecimalCount: 2}, nil, []byte("  -1.50"), -1.5},
+		{"numeric without decimals", FieldDescriptor{Type: Numeric}, nil, []byte("    1.5"), 1.5},
+		{"numeric too wide", FieldDescriptor{Type: Numeric}, nil, []byte("*****"), nil},
+		{"numeric dot", FieldDescriptor{Type: Numeric, DecimalCount: 1}, nil, []byte("   ."), nil},
+		{"numeric blank", FieldDescriptor{Type: Numeric}, nil, []byte("     "), nil},
+		{"float", FieldDescriptor{Type: Float, DecimalCount: 3}, nil, []byte("  1.250"), 1.25},
+		{"date", FieldDescriptor{Type: Date}, nil, []byte("20200309"), time.Date(2020, time.March, 9, 0, 0, 0, 0, time.UTC)},
+		{"date zeros", FieldDescriptor{Type: Date}, nil, []byte("00000000"), nil},
+		{"date blank", FieldDescriptor{Type: Date}, nil, []byte("        "), nil},
+		{"logical true", FieldDescriptor{Type: Logical}, nil, []byte("T"), true},
+		{"logical yes", FieldDescriptor{Type: Logical}, nil, []byte("y"), true},
+		{"logical false", FieldDescriptor{Type: Logical}, nil, []byte("F"), false},
+		{"logical no", FieldDescriptor{Type: Logical}, nil, []byte("n"), false},
+		{"logical unset", FieldDescriptor{Type: Logical}, nil, []byte("?"), nil},
+		{"logical blank", FieldDescriptor{Type: Logical}, nil, []byte(" "), nil},
+		{"integer", FieldDescriptor{Type: Integer}, nil, le32(minusTwo), int64(-2)},
+		{"integer zero", FieldDescriptor{Type: Integer}, nil, le32(0), int64(0)},
+		{"integer dbase 7", FieldDescriptor{Type: Integer, format: DBase7}, nil, be32(1<<31 + 5), int64(5)},
+		{"integer dbase 7 negative", FieldDescriptor{Type: Integer, format: DBase7}, nil, be32(1<<31 - 2), int64(-2)},
+		{"integer dbase 7 blank", FieldDescriptor{Type: Integer, format: DBase7}, nil, be32(0), nil},
+		{"autoincrement", FieldDescriptor{Type: Autoincrement}, nil, be32(1<<31 + 1), int64(1)},
+		{"double", FieldDescriptor{Type: Double}, nil, le64(math.Float64bits(-2.5)), -2.5},
+		{"double dbase 4 memo", FieldDescriptor{Type: Double}, nil, []byte("        12"), int64(12)},
+		{"double 7", FieldDescriptor{Type: Double7}, nil, be64(math.Float64bits(2.5) ^ 1<<63), 2.5},
+		{"double 7 negative", FieldDescriptor{Type: Double7}, nil, be64(^math.Float64bits(-2.5)), -2.5},
+		{"double 7 blank", FieldDescriptor{Type: Double7}, nil, be64(0), nil},
+		{"currency", FieldDescriptor{Type: Currency}, nil, le64(123456), 12.3456},
+		{"currency negative", FieldDescriptor{Type: Currency}, nil, le64(1<<64 - 5000), -0.5},
+		{"date time", FieldDescriptor{Type: DateTime}, nil, dateTime(julianUnixEpoch+1, 3600000), time.Date(1970, time.January, 2, 1, 0, 0, 0, time.UTC)},
+		{"date time blank", FieldDescriptor{Type: DateTime}, nil, dateTime(0, 0), nil},
+		{"timestamp", FieldDescriptor{Type: Timestamp}, nil, dateTime(2458918, 43200500), time.Date(2020, time.March, 9, 12, 0, 0, int(500*time.Millisecond), time.UTC)},
+		{"memo", FieldDescriptor{Type: Memo}, nil, []byte("        42"), int64(42)},
+		{"memo blank", FieldDescriptor{Type: Memo}, nil, []byte("          "), nil},
+		{"memo visual foxpro", FieldDescriptor{Type: Memo}, nil, le32(7), int64(7)},
+		{"memo visual foxpro blank", FieldDescriptor{Type: General}, nil, le32(0), nil},
+		{"varbinary", FieldDescriptor{Type: Varbinary}, nil, []byte{0, 1, 2, ' '}, []byte{0, 1, 2, ' '}},
+		{"null flags", FieldDescriptor{Type: NullFlags}, nil, []byte{0x05}, []byte{0x05}},
+	}
+	for _, test := range tests {
+		test.field.Name = "FIELD"
+		test.field.Length = uint8(len(test.data))
+		got, err := test.field.Decode(test.data, test.cp)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: decoded %q as %#v, want %#v", test.name, test.data, got, test.want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		field FieldDescriptor
+		data  []byte
+	}{
+		{"numeric", FieldDescriptor{Type: Numeric}, []byte("12a")},
+		{"date", FieldDescriptor{Type: Date}, []byte("20201350")},
+		{"logical", FieldDescriptor{Type: Logical}, []byte("X")},
+		{"integer length", FieldDescriptor{Type: Integer}, []byte{1, 2, 3}},
+		{"double 7 length", FieldDescriptor{Type: Double7}, []byte{1, 2, 3, 4}},
+		{"currency length", FieldDescriptor{Type: Currency}, []byte{1, 2, 3, 4}},
+		{"date time length", FieldDescriptor{Type: DateTime}, []byte{1, 2, 3, 4}},
+		{"memo", FieldDescriptor{Type: Memo}, []byte("block  ")},
+	}
+	for _, test := range tests {
+		test.field.Name = "FIELD"
+		if value, err := test.field.Decode(test.data, nil); err == nil {
+			t.Errorf("%s: decoded %q as %#v", test.name, test.data, value)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MemoFile reads the text of memo fields, which is stored outside of the table in a .dbt file for dBase or a
+// .fpt file for FoxPro. Memo fields only hold the number of the block their text starts at.
+type MemoFile struct {
+	r         io.ReaderAt
+	fox       bool
+	blockSize int64
+}
+
+// NewMemoFile reads the header of the memo file of the table described by header. The layout of the blocks
+// is picked from the version of the table, see Header.MemoExt.
+func NewMemoFile(r io.ReaderAt, header *Header) (*MemoFile, error) {
+	m := &MemoFile{r: r, fox: header.MemoExt() == ".fpt", blockSize: 512}
+
+	buf := make([]byte, 22)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("memo header: %w", err)
+	}
+
+	// dBase III always uses blocks of 512 bytes, dBase IV and later store the block size at byte 20 and
+	// FoxPro, big endian, at byte 6.
+	switch {
+	case m.fox:
+		m.blockSize = int64(binary.BigEndian.Uint16(buf[6:8]))
+	case header.Version != 0x83:
+		if size := binary.LittleEndian.Uint16(buf[20:22]); size != 0 {
+			m.blockSize = int64(size)
+		}
+	}
+	if m.blockSize == 0 {
+		return nil, fmt.Errorf("memo file has a block size of 0")
+	}
+	return m, nil
+}
+
+// Read returns the content of the memo starting at block. The content is returned as stored, text still has
+// to be decoded with the code page of the table.
+func (m *MemoFile) Read(block int64) ([]byte, error) {
+	offset := block * m.blockSize
+	header := make([]byte, 8)
+	n, err := m.r.ReadAt(header, offset)
+	if n < len(header) && (m.fox || err != io.EOF) {
+		return nil, fmt.Errorf("memo block %d: %w", block, err)
+	}
+
+	var length int64
+	switch {
+	case n < len(header):
+		// Short dBase III memos at the end of the file.
+		return m.readTerminated(block, offset)
+	case m.fox:
+		// FoxPro blocks start with the big endian type and length of the memo.
+		length = int64(binary.BigEndian.Uint32(header[4:8]))
+		offset += 8
+	case bytes.Equal(header[:4], []byte{0xFF, 0xFF, 0x08, 0x00}):
+		// dBase IV blocks start with a marker and the length of the memo, which includes these 8 bytes.
+		length = int64(binary.LittleEndian.Uint32(header[4:8])) - 8
+		offset += 8
+	default:
+		// dBase III memos have no length and end at the first end of file marker instead.
+		return m.readTerminated(block, offset)
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("memo block %d has invalid length %d", block, length)
+	}
+
+	// The content is read through a limited reader so that a corrupt length fails at the end of the file
+	// rather than allocating the whole length up front.
+	data, err := io.ReadAll(io.NewSectionReader(m.r, offset, length))
+	if err != nil {
+		return nil, fmt.Errorf("memo block %d: %w", block, err)
+	}
+	if int64(len(data)) != length {
+		return nil, fmt.Errorf("memo block %d: %w", block, io.ErrUnexpectedEOF)
+	}
+	return data, nil
+}
+
+func (m *MemoFile) readTerminated(block, offset int64) ([]byte, error) {
+	var data []byte
+	buf := make([]byte, m.blockSize)
+	for {
+		n, err := m.r.ReadAt(buf, offset)
+		if i := bytes.IndexByte(buf[:n], 0x1A); i >= 0 {
+			return append(data, buf[:i]...), nil
+		}
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("memo block %d: %w", block, err)
+		}
+		offset += int64(n)
+	}
+}
+
+// MemoWriter writes the .dbt memo file of a dBase III table. Every memo starts on a block of 512 bytes and
+// ends with two end of file markers. The header holds the next free block, so it is filled in by Close.
+type MemoWriter struct {
+	w io.WriteSeeker
+	// Next free block
+	next uint32
+}
+
+// NewMemoWriter reserves the header block of a memo file.
+func NewMemoWriter(w io.WriteSeeker) (*MemoWriter, error) {
+	if _, err := w.Write(make([]byte, 512)); err != nil {
+		return nil, err
+	}
+	return &MemoWriter{w: w, next: 1}, nil
+}
+
+// Write appends a memo and returns the block it starts at, which is stored in the memo field.
+func (m *MemoWriter) Write(data []byte) (int64, error) {
+	if bytes.IndexByte(data, 0x1A) >= 0 {
+		return 0, fmt.Errorf("memo holds an end of file marker, which dBase III memos cannot store")
+	}
+
+	block := m.next
+	length := len(data) + 2
+	padded := make([]byte, (length+511)/512*512)
+	copy(padded, data)
+	padded[len(data)], padded[len(data)+1] = 0x1A, 0x1A
+	if _, err := m.w.Write(padded); err != nil {
+		return 0, err
+	}
+	m.next += uint32(len(padded) / 512)
+	return int64(block), nil
+}
+
+// Close writes the header. It does not close the underlying writer.
+func (m *MemoWriter) Close() error {
+	if _, err := m.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(m.w, binary.LittleEndian, m.next); err != nil {
+		return err
+	}
+	_, err := m.w.Seek(0, io.SeekEnd)
+	return err
+}
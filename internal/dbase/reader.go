@@ -0,0 +1,177 @@
+package dbase
+
+import (
+	"fmt"
+	"io"
+	"iter"
+)
+
+// Reader reads the records of a dbase table one at a time, such as the attributes of a shapefile or a plain
+// table like the Census relationship files.
+type Reader struct {
+	*Dbase
+	// Records returns deleted records as well when set, they are skipped otherwise.
+	IncludeDeleted bool
+
+	r       io.Reader
+	next    int
+	buf     []byte
+	columns []column
+	// Position of the null flags of Visual FoxPro within a record, or -1
+	nullFlags int
+}
+
+// column locates a field within a record along with its bits in the null flags of Visual FoxPro, which are
+// -1 for fields without them.
+type column struct {
+	offset    int
+	nullBit   int
+	lengthBit int
+}
+
+// Record is a single row of the table.
+type Record struct {
+	// One based position of the record in the table, deleted records included
+	Number int
+	// Deleted records are marked with an asterisk but kept in the file until it is packed.
+	Deleted bool
+	// Values keyed by field name, see FieldDescriptor.Decode. Hidden system fields are left out.
+	Values map[string]any
+}
+
+// NewReader reads the header of the table, leaving r at the first record.
+func NewReader(r io.Reader) (*Reader, error) {
+	db, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{Dbase: db, r: r, buf: make([]byte, db.Header.RecordLength), nullFlags: -1}
+
+	// Visual FoxPro gives every variable length field a bit in the null flags, set when the value is shorter
+	// than the field, followed by a bit for every nullable field.
+	length, bits := 1, 0
+	for _, field := range db.Fields {
+		col := column{offset: length, nullBit: -1, lengthBit: -1}
+		if db.Header.Format() == VisualFoxPro {
+			if field.Type == Varchar || field.Type == Varbinary {
+				col.lengthBit = bits
+				bits++
+			}
+			if field.Flags&NullableField != 0 {
+				col.nullBit = bits
+				bits++
+			}
+			if field.Type == NullFlags {
+				reader.nullFlags = length
+			}
+		}
+		reader.columns = append(reader.columns, col)
+		length += int(field.Length)
+	}
+	if length > int(db.Header.RecordLength) {
+		return nil, fmt.Errorf("fields need %d bytes but records are %d bytes long", length, db.Header.RecordLength)
+	}
+
+	return reader, nil
+}
+
+// Read reads the next record, whether it is deleted or not. It returns io.EOF once every record of the
+// header has been read. Memo fields are replaced by their content when the memo file is set, as a string
+// decoded with the code page or as bytes for binary memos.
+func (r *Reader) Read() (Record, error) {
+	if r.next >= int(r.Header.RecordCount) {
+		return Record{}, io.EOF
+	}
+	r.next++
+
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, fmt.Errorf("record %d: %w", r.next, err)
+	}
+
+	record := Record{
+		Number:  r.next,
+		Deleted: r.buf[0] == '*',
+		Values:  make(map[string]any, len(r.Fields)),
+	}
+	for i, field := range r.Fields {
+		if field.Flags&SystemField != 0 {
+			continue
+		}
+		if r.isSet(r.columns[i].nullBit) {
+			record.Values[field.GetName()] = nil
+			continue
+		}
+
+		data := r.buf[r.columns[i].offset : r.columns[i].offset+int(field.Length)]
+		if r.isSet(r.columns[i].lengthBit) && len(data) > 0 {
+			// Shorter values store their length in the last byte of the field.
+			data = data[:min(int(data[len(data)-1]), len(data)-1)]
+		}
+		value, err := field.Decode(data, r.CodePage)
+		if err == nil {
+			value, err = r.memo(&field, value)
+		}
+		if err != nil {
+			return Record{}, fmt.Errorf("record %d: %w", r.next, err)
+		}
+		record.Values[field.GetName()] = value
+	}
+	return record, nil
+}
+
+// isSet reports whether bit is set in the null flags of the current record.
+func (r *Reader) isSet(bit int) bool {
+	if bit < 0 || r.nullFlags < 0 || r.nullFlags+bit/8 >= len(r.buf) {
+		return false
+	}
+	return r.buf[r.nullFlags+bit/8]&(1<<(bit%8)) != 0
+}
+
+// memo reads the content of a memo field from the memo file, other values are returned as is.
+func (r *Reader) memo(field *FieldDescriptor, value any) (any, error) {
+	block, ok := value.(int64)
+	if !ok || r.Memo == nil {
+		return value, nil
+	}
+	switch field.Type {
+	case Memo, General, Double:
+	default:
+		return value, nil
+	}
+
+	data, err := r.Memo.Read(block)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.GetName(), err)
+	}
+	if field.Type != Memo || field.Flags&BinaryField != 0 {
+		return data, nil
+	}
+	return r.CodePage.Decode(string(data)), nil
+}
+
+// Records returns an iterator over the remaining records. Iteration stops after the first error, which is
+// yielded alongside an empty record.
+func (r *Reader) Records() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(Record{}, err)
+				return
+			}
+			if record.Deleted && !r.IncludeDeleted {
+				continue
+			}
+			if !yield(record, nil) {
+				return
+			}
+		}
+	}
+}
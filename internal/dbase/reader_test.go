@@ -0,0 +1,239 @@
+package dbase
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// rawTable assembles a table of the given version byte from its field descriptors and records, each record
+// starting with its deletion flag. Padding is left between the terminator of the descriptors and the first
+// record, as is done by the backlink of Visual FoxPro.
+func rawTable(version uint8, descriptors [][]byte, padding int, records ...string) []byte {
+	header := Header{Version: version, RecordCount: uint32(len(records)), YY: 124, MM: 1, DD: 2}
+	length := 32
+	if header.Format() == DBase7 {
+		length += 36
+	}
+	for _, d := range descriptors {
+		length += len(d)
+	}
+	header.HeaderLength = uint16(length + 1 + padding)
+	if len(records) > 0 {
+		header.RecordLength = uint16(len(records[0]))
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, header)
+	if header.Format() == DBase7 {
+		buf.Write(make([]byte, 36))
+	}
+	for _, d := range descriptors {
+		buf.Write(d)
+	}
+	buf.WriteByte(0x0D)
+	buf.Write(make([]byte, padding))
+	for _, record := range records {
+		buf.WriteString(record)
+	}
+	buf.WriteByte(0x1A)
+	return buf.Bytes()
+}
+
+// rawDescriptor returns the 32 byte descriptor of a field with the flags of Visual FoxPro.
+func rawDescriptor(name string, ft FieldType, length, flags uint8) []byte {
+	d := descriptor{Type: ft, Length: length, Flags: flags}
+	copy(d.Name[:10], name)
+	data, _ := binary.Append(nil, binary.LittleEndian, d)
+	return data
+}
+
+// rawDescriptor7 returns the 48 byte descriptor of a dBase 7 field.
+func rawDescriptor7(name string, ft FieldType, length uint8) []byte {
+	d := descriptor7{Type: ft, Length: length}
+	copy(d.Name[:31], name)
+	data, _ := binary.Append(nil, binary.LittleEndian, d)
+	return data
+}
+
+func TestReaderFormats(t *testing.T) {
+	le32 := func(v int32) string { return string(binary.LittleEndian.AppendUint32(nil, uint32(v))) }
+	le64 := func(v float64) string { return string(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v))) }
+	be32 := func(v int32) string { return string(binary.BigEndian.AppendUint32(nil, uint32(v)^1<<31)) }
+	be64 := func(v float64) string {
+		bits := math.Float64bits(v)
+		if v < 0 {
+			bits = ^bits
+		} else {
+			bits ^= 1 << 63
+		}
+		return string(binary.BigEndian.AppendUint64(nil, bits))
+	}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format Format
+		fields []string
+		want   []map[string]any
+	}{
+		{
+			// Visual FoxPro follows the descriptors with a backlink of 263 bytes and hides the null flags in a
+			// system field.
+			name: "visual foxpro",
+			data: rawTable(0x30, [][]byte{
+				rawDescriptor("NAME", Character, 8, 0),
+				rawDescriptor("COUNT", Integer, 4, 0),
+				rawDescriptor("VALUE", Double, 8, 0),
+				rawDescriptor("NOTE", Varchar, 6, NullableField),
+				rawDescriptor("_NullFlags", NullFlags, 1, SystemField),
+			}, 263,
+				" Denver  "+le32(-2)+le64(2.5)+"Mile\x00\x04"+"\x01",
+				" Aspen   "+le32(7)+le64(-0.5)+"      "+"\x02",
+			),
+			format: VisualFoxPro,
+			fields: []string{"NAME", "COUNT", "VALUE", "NOTE", "_NullFlags"},
+			want: []map[string]any{
+				{"NAME": "Denver", "COUNT": int64(-2), "VALUE": 2.5, "NOTE": "Mile"},
+				{"NAME": "Aspen", "COUNT": int64(7), "VALUE": -0.5, "NOTE": nil},
+			},
+		},
+		{
+			// dBase 7 has 48 byte descriptors with long names and big endian numbers that sort bytewise.
+			name: "dbase 7",
+			data: rawTable(0x04, [][]byte{
+				rawDescriptor7("COUNTY_NAME_IN_FULL", Character, 8),
+				rawDescriptor7("POPULATION_2020", Integer, 4),
+				rawDescriptor7("CHANGE_SINCE_2010", Double7, 8),
+			}, 0,
+				" Denver  "+be32(715522)+be64(0.192),
+				" Aspen   "+be32(-1)+be64(-2.5),
+				" Vail    "+"\x00\x00\x00\x00"+"\x00\x00\x00\x00\x00\x00\x00\x00",
+			),
+			format: DBase7,
+			fields: []string{"COUNTY_NAME_IN_FULL", "POPULATION_2020", "CHANGE_SINCE_2010"},
+			want: []map[string]any{
+				{"COUNTY_NAME_IN_FULL": "Denver", "POPULATION_2020": int64(715522), "CHANGE_SINCE_2010": 0.192},
+				{"COUNTY_NAME_IN_FULL": "Aspen", "POPULATION_2020": int64(-1), "CHANGE_SINCE_2010": -2.5},
+				{"COUNTY_NAME_IN_FULL": "Vail", "POPULATION_2020": nil, "CHANGE_SINCE_2010": nil},
+			},
+		},
+	}
+	for _, test := range tests {
+		reader, rows := readTable(t, test.data)
+		if format := reader.Header.Format(); format != test.format {
+			t.Errorf("%s: read as %s", test.name, format)
+		}
+		var fields []string
+		for _, field := range reader.Fields {
+			fields = append(fields, field.GetName())
+		}
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%s: fields %q, want %q", test.name, fields, test.fields)
+		}
+		if !reflect.DeepEqual(rows, test.want) {
+			t.Errorf("%s: read %v, want %v", test.name, rows, test.want)
+		}
+	}
+}
+
+func TestReaderDeleted(t *testing.T) {
+	fields, err := Descriptors([]Field{{Name: "NAME", Type: Character, Length: 9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"Adams", "Alamosa", "Arapahoe", "Archuleta"}
+	var rows []map[string]any
+	for _, name := range names {
+		rows = append(rows, map[string]any{"NAME": name})
+	}
+	data := writeTable(t, fields, rows)
+	reader, _ := readTable(t, data)
+	for _, i := range []int{1, 3} {
+		data[int(reader.Header.HeaderLength)+i*int(reader.Header.RecordLength)] = '*'
+	}
+
+	tests := []struct {
+		includeDeleted bool
+		want           []Record
+	}{
+		{false, []Record{
+			{Number: 1, Values: map[string]any{"NAME": "Adams"}},
+			{Number: 3, Values: map[string]any{"NAME": "Arapahoe"}},
+		}},
+		{true, []Record{
+			{Number: 1, Values: map[string]any{"NAME": "Adams"}},
+			{Number: 2, Deleted: true, Values: map[string]any{"NAME": "Alamosa"}},
+			{Number: 3, Values: map[string]any{"NAME": "Arapahoe"}},
+			{Number: 4, Deleted: true, Values: map[string]any{"NAME": "Archuleta"}},
+		}},
+	}
+	for _, test := range tests {
+		reader, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader.IncludeDeleted = test.includeDeleted
+		var got []Record
+		for record, err := range reader.Records() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, record)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("including deleted records %v: read %+v, want %+v", test.includeDeleted, got, test.want)
+		}
+	}
+}
+
+func TestReaderHeaderLength(t *testing.T) {
+	descriptors := [][]byte{rawDescriptor("NAME", Character, 8, 0), rawDescriptor("COUNT", Numeric, 4, 0)}
+	for _, padding := range []int{0, 1, 263} {
+		data := rawTable(0x03, descriptors, padding, " Adams     12", " Alamosa  -34")
+		reader, rows := readTable(t, data)
+		if len(reader.Fields) != 2 {
+			t.Errorf("padding %d: read %d fields", padding, len(reader.Fields))
+		}
+		want := []map[string]any{{"NAME": "Adams", "COUNT": int64(12)}, {"NAME": "Alamosa", "COUNT": int64(-34)}}
+		if !reflect.DeepEqual(rows, want) {
+			t.Errorf("padding %d: read %v, want %v", padding, rows, want)
+		}
+	}
+
+	// The descriptors may not run past the header length.
+	data := rawTable(0x03, descriptors, 0, " Adams     12")
+	binary.LittleEndian.PutUint16(data[8:], 32+32)
+	if _, err := NewReader(bytes.NewReader(data)); err == nil {
+		t.Error("read descriptors past the header length")
+	}
+}
+
+func TestReaderVarchar(t *testing.T) {
+	// The first two bits of the null flags give the length of the variable fields, the third is the null
+	// bit of the nullable field.
+	descriptors := [][]byte{
+		rawDescriptor("TEXT", Varchar, 6, 0),
+		rawDescriptor("EMPTY", Varchar, 0, 0),
+		rawDescriptor("NOTE", Character, 4, NullableField),
+		rawDescriptor("_NullFlags", NullFlags, 1, SystemField),
+	}
+	records := []string{
+		" Denver" + "" + "Note" + "\x00",
+		" Aspen\x05" + "" + "    " + "\x07",
+		" Vail \x0c" + "" + "    " + "\x03",
+	}
+	data := rawTable(0x30, descriptors, 263, records...)
+	_, rows := readTable(t, data)
+	want := []map[string]any{
+		{"TEXT": "Denver", "EMPTY": "", "NOTE": "Note"},
+		{"TEXT": "Aspen", "EMPTY": "", "NOTE": nil},
+		// A length past the end of the field keeps all but its last byte.
+		{"TEXT": "Vail", "EMPTY": "", "NOTE": ""},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("read %v, want %v", rows, want)
+	}
+}
@@ -0,0 +1,226 @@
+package dbase
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxNumericLength is the widest numeric field dBase IV accepts.
+const maxNumericLength = 20
+
+// Descriptor creates the field descriptor of the field. Fields of a fixed size, such as Date or Logical,
+// ignore the length and decimals of the field.
+func (f Field) Descriptor() (FieldDescriptor, error) {
+	fd := FieldDescriptor{Name: f.Name, Type: f.Type}
+	if f.Name == "" || len(f.Name) > 10 {
+		return fd, fmt.Errorf("field name %q has to be between 1 and 10 bytes", f.Name)
+	}
+
+	length, decimals := f.Length, f.Decimals
+	switch f.Type {
+	case Character:
+		if length < 1 || length > 254 {
+			return fd, fmt.Errorf("character field %s has length %d, which is not between 1 and 254", f.Name, length)
+		}
+		decimals = 0
+	case Numeric, Float:
+		if length < 1 || length > maxNumericLength {
+			return fd, fmt.Errorf("numeric field %s has length %d, which is not between 1 and %d", f.Name, length, maxNumericLength)
+		}
+		if decimals < 0 || (decimals > 0 && decimals > length-2) {
+			return fd, fmt.Errorf("numeric field %s of length %d has no room for %d decimals", f.Name, length, decimals)
+		}
+	case Date:
+		length, decimals = 8, 0
+	case Logical:
+		length, decimals = 1, 0
+	case Memo:
+		length, decimals = 10, 0
+	case Integer:
+		length, decimals = 4, 0
+	case Double, DateTime:
+		length, decimals = 8, 0
+	default:
+		return fd, fmt.Errorf("field %s has unsupported type %s", f.Name, f.Type)
+	}
+	fd.Length = uint8(length)
+	fd.DecimalCount = uint8(decimals)
+	return fd, nil
+}
+
+// Descriptors creates the field descriptors of a schema, see Field.Descriptor.
+func Descriptors(fields []Field) ([]FieldDescriptor, error) {
+	descriptors := make([]FieldDescriptor, len(fields))
+	for i, field := range fields {
+		var err error
+		if descriptors[i], err = field.Descriptor(); err != nil {
+			return nil, err
+		}
+	}
+	return descriptors, nil
+}
+
+// InferSchema picks a field for each column that fits every value of the rows:
+//
+//   - Text becomes a Character field as long as the longest value.
+//   - Integers become a Numeric field without decimals, floats a Numeric field with as many decimals as
+//     the most precise value needs, within the 20 characters of a numeric field.
+//   - Booleans become a Logical field and times a Date field.
+//
+// Columns mixing text with other values, and columns that only hold nil, become Character fields with the
+// values formatted as text.
+func InferSchema(columns []string, rows []map[string]any) []Field {
+	fields := make([]Field, len(columns))
+	for i, column := range columns {
+		fields[i] = inferField(column, rows)
+	}
+	return fields
+}
+
+func inferField(column string, rows []map[string]any) Field {
+	var texts, ints, floats, bools, times, others int
+	textLength, intDigits, fracDigits := 1, 1, 0
+	for _, row := range rows {
+		switch v := row[column].(type) {
+		case nil:
+		case string:
+			texts++
+			textLength = max(textLength, len(v))
+		case int, int32, int64, uint32:
+			ints++
+			n, _ := toInt(v)
+			intDigits = max(intDigits, len(strconv.FormatInt(n, 10)))
+		case float32, float64:
+			floats++
+			f, _ := toFloat(v)
+			if math.IsInf(f, 0) || math.IsNaN(f) {
+				continue
+			}
+			text := strconv.FormatFloat(f, 'f', -1, 64)
+			whole, frac, _ := strings.Cut(text, ".")
+			intDigits = max(intDigits, len(whole))
+			fracDigits = max(fracDigits, len(frac))
+		case bool:
+			bools++
+		case time.Time:
+			times++
+		default:
+			others++
+		}
+	}
+
+	numbers := ints + floats
+	switch {
+	case texts == 0 && others == 0 && numbers > 0 && bools == 0 && times == 0:
+		if floats == 0 {
+			return Field{Name: column, Type: Numeric, Length: min(intDigits, maxNumericLength)}
+		}
+		// Decimals give way to the whole part when both do not fit.
+		decimals := min(fracDigits, maxNumericLength-intDigits-1, 15)
+		decimals = max(decimals, 0)
+		length := intDigits
+		if decimals > 0 {
+			length += 1 + decimals
+		}
+		return Field{Name: column, Type: Numeric, Length: min(length, maxNumericLength), Decimals: decimals}
+	case texts+others+numbers == 0 && bools > 0 && times == 0:
+		return Field{Name: column, Type: Logical, Length: 1}
+	case texts+others+numbers == 0 && bools == 0 && times > 0:
+		return Field{Name: column, Type: Date, Length: 8}
+	}
+
+	// Anything else is written as text, which has to be as long as the longest formatted value.
+	for _, row := range rows {
+		switch v := row[column].(type) {
+		case nil, string:
+		default:
+			text, _ := (&FieldDescriptor{Type: Character}).text(v)
+			textLength = max(textLength, len(text))
+		}
+	}
+	return Field{Name: column, Type: Character, Length: min(textLength, 254)}
+}
+
+// ExportFields returns dBase III fields for the values of a table of any format, see Writer, along with the
+// column each field takes its values from:
+//
+//   - Hidden system fields, such as the null flags of Visual FoxPro, are left out.
+//   - Names longer than 10 bytes, which dBase 7 allows, are shortened and numbered when they collide.
+//   - Integers become Numeric fields, currencies Numeric fields with decimals, doubles Float fields with
+//     decimals, date times and timestamps Character fields holding RFC 3339 text, and varchars Character
+//     fields.
+//   - Memo fields hold their text in a memo file, see Writer.Memo. Binary fields, such as General or
+//     Varbinary, and binary memos have no dBase III equivalent and are left out.
+func ExportFields(fields []FieldDescriptor) ([]FieldDescriptor, []string) {
+	var exported []FieldDescriptor
+	var columns []string
+	names := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if field.Flags&SystemField != 0 || field.Flags&BinaryField != 0 {
+			continue
+		}
+
+		fd := FieldDescriptor{Type: field.Type, Length: field.Length, DecimalCount: field.DecimalCount}
+		switch field.Type {
+		case Character, Varchar:
+			fd.Type, fd.DecimalCount = Character, 0
+			fd.Length = min(max(fd.Length, 1), 254)
+		case Numeric, Float:
+			fd.Type = Numeric
+		case Date, Logical:
+		case Memo:
+			fd.Length, fd.DecimalCount = 10, 0
+		case Integer, Autoincrement:
+			// Wide enough for -2147483648
+			fd.Type, fd.Length, fd.DecimalCount = Numeric, 11, 0
+		case Currency:
+			fd.Type, fd.Length, fd.DecimalCount = Numeric, maxNumericLength, 4
+		case Double, Double7:
+			if field.Type == Double && field.Length != 8 {
+				// Binary memo of dBase IV
+				continue
+			}
+			// Float fields fall back to scientific notation for values too wide for their decimals.
+			fd.Type, fd.Length = Float, maxNumericLength
+			if fd.DecimalCount == 0 {
+				fd.DecimalCount = 6
+			}
+		case DateTime, Timestamp:
+			fd.Type, fd.Length, fd.DecimalCount = Character, uint8(len(time.RFC3339)), 0
+		default:
+			continue
+		}
+
+		fd.Name = exportName(field.GetName(), names)
+		exported = append(exported, fd)
+		columns = append(columns, field.GetName())
+	}
+	return exported, columns
+}
+
+// exportName shortens a field name to 10 bytes, numbering it when the shortened name is already taken.
+// Names are compared regardless of case.
+func exportName(name string, taken map[string]bool) string {
+	candidate := truncate(name, 10)
+	for n := 1; taken[strings.ToUpper(candidate)]; n++ {
+		suffix := "_" + strconv.Itoa(n)
+		candidate = truncate(name, 10-len(suffix)) + suffix
+	}
+	taken[strings.ToUpper(candidate)] = true
+	return candidate
+}
+
+// truncate shortens s to at most n bytes without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,314 @@
+package dbase
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Writer writes a dBase III table one record at a time. The record count in the header is only known
+// once every record has been written, so it is filled in by Close.
+type Writer struct {
+	Header Header
+	Fields []FieldDescriptor
+	// Keys of the attributes written to each field, see ExportFields. Fields take the attribute of their own
+	// name when it is nil.
+	Columns []string
+	// Memo file receiving the text of memo fields. Memo fields are written blank when it is nil.
+	Memo *MemoWriter
+
+	w   io.WriteSeeker
+	buf *bufio.Writer
+}
+
+// NewWriter writes the header of a table with the fields, see Descriptors and InferSchema to create them.
+func NewWriter(w io.WriteSeeker, fields []FieldDescriptor) (*Writer, error) {
+	if err := checkFields(fields); err != nil {
+		return nil, err
+	}
+
+	dw := &Writer{
+		Fields: fields,
+		w:      w,
+		buf:    bufio.NewWriter(w),
+	}
+
+	now := time.Now()
+	dw.Header.Version = 0x03
+	dw.Header.YY = uint8(now.Year() - 1900)
+	dw.Header.MM = uint8(now.Month())
+	dw.Header.DD = uint8(now.Day())
+	dw.Header.HeaderLength = uint16(32 + 32*len(fields) + 1)
+	dw.Header.RecordLength = 1
+	for _, field := range fields {
+		dw.Header.RecordLength += uint16(field.Length)
+	}
+
+	if err := binary.Write(dw.buf, binary.LittleEndian, dw.Header); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if err := writeDescriptor(dw.buf, field); err != nil {
+			return nil, err
+		}
+	}
+	if err := dw.buf.WriteByte(0x0D); err != nil {
+		return nil, err
+	}
+	return dw, nil
+}
+
+// checkFields makes sure the fields can be described by a header, both the header and the records have to
+// fit in their 16-bit lengths.
+func checkFields(fields []FieldDescriptor) error {
+	if 32+32*len(fields)+1 > math.MaxUint16 {
+		return fmt.Errorf("%d fields do not fit in a header", len(fields))
+	}
+
+	names := make(map[string]bool, len(fields))
+	length := 1
+	for _, field := range fields {
+		name := strings.ToUpper(field.GetName())
+		if name == "" {
+			return fmt.Errorf("field of type %s has no name", field.Type)
+		}
+		if len(name) > 10 {
+			return fmt.Errorf("field name %s is longer than 10 bytes", field.GetName())
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate field %s", field.GetName())
+		}
+		names[name] = true
+
+		if field.Length == 0 {
+			return fmt.Errorf("field %s has no length", field.GetName())
+		}
+		length += int(field.Length)
+	}
+	if length > math.MaxUint16 {
+		return fmt.Errorf("records of %d bytes are longer than the %d bytes a record can hold", length, math.MaxUint16)
+	}
+	return nil
+}
+
+// Write appends a record. Fields missing from attrs, or set to nil, are written blank.
+func (dw *Writer) Write(attrs map[string]any) error {
+	// Records start with the deletion flag, a space marks a valid record.
+	if err := dw.buf.WriteByte(' '); err != nil {
+		return err
+	}
+	for i, field := range dw.Fields {
+		column := field.GetName()
+		if dw.Columns != nil {
+			column = dw.Columns[i]
+		}
+		value := attrs[column]
+		if field.Type == Memo {
+			var err error
+			if value, err = dw.memo(value); err != nil {
+				return fmt.Errorf("field %s: %w", field.GetName(), err)
+			}
+		}
+		if err := field.Write(dw.buf, value); err != nil {
+			return err
+		}
+	}
+	dw.Header.RecordCount++
+	return nil
+}
+
+// memo writes the text of a memo field to the memo file and returns its block. Block numbers, which memo
+// fields hold when their own memo file was not read, point into that file and are written blank.
+func (dw *Writer) memo(value any) (any, error) {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return nil, nil
+	}
+	if len(data) == 0 || dw.Memo == nil {
+		return nil, nil
+	}
+	return dw.Memo.Write(data)
+}
+
+// Close terminates the table and writes the final header. It does not close the underlying writer, nor the
+// memo file.
+func (dw *Writer) Close() error {
+	if dw.Memo != nil {
+		// Tables with a .dbt file are marked by the high bit of their version.
+		dw.Header.Version = 0x83
+	}
+	if err := dw.buf.WriteByte(0x1A); err != nil {
+		return err
+	}
+	if err := dw.buf.Flush(); err != nil {
+		return err
+	}
+
+	if _, err := dw.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := binary.Write(dw.w, binary.LittleEndian, dw.Header); err != nil {
+		return err
+	}
+	_, err := dw.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// Write writes a value in the layout of the field, see Encode.
+func (fd *FieldDescriptor) Write(w io.Writer, value any) error {
+	data, err := fd.Encode(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Encode converts a value into the bytes of the field, the reverse of Decode. Values of the Go types Decode
+// returns for the field are formatted for it, strings are written as they are and nil is written blank.
+// Numeric values are right aligned, everything else is left aligned.
+func (fd *FieldDescriptor) Encode(value any) ([]byte, error) {
+	switch fd.Type {
+	case Integer:
+		data := make([]byte, 4)
+		if value != nil {
+			n, ok := toInt(value)
+			if !ok || n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("value %v does not fit in integer field %s", value, fd.GetName())
+			}
+			binary.LittleEndian.PutUint32(data, uint32(int32(n)))
+		}
+		return data, nil
+	case Double:
+		if fd.Length != 8 {
+			break
+		}
+		data := make([]byte, 8)
+		if value != nil {
+			f, ok := toFloat(value)
+			if !ok {
+				return nil, fmt.Errorf("value %v is not a number for field %s", value, fd.GetName())
+			}
+			binary.LittleEndian.PutUint64(data, math.Float64bits(f))
+		}
+		return data, nil
+	case DateTime:
+		data := make([]byte, 8)
+		if t, ok := value.(time.Time); ok {
+			ms := t.UTC().UnixMilli()
+			day := ms/86400000 + julianUnixEpoch
+			ms %= 86400000
+			if ms < 0 {
+				day--
+				ms += 86400000
+			}
+			binary.LittleEndian.PutUint32(data[:4], uint32(day))
+			binary.LittleEndian.PutUint32(data[4:], uint32(ms))
+		} else if value != nil {
+			return nil, fmt.Errorf("value %v is not a time for field %s", value, fd.GetName())
+		}
+		return data, nil
+	}
+
+	text, err := fd.text(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(text) > int(fd.Length) {
+		return nil, fmt.Errorf("value %q does not fit in field %s of length %d", text, fd.GetName(), fd.Length)
+	}
+
+	padding := strings.Repeat(" ", int(fd.Length)-len(text))
+	switch fd.Type {
+	case Numeric, Float, Memo:
+		text = padding + text
+	default:
+		text = text + padding
+	}
+	return []byte(text), nil
+}
+
+// text returns the text of a value for the text based field types.
+func (fd *FieldDescriptor) text(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+
+	switch fd.Type {
+	case Numeric, Float:
+		if n, ok := toInt(value); ok && fd.DecimalCount == 0 {
+			return strconv.FormatInt(n, 10), nil
+		}
+		if f, ok := toFloat(value); ok {
+			text := strconv.FormatFloat(f, 'f', int(fd.DecimalCount), 64)
+			// Float fields hold values too wide for their decimals in scientific notation, with as many
+			// digits as fit.
+			for digits := 16; fd.Type == Float && len(text) > int(fd.Length) && digits >= 0; digits-- {
+				text = strconv.FormatFloat(f, 'e', digits, 64)
+			}
+			return text, nil
+		}
+	case Date:
+		if t, ok := value.(time.Time); ok {
+			return t.Format("20060102"), nil
+		}
+	case Logical:
+		if b, ok := value.(bool); ok {
+			if b {
+				return "T", nil
+			}
+			return "F", nil
+		}
+	case Memo, Double:
+		if n, ok := toInt(value); ok {
+			return strconv.FormatInt(n, 10), nil
+		}
+	case Character:
+		if t, ok := value.(time.Time); ok {
+			// Date times of other formats, see ExportFields
+			return t.Format(time.RFC3339), nil
+		}
+		return fmt.Sprint(value), nil
+	default:
+		return fmt.Sprint(value), nil
+	}
+	return "", fmt.Errorf("value %v of type %T cannot be written to %s field %s", value, value, fd.Type, fd.GetName())
+}
+
+func toInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint32:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	n, ok := toInt(value)
+	return float64(n), ok
+}
@@ -0,0 +1,324 @@
+package dbase
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTable writes the rows with a Writer and returns the bytes of the table.
+func writeTable(t *testing.T, fields []FieldDescriptor, rows []map[string]any) []byte {
+	t.Helper()
+	file, err := os.Create(filepath.Join(t.TempDir(), "table.dbf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	w, err := NewWriter(file, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// readTable reads every record of a table.
+func readTable(t *testing.T, data []byte) (*Reader, []map[string]any) {
+	t.Helper()
+	reader, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]any
+	for record, err := range reader.Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, record.Values)
+	}
+	return reader, rows
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	day := time.Date(2020, time.March, 9, 0, 0, 0, 0, time.UTC)
+	rows := []map[string]any{
+		{"NAME": "Autauga", "COUNT": 55869, "RATIO": 0.25, "NEGATIVE": -3, "ACTIVE": true, "UPDATED": day, "SCORE": 1.5},
+		{"NAME": "Baldwin County", "COUNT": int64(-7), "RATIO": 12.5, "NEGATIVE": nil, "ACTIVE": false, "UPDATED": nil, "SCORE": -0.125},
+		{"NAME": nil, "COUNT": nil, "RATIO": nil, "NEGATIVE": int32(-120), "ACTIVE": nil, "SCORE": nil},
+	}
+	schema := InferSchema([]string{"NAME", "COUNT", "RATIO", "NEGATIVE", "ACTIVE", "UPDATED"}, rows)
+	schema = append(schema, Field{Name: "SCORE", Type: Float, Length: 8, Decimals: 3})
+
+	wantSchema := []Field{
+		{Name: "NAME", Type: Character, Length: 14},
+		{Name: "COUNT", Type: Numeric, Length: 5},
+		{Name: "RATIO", Type: Numeric, Length: 5, Decimals: 2},
+		{Name: "NEGATIVE", Type: Numeric, Length: 4},
+		{Name: "ACTIVE", Type: Logical, Length: 1},
+		{Name: "UPDATED", Type: Date, Length: 8},
+		{Name: "SCORE", Type: Float, Length: 8, Decimals: 3},
+	}
+	if !reflect.DeepEqual(schema, wantSchema) {
+		t.Fatalf("inferred schema %+v, want %+v", schema, wantSchema)
+	}
+
+	fields, err := Descriptors(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := writeTable(t, fields, rows)
+
+	reader, got := readTable(t, data)
+	header := reader.Header
+	if header.Version != 0x03 || header.RecordCount != 3 || header.RecordLength != 1+14+5+5+4+1+8+8 {
+		t.Errorf("header %+v", header)
+	}
+	if int(header.HeaderLength) != 32+32*len(fields)+1 || len(data) != int(header.HeaderLength)+3*int(header.RecordLength)+1 {
+		t.Errorf("header of %d bytes and %d bytes of records in a table of %d bytes", header.HeaderLength, header.RecordLength, len(data))
+	}
+	if !reflect.DeepEqual(reader.Schema(), wantSchema) {
+		t.Errorf("read schema %+v, want %+v", reader.Schema(), wantSchema)
+	}
+
+	want := []map[string]any{
+		{"NAME": "Autauga", "COUNT": int64(55869), "RATIO": 0.25, "NEGATIVE": int64(-3), "ACTIVE": true, "UPDATED": day, "SCORE": 1.5},
+		{"NAME": "Baldwin County", "COUNT": int64(-7), "RATIO": 12.5, "NEGATIVE": nil, "ACTIVE": false, "UPDATED": nil, "SCORE": -0.125},
+		// Blank text reads back as an empty string.
+		{"NAME": "", "COUNT": nil, "RATIO": nil, "NEGATIVE": int64(-120), "ACTIVE": nil, "UPDATED": nil, "SCORE": nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read %v, want %v", got, want)
+	}
+
+	// Numbers are right aligned with their decimals, everything else left aligned.
+	record := string(data[header.HeaderLength : header.HeaderLength+header.RecordLength])
+	if wantRecord := " Autauga       55869 0.25  -3T20200309   1.500"; record != wantRecord {
+		t.Errorf("first record %q, want %q", record, wantRecord)
+	}
+}
+
+func TestEncodeRejectsLongValues(t *testing.T) {
+	tests := []struct {
+		field FieldDescriptor
+		value any
+	}{
+		{FieldDescriptor{Type: Character, Length: 3}, "abcd"},
+		{FieldDescriptor{Type: Numeric, Length: 3}, 1234},
+		{FieldDescriptor{Type: Numeric, Length: 3}, int64(-100)},
+		{FieldDescriptor{Type: Numeric, Length: 5, DecimalCount: 2}, 123.4},
+		{FieldDescriptor{Type: Float, Length: 4, DecimalCount: 1}, -10.5},
+		{FieldDescriptor{Type: Logical, Length: 1}, "yes"},
+		{FieldDescriptor{Type: Memo, Length: 10}, int64(12345678901)},
+	}
+	for _, test := range tests {
+		test.field.Name = "FIELD"
+		if data, err := test.field.Encode(test.value); err == nil {
+			t.Errorf("%v written to %s field of length %d as %q", test.value, test.field.Type, test.field.Length, data)
+		}
+	}
+
+	// The writer refuses the record as a whole.
+	fields, err := Descriptors([]Field{{Name: "NAME", Type: Character, Length: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWriter(&seekBuffer{}, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(map[string]any{"NAME": "Autauga"}); err == nil {
+		t.Error("wrote a value longer than its field")
+	}
+}
+
+func TestDescriptorRejectsInvalidFields(t *testing.T) {
+	for _, field := range []Field{
+		{Name: "", Type: Character, Length: 1},
+		{Name: "LONGERTHAN10", Type: Character, Length: 1},
+		{Name: "TEXT", Type: Character, Length: 255},
+		{Name: "NUMBER", Type: Numeric, Length: 21},
+		{Name: "NUMBER", Type: Numeric, Length: 4, Decimals: 3},
+		{Name: "BLOB", Type: Varbinary, Length: 4},
+	} {
+		if _, err := field.Descriptor(); err == nil {
+			t.Errorf("field %+v described", field)
+		}
+	}
+
+	fields, err := Descriptors([]Field{{Name: "name", Type: Character, Length: 1}, {Name: "NAME", Type: Numeric, Length: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWriter(&seekBuffer{}, fields); err == nil {
+		t.Error("wrote a table with duplicate fields")
+	}
+}
+
+func TestExportFields(t *testing.T) {
+	field := func(name string, ft FieldType, length, decimals uint8, flags uint8) FieldDescriptor {
+		return FieldDescriptor{Name: name, Type: ft, Length: length, DecimalCount: decimals, Flags: flags}
+	}
+	fields := []FieldDescriptor{
+		field("NAME", Varchar, 40, 0, 0),
+		field("AMOUNT", Currency, 8, 0, 0),
+		field("COUNT", Integer, 4, 0, 0),
+		field("LONGFIELDNAME1", Double7, 8, 0, 0),
+		field("LONGFIELDNAME2", Double7, 8, 2, 0),
+		field("STAMP", Timestamp, 8, 0, 0),
+		field("NOTE", Memo, 4, 0, 0),
+		field("PICTURE", General, 4, 0, 0),
+		field("_NullFlags", NullFlags, 1, 0, SystemField),
+	}
+	got, columns := ExportFields(fields)
+
+	var schema []Field
+	for _, fd := range got {
+		schema = append(schema, fd.Field())
+	}
+	want := []Field{
+		{Name: "NAME", Type: Character, Length: 40},
+		{Name: "AMOUNT", Type: Numeric, Length: 20, Decimals: 4},
+		{Name: "COUNT", Type: Numeric, Length: 11},
+		{Name: "LONGFIELDN", Type: Float, Length: 20, Decimals: 6},
+		{Name: "LONGFIEL_1", Type: Float, Length: 20, Decimals: 2},
+		{Name: "STAMP", Type: Character, Length: 25},
+		{Name: "NOTE", Type: Memo, Length: 10},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("exported %+v, want %+v", schema, want)
+	}
+	wantColumns := []string{"NAME", "AMOUNT", "COUNT", "LONGFIELDNAME1", "LONGFIELDNAME2", "STAMP", "NOTE"}
+	if !reflect.DeepEqual(columns, wantColumns) {
+		t.Errorf("columns %v, want %v", columns, wantColumns)
+	}
+
+	// Names are shortened without splitting characters.
+	got, _ = ExportFields([]FieldDescriptor{
+		field("GEMEINDESCHLÜSSEL", Character, 8, 0, 0),
+		field("GEMEINDESCHLÜSSEL_2", Character, 8, 0, 0),
+		field("€€€€", Character, 8, 0, 0),
+		field("€€€€€", Character, 8, 0, 0),
+	})
+	var names []string
+	for _, fd := range got {
+		names = append(names, fd.Name)
+	}
+	if want := []string{"GEMEINDESC", "GEMEINDE_1", "€€€", "€€_1"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("exported names %q, want %q", names, want)
+	}
+	if got, _ := ExportFields([]FieldDescriptor{field("ÄÄÄÄÄÄ", Character, 8, 0, 0)}); got[0].Name != "ÄÄÄÄÄ" {
+		t.Errorf("exported name %q, want %q", got[0].Name, "ÄÄÄÄÄ")
+	}
+
+	// Doubles too large for their decimals are written in scientific notation.
+	exported, _ := ExportFields([]FieldDescriptor{field("VALUE", Double7, 8, 0, 0)})
+	rows := []map[string]any{{"VALUE": 2.5}, {"VALUE": -1.2345678901234567e13}, {"VALUE": 6.02214076e23}, {"VALUE": -0.125}}
+	_, read := readTable(t, writeTable(t, exported, rows))
+	for i, row := range read {
+		want := rows[i]["VALUE"].(float64)
+		if got, ok := row["VALUE"].(float64); !ok || math.Abs(got-want) > math.Abs(want)*1e-12 {
+			t.Errorf("wrote %v, read back %v", want, row["VALUE"])
+		}
+	}
+}
+
+func TestWriterMemo(t *testing.T) {
+	fields, err := Descriptors([]Field{{Name: "ID", Type: Numeric, Length: 2}, {Name: "NOTE", Type: Memo}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("memo text longer than its field ", 20)
+
+	var dbf, dbt seekBuffer
+	w, err := NewWriter(&dbf, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Memo, err = NewMemoWriter(&dbt); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []map[string]any{{"ID": 1, "NOTE": long}, {"ID": 2}, {"ID": 3, "NOTE": "short"}} {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Memo.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Memo.Write([]byte("end of file \x1a")); err == nil {
+		t.Error("wrote a memo holding an end of file marker")
+	}
+
+	// The first memo takes two blocks after the header.
+	if len(dbt.data) != 4*512 {
+		t.Errorf("memo file of %d bytes, want 4 blocks", len(dbt.data))
+	}
+	reader, err := NewReader(bytes.NewReader(dbf.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reader.Header.HasMemo() || reader.Header.MemoExt() != ".dbt" {
+		t.Fatalf("table of version %#x has no .dbt file", reader.Header.Version)
+	}
+	if reader.Memo, err = NewMemoFile(bytes.NewReader(dbt.data), &reader.Header); err != nil {
+		t.Fatal(err)
+	}
+	var notes []any
+	for record, err := range reader.Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		notes = append(notes, record.Values["NOTE"])
+	}
+	if want := []any{long, nil, "short"}; !reflect.DeepEqual(notes, want) {
+		t.Errorf("read memos %q, want %q", notes, want)
+	}
+}
+
+// seekBuffer is an in-memory io.WriteSeeker for the writers.
+type seekBuffer struct {
+	data []byte
+	pos  int64
+}
+
+func (b *seekBuffer) Write(p []byte) (int, error) {
+	if end := b.pos + int64(len(p)); end > int64(len(b.data)) {
+		b.data = append(b.data, make([]byte, end-int64(len(b.data)))...)
+	}
+	n := copy(b.data[b.pos:], p)
+	b.pos += int64(n)
+	return n, nil
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += b.pos
+	case io.SeekEnd:
+		offset += int64(len(b.data))
+	}
+	b.pos = offset
+	return offset, nil
+}
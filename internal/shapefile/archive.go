@@ -0,0 +1,185 @@
+package shapefile
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/nilptrderef/gogeo/internal/dbase"
+)
+
+// Archive is a zip file holding one or more shapefile layers, such as the downloads of the US Census
+// TIGER/Line files. Files are read straight from the archive without extracting them.
+type Archive struct {
+	Layers []*Layer
+
+	closer io.Closer
+}
+
+// Layer is a shapefile inside an archive along with the sibling files sharing its basename.
+type Layer struct {
+	// Path of the layer inside the archive without its extension, e.g. "tl_2023_us_county"
+	Name string
+
+	// Files of the layer keyed by their lower case extension, e.g. ".dbf"
+	files map[string]*zip.File
+}
+
+// OpenArchive opens the zip file at path. The archive has to be closed once its layers have been read.
+func OpenArchive(path string) (*Archive, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := newArchive(&zr.Reader)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	a.closer = zr
+	return a, nil
+}
+
+// NewArchive reads the zip file of the given size from r.
+func NewArchive(r io.ReaderAt, size int64) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return newArchive(zr)
+}
+
+// newArchive groups the files of the archive by basename. Every group with a .shp file is a layer, anything
+// else such as the XML metadata is ignored.
+func newArchive(zr *zip.Reader) (*Archive, error) {
+	groups := map[string]map[string]*zip.File{}
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		ext := path.Ext(file.Name)
+		name := strings.TrimSuffix(file.Name, ext)
+		if groups[name] == nil {
+			groups[name] = map[string]*zip.File{}
+		}
+		groups[name][strings.ToLower(ext)] = file
+	}
+
+	a := &Archive{}
+	for name, files := range groups {
+		if files[".shp"] != nil {
+			a.Layers = append(a.Layers, &Layer{Name: name, files: files})
+		}
+	}
+	if len(a.Layers) == 0 {
+		return nil, fmt.Errorf("archive contains no shapefiles")
+	}
+	slices.SortFunc(a.Layers, func(a, b *Layer) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return a, nil
+}
+
+// Layer finds a layer by name. The name may leave out the directories and the .shp extension.
+func (a *Archive) Layer(name string) (*Layer, error) {
+	name = strings.TrimSuffix(name, path.Ext(name))
+	for _, layer := range a.Layers {
+		if layer.Name == name || path.Base(layer.Name) == name {
+			return layer, nil
+		}
+	}
+	return nil, fmt.Errorf("archive has no layer %q", name)
+}
+
+// Close closes the zip file when the archive was opened from a path.
+func (a *Archive) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// Has reports whether the layer has a file with the extension, e.g. ".prj".
+func (l *Layer) Has(ext string) bool {
+	return l.files[strings.ToLower(ext)] != nil
+}
+
+// Open opens the file of the layer with the extension. Missing files return an error wrapping fs.ErrNotExist.
+func (l *Layer) Open(ext string) (io.ReadCloser, error) {
+	file := l.files[strings.ToLower(ext)]
+	if file == nil {
+		return nil, fmt.Errorf("layer %s has no %s file: %w", l.Name, ext, fs.ErrNotExist)
+	}
+	return file.Open()
+}
+
+// Parse reads the shapefile of the layer along with the attributes of its dbase file when there is one. Text
+// is decoded with the code page of the .cpg file, or with the language driver of the dbase file when the
+// .cpg names a code page that is not supported, and memo fields are read from the .dbt or .fpt file when
+// they are present.
+func (l *Layer) Parse() (*Shapefile, error) {
+	shp, err := l.Open(".shp")
+	if err != nil {
+		return nil, err
+	}
+	defer shp.Close()
+
+	s, err := Parse(bufio.NewReader(shp))
+	if err != nil {
+		return nil, fmt.Errorf("%s.shp: %w", l.Name, err)
+	}
+	if !l.Has(".dbf") {
+		return s, nil
+	}
+
+	if l.Has(".cpg") {
+		cpg, err := l.Open(".cpg")
+		if err != nil {
+			return nil, err
+		}
+		defer cpg.Close()
+
+		s.CodePage, err = dbase.ParseCpg(cpg)
+		if err != nil && !errors.Is(err, dbase.ErrUnsupportedCodePage) {
+			return nil, fmt.Errorf("%s.cpg: %w", l.Name, err)
+		}
+	}
+
+	for _, ext := range []string{".dbt", ".fpt"} {
+		if !l.Has(ext) {
+			continue
+		}
+		memo, err := l.Open(ext)
+		if err != nil {
+			return nil, err
+		}
+		defer memo.Close()
+
+		// Memos are read by block number, which needs random access that the archive does not offer.
+		data, err := io.ReadAll(memo)
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", l.Name, ext, err)
+		}
+		s.Memo = bytes.NewReader(data)
+		break
+	}
+
+	dbf, err := l.Open(".dbf")
+	if err != nil {
+		return nil, err
+	}
+	defer dbf.Close()
+
+	if err := s.LoadAttributes(bufio.NewReader(dbf)); err != nil {
+		return nil, fmt.Errorf("%s.dbf: %w", l.Name, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,236 @@
+package shapefile
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeArchive zips the files, keyed by their path inside the archive, in the order of the paths.
+func writeArchive(t testing.TB, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// layerFiles returns the files of a layer of three points named after the base of the archive paths, along
+// with a .dbf naming them when names is set.
+func layerFiles(t testing.TB, base, shpExt, dbfExt string, names []string) map[string][]byte {
+	t.Helper()
+	shp, shx, _ := writeShapes(t, PointType, testShapes(PointType))
+	files := map[string][]byte{base + shpExt: shp, base + ".shx": shx}
+	if names != nil {
+		files[base+dbfExt] = writeRows(t, names)
+	}
+	return files
+}
+
+func TestArchive(t *testing.T) {
+	names := []string{"Adams", "Café", "Denver"}
+	merge := func(groups ...map[string][]byte) map[string][]byte {
+		files := map[string][]byte{}
+		for _, group := range groups {
+			for name, data := range group {
+				files[name] = data
+			}
+		}
+		return files
+	}
+
+	tests := []struct {
+		name  string
+		files map[string][]byte
+		// Names of the layers, the one to parse and names which must not be found
+		layers  []string
+		layer   string
+		missing []string
+		// Whether the parsed layer has attributes
+		attrs bool
+	}{
+		{
+			name: "single layer",
+			files: merge(layerFiles(t, "tl_2023_08_county", ".shp", ".dbf", names), map[string][]byte{
+				"tl_2023_08_county.cpg":     []byte("UTF-8"),
+				"tl_2023_08_county.shp.xml": []byte("<metadata/>"),
+			}),
+			layers:  []string{"tl_2023_08_county"},
+			layer:   "tl_2023_08_county.shp",
+			missing: []string{"tl_2023_08_county.shp.xml", "tl_2023_08"},
+			attrs:   true,
+		},
+		{
+			name: "several layers",
+			files: merge(
+				layerFiles(t, "roads", ".shp", ".dbf", names),
+				layerFiles(t, "rivers", ".shp", ".dbf", names),
+				map[string][]byte{"lakes.dbf": writeRows(t, names)},
+			),
+			layers:  []string{"rivers", "roads"},
+			layer:   "rivers",
+			missing: []string{"lakes", "lakes.shp", "road"},
+			attrs:   true,
+		},
+		{
+			name:   "upper case extensions",
+			files:  merge(layerFiles(t, "COUNTY", ".SHP", ".Dbf", names), map[string][]byte{"COUNTY.CPG": []byte("UTF-8")}),
+			layers: []string{"COUNTY"},
+			layer:  "COUNTY.SHP",
+			attrs:  true,
+		},
+		{
+			name:    "nested layer",
+			files:   merge(layerFiles(t, "data/2023/county", ".shp", ".dbf", names), map[string][]byte{"data/": nil}),
+			layers:  []string{"data/2023/county"},
+			layer:   "county",
+			missing: []string{"2023/county", "data"},
+			attrs:   true,
+		},
+		{
+			name:   "no dbase file",
+			files:  layerFiles(t, "points", ".shp", ".dbf", nil),
+			layers: []string{"points"},
+			layer:  "points",
+		},
+	}
+	for _, test := range tests {
+		data := writeArchive(t, test.files)
+		archive, err := NewArchive(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var layers []string
+		for _, layer := range archive.Layers {
+			layers = append(layers, layer.Name)
+		}
+		if !slices.Equal(layers, test.layers) {
+			t.Errorf("%s: layers %q, want %q", test.name, layers, test.layers)
+		}
+		for _, name := range test.missing {
+			if layer, err := archive.Layer(name); err == nil {
+				t.Errorf("%s: %q found layer %s", test.name, name, layer.Name)
+			}
+		}
+
+		layer, err := archive.Layer(test.layer)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if layer.Has(".dbf") != test.attrs || layer.Has(".DBF") != test.attrs {
+			t.Errorf("%s: layer has a .dbf file %v, want %v", test.name, layer.Has(".dbf"), test.attrs)
+		}
+		if !test.attrs {
+			if _, err := layer.Open(".dbf"); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%s: opened a missing .dbf file, %v", test.name, err)
+			}
+		}
+
+		shp, err := layer.Parse()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(shp.Records) != 3 {
+			t.Errorf("%s: parsed %d records, want 3", test.name, len(shp.Records))
+			continue
+		}
+		for i, record := range shp.Records {
+			want := ""
+			if test.attrs {
+				want = names[i]
+			}
+			if got := record.Attrs.String("NAME"); got != want {
+				t.Errorf("%s: record %d named %q, want %q", test.name, record.Number, got, want)
+			}
+		}
+	}
+
+	if _, err := NewArchive(bytes.NewReader(nil), 0); err == nil {
+		t.Error("read an empty file as an archive")
+	}
+	data := writeArchive(t, map[string][]byte{"lakes.dbf": writeRows(t, names), "README.txt": nil})
+	if _, err := NewArchive(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("read an archive without shapefiles")
+	}
+}
+
+func TestArchiveCodePage(t *testing.T) {
+	tests := []struct {
+		cpg  string
+		want string
+	}{
+		{"UTF-8", "Café"},
+		// The UTF-8 bytes read as the box drawing characters of the DOS code page.
+		{"437", "Caf├⌐"},
+		// A code page that is not supported falls back to the language driver of the dbase file.
+		{"Shift_JIS", "Café"},
+	}
+	for _, test := range tests {
+		files := layerFiles(t, "county", ".shp", ".dbf", []string{"Café", "", ""})
+		files["county.cpg"] = []byte(test.cpg)
+		data := writeArchive(t, files)
+		archive, err := NewArchive(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		shp, err := archive.Layers[0].Parse()
+		if err != nil {
+			t.Errorf("%s: %v", test.cpg, err)
+			continue
+		}
+		if got := shp.Records[0].Attrs.String("NAME"); got != test.want {
+			t.Errorf("%s: read %q, want %q", test.cpg, got, test.want)
+		}
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tl_2023_08_county.zip")
+	if err := os.WriteFile(path, writeArchive(t, layerFiles(t, "tl_2023_08_county", ".shp", ".dbf", []string{"a", "b", "c"})), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := OpenArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := archive.Layer("tl_2023_08_county")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shx, err := layer.Open(".shx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, shx); err != nil {
+		t.Fatal(err)
+	}
+	shx.Close()
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenArchive(filepath.Join(t.TempDir(), "missing.zip")); err == nil {
+		t.Error("opened a missing archive")
+	}
+}
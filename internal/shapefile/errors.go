@@ -0,0 +1,21 @@
+package shapefile
+
+import "fmt"
+
+// ParseError reports a record that could not be parsed along with where it was found in the .shp file.
+type ParseError struct {
+	// One based record number, as expected from the position of the record. Record headers cannot be
+	// trusted once the file is corrupt.
+	Record int
+	// Byte offset of the record header in the .shp file
+	Offset int64
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("record %d at offset %d: %v", e.Record, e.Offset, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
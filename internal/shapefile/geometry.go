@@ -0,0 +1,744 @@
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	. "github.com/nilptrderef/gogeo/internal/common"
+)
+
+// Shape is the geometry carried by a single shapefile record.
+type Shape interface {
+	GetType() ShapeType
+
+	// Parse reads the content of a record that follows its shape type.
+	Parse(r *bytes.Reader) error
+
+	// Write writes the content of a record that follows its shape type. Bounding boxes and ranges are
+	// recomputed from the points so that they always match the written geometry.
+	Write(w io.Writer) error
+
+	// ContentLength returns the number of bytes written by Write.
+	ContentLength() int
+
+	// Transform replaces every point of the shape with the result of fn and recomputes the bounding box.
+	Transform(fn func(Point) Point)
+
+	// ToGeoJson converts the shape into its matching GeoJSON geometry. Null shapes return nil.
+	ToGeoJson() GeoJsonGeometry
+}
+
+// NewShape returns an empty shape for the given shape type, ready to be parsed.
+func NewShape(st ShapeType) (Shape, error) {
+	switch st {
+	case Null:
+		return &NullShape{}, nil
+	case PointType, PointZ, PointM:
+		return &PointShape{Type: st}, nil
+	case MultiPoint, MultiPointZ, MultiPointM:
+		return &MultiPointShape{Type: st}, nil
+	case Polyline, PolylineZ, PolylineM:
+		return &PolylineShape{Multipart{Type: st}}, nil
+	case PolygonType, PolygonZ, PolygonM:
+		return &Polygon{Multipart{Type: st}}, nil
+	case MultiPatch:
+		return &MultiPatchShape{Multipart: Multipart{Type: st}}, nil
+	default:
+		return nil, fmt.Errorf("unknown shape type %d", st)
+	}
+}
+
+// Measures holds the optional Z and M values of a shape, one per point.
+type Measures struct {
+	Zrange Range
+	Z      []float64
+	Mrange Range
+	M      []float64
+}
+
+// checkCounts makes sure the rest of the record is large enough for the parts and points it claims to hold,
+// before anything is allocated for them. Each part takes partSize bytes.
+func checkCounts(r *bytes.Reader, st ShapeType, parts, points uint32, partSize int) error {
+	need := uint64(parts)*uint64(partSize) + uint64(points)*16
+	if st.HasZ() {
+		need += 16 + uint64(points)*8
+	}
+	if need > uint64(r.Len()) {
+		return fmt.Errorf("%d parts and %d points need %d bytes but the record only has %d left", parts, points, need, r.Len())
+	}
+	return nil
+}
+
+// checkParts makes sure every part starts within the points, after the part before it.
+func checkParts(parts []uint32, points int) error {
+	for i, start := range parts {
+		switch {
+		case i == 0 && start != 0:
+			return fmt.Errorf("first part starts at point %d instead of 0", start)
+		case int64(start) >= int64(points):
+			return fmt.Errorf("part %d starts at point %d of %d", i, start, points)
+		case i > 0 && start < parts[i-1]:
+			return fmt.Errorf("part %d starts at point %d, before the part ahead of it", i, start)
+		}
+	}
+	if len(parts) == 0 && points > 0 {
+		return fmt.Errorf("%d points do not belong to any part", points)
+	}
+	return nil
+}
+
+// checkCount makes sure the Z types have a Z value for every point, and that the M values, which are
+// optional, cover every point when there are any.
+func (ms *Measures) checkCount(st ShapeType, points int) error {
+	if st.HasZ() && len(ms.Z) != points {
+		return fmt.Errorf("%d Z values for %d points", len(ms.Z), points)
+	}
+	if st.HasM() && ms.M != nil && len(ms.M) != points {
+		return fmt.Errorf("%d M values for %d points", len(ms.M), points)
+	}
+	return nil
+}
+
+func (ms *Measures) parse(r *bytes.Reader, st ShapeType, count int) error {
+	if st.HasZ() {
+		if err := binary.Read(r, binary.LittleEndian, &ms.Zrange); err != nil {
+			return err
+		}
+		ms.Z = make([]float64, count)
+		if err := binary.Read(r, binary.LittleEndian, &ms.Z); err != nil {
+			return err
+		}
+	}
+
+	// Measures are optional for both the Z and M types, they are only present when the record has room for them.
+	if st.HasM() && r.Len() >= 16+count*8 {
+		if err := binary.Read(r, binary.LittleEndian, &ms.Mrange); err != nil {
+			return err
+		}
+		ms.M = make([]float64, count)
+		if err := binary.Read(r, binary.LittleEndian, &ms.M); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *Measures) write(w io.Writer, st ShapeType) error {
+	if st.HasZ() {
+		ms.Zrange = valueRange(ms.Z)
+		if err := binary.Write(w, binary.LittleEndian, ms.Zrange); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, ms.Z); err != nil {
+			return err
+		}
+	}
+	if st.HasM() && ms.M != nil {
+		ms.Mrange = valueRange(ms.M)
+		if err := binary.Write(w, binary.LittleEndian, ms.Mrange); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, ms.M); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *Measures) contentLength(st ShapeType) int {
+	length := 0
+	if st.HasZ() {
+		length += 16 + len(ms.Z)*8
+	}
+	if st.HasM() && ms.M != nil {
+		length += 16 + len(ms.M)*8
+	}
+	return length
+}
+
+// position returns the GeoJSON position of point i, including its Z value when present.
+func (ms *Measures) position(pt Point, i int) []float64 {
+	if len(ms.Z) > i {
+		return []float64{pt.X, pt.Y, ms.Z[i]}
+	}
+	return []float64{pt.X, pt.Y}
+}
+
+type NullShape struct{}
+
+func (n *NullShape) GetType() ShapeType             { return Null }
+func (n *NullShape) Parse(r *bytes.Reader) error    { return nil }
+func (n *NullShape) Write(w io.Writer) error        { return nil }
+func (n *NullShape) ContentLength() int             { return 0 }
+func (n *NullShape) Transform(fn func(Point) Point) {}
+func (n *NullShape) ToGeoJson() GeoJsonGeometry     { return nil }
+
+// PointShape holds Point, PointZ and PointM records.
+type PointShape struct {
+	Type  ShapeType
+	Point Point
+	Z     float64
+	M     float64
+}
+
+func (p *PointShape) GetType() ShapeType { return p.Type }
+
+func (p *PointShape) Parse(r *bytes.Reader) error {
+	if err := binary.Read(r, binary.LittleEndian, &p.Point); err != nil {
+		return err
+	}
+	if p.Type.HasZ() {
+		if err := binary.Read(r, binary.LittleEndian, &p.Z); err != nil {
+			return err
+		}
+	}
+	if p.Type.HasM() && r.Len() >= 8 {
+		if err := binary.Read(r, binary.LittleEndian, &p.M); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PointShape) Write(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, p.Point); err != nil {
+		return err
+	}
+	if p.Type.HasZ() {
+		if err := binary.Write(w, binary.LittleEndian, p.Z); err != nil {
+			return err
+		}
+	}
+	if p.Type.HasM() {
+		if err := binary.Write(w, binary.LittleEndian, p.M); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PointShape) ContentLength() int {
+	length := 16
+	if p.Type.HasZ() {
+		length += 8
+	}
+	if p.Type.HasM() {
+		length += 8
+	}
+	return length
+}
+
+func (p *PointShape) Transform(fn func(Point) Point) {
+	p.Point = fn(p.Point)
+}
+
+func (p *PointShape) ToGeoJson() GeoJsonGeometry {
+	coordinates := []float64{p.Point.X, p.Point.Y}
+	if p.Type.HasZ() {
+		coordinates = append(coordinates, p.Z)
+	}
+	return GeoJsonPoint{Type: "Point", Coordinates: coordinates}
+}
+
+// MultiPointShape holds MultiPoint, MultiPointZ and MultiPointM records.
+type MultiPointShape struct {
+	Type   ShapeType
+	Mbr    Rectangle
+	Points []Point
+	Measures
+}
+
+func (mp *MultiPointShape) GetType() ShapeType { return mp.Type }
+
+func (mp *MultiPointShape) Parse(r *bytes.Reader) error {
+	if err := binary.Read(r, binary.LittleEndian, &mp.Mbr); err != nil {
+		return err
+	}
+
+	var count uint32
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return err
+	}
+	if err := checkCounts(r, mp.Type, 0, count, 0); err != nil {
+		return err
+	}
+
+	mp.Points = make([]Point, count)
+	if err := binary.Read(r, binary.LittleEndian, &mp.Points); err != nil {
+		return err
+	}
+
+	return mp.Measures.parse(r, mp.Type, len(mp.Points))
+}
+
+// check makes sure the measures match the points before the shape is written.
+func (mp *MultiPointShape) check() error {
+	return mp.Measures.checkCount(mp.Type, len(mp.Points))
+}
+
+func (mp *MultiPointShape) Write(w io.Writer) error {
+	mp.Mbr = bounds(mp.Points)
+	if err := binary.Write(w, binary.LittleEndian, mp.Mbr); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(mp.Points))); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, mp.Points); err != nil {
+		return err
+	}
+	return mp.Measures.write(w, mp.Type)
+}
+
+func (mp *MultiPointShape) ContentLength() int {
+	return 36 + len(mp.Points)*16 + mp.Measures.contentLength(mp.Type)
+}
+
+func (mp *MultiPointShape) Transform(fn func(Point) Point) {
+	mp.Mbr = transformPoints(mp.Points, fn)
+}
+
+func (mp *MultiPointShape) ToGeoJson() GeoJsonGeometry {
+	out := GeoJsonMultiPoint{
+		Type:        "MultiPoint",
+		Coordinates: make([][]float64, len(mp.Points)),
+	}
+	for i, pt := range mp.Points {
+		out.Coordinates[i] = mp.position(pt, i)
+	}
+	return out
+}
+
+// Multipart is the layout shared by the polyline, polygon and multipatch records: a bounding box,
+// a list of part offsets and a flat list of points, optionally followed by Z and M measures.
+type Multipart struct {
+	Type   ShapeType
+	Header PolygonHeader
+	Parts  []uint32
+	Points []Point
+	Measures
+}
+
+type PolygonHeader struct {
+	Mbr        Rectangle
+	PartCount  uint32
+	PointCount uint32
+}
+
+func (mp *Multipart) GetType() ShapeType { return mp.Type }
+
+func (mp *Multipart) Parse(r *bytes.Reader) error {
+	if err := binary.Read(r, binary.LittleEndian, &mp.Header); err != nil {
+		return err
+	}
+	if err := checkCounts(r, mp.Type, mp.Header.PartCount, mp.Header.PointCount, 4); err != nil {
+		return err
+	}
+
+	mp.Parts = make([]uint32, mp.Header.PartCount)
+	if err := binary.Read(r, binary.LittleEndian, &mp.Parts); err != nil {
+		return err
+	}
+	if err := checkParts(mp.Parts, int(mp.Header.PointCount)); err != nil {
+		return err
+	}
+
+	mp.Points = make([]Point, mp.Header.PointCount)
+	if err := binary.Read(r, binary.LittleEndian, &mp.Points); err != nil {
+		return err
+	}
+
+	return mp.Measures.parse(r, mp.Type, len(mp.Points))
+}
+
+// check makes sure the parts and measures match the points before the shape is written.
+func (mp *Multipart) check() error {
+	if err := checkParts(mp.Parts, len(mp.Points)); err != nil {
+		return err
+	}
+	return mp.Measures.checkCount(mp.Type, len(mp.Points))
+}
+
+func (mp *Multipart) Write(w io.Writer) error {
+	mp.updateHeader()
+	if err := binary.Write(w, binary.LittleEndian, mp.Header); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, mp.Parts); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, mp.Points); err != nil {
+		return err
+	}
+	return mp.Measures.write(w, mp.Type)
+}
+
+func (mp *Multipart) ContentLength() int {
+	return 40 + len(mp.Parts)*4 + len(mp.Points)*16 + mp.Measures.contentLength(mp.Type)
+}
+
+func (mp *Multipart) updateHeader() {
+	mp.Header.Mbr = bounds(mp.Points)
+	mp.Header.PartCount = uint32(len(mp.Parts))
+	mp.Header.PointCount = uint32(len(mp.Points))
+}
+
+func (mp *Multipart) Transform(fn func(Point) Point) {
+	mp.Header.Mbr = transformPoints(mp.Points, fn)
+}
+
+// PartBounds returns the start and end offsets into Points of part i.
+func (mp *Multipart) PartBounds(i int) (int, int) {
+	start := int(mp.Parts[i])
+	end := len(mp.Points)
+	if i+1 < len(mp.Parts) {
+		end = int(mp.Parts[i+1])
+	}
+	return start, end
+}
+
+// partPositions returns the GeoJSON positions of every point in part i.
+func (mp *Multipart) partPositions(i int) [][]float64 {
+	start, end := mp.PartBounds(i)
+	positions := make([][]float64, 0, end-start)
+	for j := start; j < end; j++ {
+		positions = append(positions, mp.position(mp.Points[j], j))
+	}
+	return positions
+}
+
+// coordinates returns the X and Y values of every part as flat coordinates [x, y, x, y, ...].
+func (mp *Multipart) coordinates() [][]float64 {
+	parts := make([][]float64, len(mp.Parts))
+	for i := range mp.Parts {
+		start, end := mp.PartBounds(i)
+		parts[i] = make([]float64, 0, (end-start)*2)
+		for _, pt := range mp.Points[start:end] {
+			parts[i] = append(parts[i], pt.X, pt.Y)
+		}
+	}
+	return parts
+}
+
+// subset returns the points of every part that remain in the matching flat coordinates, which have to be an
+// ordered subset of the part, along with their Z and M values. Parts left without any coordinates are dropped.
+func (mp *Multipart) subset(parts [][]float64) Multipart {
+	kept := Multipart{Type: mp.Type}
+	for i, coordinates := range parts {
+		if len(coordinates) == 0 {
+			continue
+		}
+		// Walking both lists side by side finds the measures that belong to each remaining point.
+		start, end := mp.PartBounds(i)
+		kept.Parts = append(kept.Parts, uint32(len(kept.Points)))
+		k := 0
+		for j := start; j < end && k < len(coordinates); j++ {
+			if mp.Points[j].X != coordinates[k] || mp.Points[j].Y != coordinates[k+1] {
+				continue
+			}
+			kept.Points = append(kept.Points, mp.Points[j])
+			if mp.Z != nil {
+				kept.Z = append(kept.Z, mp.Z[j])
+			}
+			if mp.M != nil {
+				kept.M = append(kept.M, mp.M[j])
+			}
+			k += 2
+		}
+	}
+	kept.updateHeader()
+	return kept
+}
+
+// PolylineShape holds Polyline, PolylineZ and PolylineM records.
+type PolylineShape struct {
+	Multipart
+}
+
+func (p *PolylineShape) ToGeoJson() GeoJsonGeometry {
+	if len(p.Parts) == 1 {
+		return GeoJsonLineString{Type: "LineString", Coordinates: p.partPositions(0)}
+	}
+
+	out := GeoJsonMultiLineString{
+		Type:        "MultiLineString",
+		Coordinates: make([][][]float64, len(p.Parts)),
+	}
+	for i := range p.Parts {
+		out.Coordinates[i] = p.partPositions(i)
+	}
+	return out
+}
+
+// Polygon holds Polygon, PolygonZ and PolygonM records.
+type Polygon struct {
+	Multipart
+}
+
+// ToGeoJson emits a Polygon when the record has a single outer ring and a MultiPolygon otherwise. Rings
+// are oriented as required by RFC 7946, outer rings counterclockwise and holes clockwise, which is the
+// opposite of the shapefile convention.
+func (p *Polygon) ToGeoJson() GeoJsonGeometry {
+	groups := p.Rings()
+	polygons := make([][][][]float64, len(groups))
+	for i, group := range groups {
+		for j, part := range group {
+			positions := p.partPositions(part)
+			// The shell of a group has to be counterclockwise and every hole clockwise.
+			if (j == 0) != (p.signedArea(part) > 0) {
+				slices.Reverse(positions)
+			}
+			polygons[i] = append(polygons[i], positions)
+		}
+	}
+
+	if len(polygons) == 1 {
+		return GeoJsonPolygon{Type: "Polygon", Coordinates: polygons[0]}
+	}
+	return GeoJsonMultiPolygon{Type: "MultiPolygon", Coordinates: polygons}
+}
+
+// Rings groups the parts of the polygon into polygons, each listing the index of its outer ring followed by
+// the indexes of its holes. Shapefiles store outer rings clockwise and holes counterclockwise, and a hole
+// belongs to the smallest outer ring containing it. Holes outside of every outer ring are treated as outer
+// rings of their own, as are rings without any area.
+func (p *Polygon) Rings() [][]int {
+	areas := make([]float64, len(p.Parts))
+	var shells, holes []int
+	for i := range p.Parts {
+		areas[i] = p.signedArea(i)
+		if areas[i] > 0 {
+			holes = append(holes, i)
+		} else {
+			shells = append(shells, i)
+		}
+	}
+
+	groups := make([][]int, 0, len(shells))
+	group := make(map[int]int, len(shells))
+	for _, shell := range shells {
+		group[shell] = len(groups)
+		groups = append(groups, []int{shell})
+	}
+
+	for _, hole := range holes {
+		owner := -1
+		for _, shell := range shells {
+			if (owner < 0 || -areas[shell] < -areas[owner]) && p.containsPart(shell, hole) {
+				owner = shell
+			}
+		}
+		if owner < 0 {
+			groups = append(groups, []int{hole})
+			continue
+		}
+		groups[group[owner]] = append(groups[group[owner]], hole)
+	}
+	return groups
+}
+
+// signedArea returns the area of part i, negative when the ring is clockwise.
+func (p *Polygon) signedArea(i int) float64 {
+	start, end := p.PartBounds(i)
+	area := 0.0
+	for j := start; j+1 < end; j++ {
+		area += p.Points[j].X*p.Points[j+1].Y - p.Points[j+1].X*p.Points[j].Y
+	}
+	if end-start > 2 {
+		// Rings should be closed but the area is the same either way.
+		first, last := p.Points[start], p.Points[end-1]
+		area += last.X*first.Y - first.X*last.Y
+	}
+	return area / 2
+}
+
+// containsPart reports whether the ring of part outer contains the ring of part inner. Rings of a valid
+// polygon do not cross, so the first point of inner that is not also a point of outer decides.
+func (p *Polygon) containsPart(outer, inner int) bool {
+	start, end := p.PartBounds(outer)
+	ring := p.Points[start:end]
+	mbr := bounds(ring)
+
+	start, end = p.PartBounds(inner)
+	for _, pt := range p.Points[start:end] {
+		if pt.X < mbr.Start.X || pt.X > mbr.End.X || pt.Y < mbr.Start.Y || pt.Y > mbr.End.Y {
+			return false
+		}
+		if slices.Contains(ring, pt) {
+			continue
+		}
+		return pointInRing(pt, ring)
+	}
+	return false
+}
+
+// pointInRing reports whether pt lies inside the ring by casting a ray along the x axis.
+func pointInRing(pt Point, ring []Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Y > pt.Y) != (b.Y > pt.Y) && pt.X < (b.X-a.X)*(pt.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+type PatchType uint32
+
+const (
+	TriangleStrip PatchType = 0
+	TriangleFan   PatchType = 1
+	OuterRing     PatchType = 2
+	InnerRing     PatchType = 3
+	FirstRing     PatchType = 4
+	Ring          PatchType = 5
+)
+
+// MultiPatchShape holds MultiPatch records. Its parts are typed as triangle strips, triangle fans or rings.
+type MultiPatchShape struct {
+	Multipart
+	PartTypes []PatchType
+}
+
+func (mp *MultiPatchShape) Parse(r *bytes.Reader) error {
+	if err := binary.Read(r, binary.LittleEndian, &mp.Header); err != nil {
+		return err
+	}
+	// Every part has both an offset and a part type.
+	if err := checkCounts(r, mp.Type, mp.Header.PartCount, mp.Header.PointCount, 8); err != nil {
+		return err
+	}
+
+	mp.Parts = make([]uint32, mp.Header.PartCount)
+	if err := binary.Read(r, binary.LittleEndian, &mp.Parts); err != nil {
+		return err
+	}
+	if err := checkParts(mp.Parts, int(mp.Header.PointCount)); err != nil {
+		return err
+	}
+
+	mp.PartTypes = make([]PatchType, mp.Header.PartCount)
+	if err := binary.Read(r, binary.LittleEndian, &mp.PartTypes); err != nil {
+		return err
+	}
+
+	mp.Points = make([]Point, mp.Header.PointCount)
+	if err := binary.Read(r, binary.LittleEndian, &mp.Points); err != nil {
+		return err
+	}
+
+	return mp.Measures.parse(r, mp.Type, len(mp.Points))
+}
+
+// check makes sure every part has a part type, on top of the checks of Multipart.
+func (mp *MultiPatchShape) check() error {
+	if len(mp.PartTypes) != len(mp.Parts) {
+		return fmt.Errorf("%d part types for %d parts", len(mp.PartTypes), len(mp.Parts))
+	}
+	return mp.Multipart.check()
+}
+
+func (mp *MultiPatchShape) Write(w io.Writer) error {
+	mp.updateHeader()
+	if err := binary.Write(w, binary.LittleEndian, mp.Header); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, mp.Parts); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, mp.PartTypes); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, mp.Points); err != nil {
+		return err
+	}
+	return mp.Measures.write(w, mp.Type)
+}
+
+func (mp *MultiPatchShape) ContentLength() int {
+	return mp.Multipart.ContentLength() + len(mp.PartTypes)*4
+}
+
+// ToGeoJson flattens the patch into a MultiPolygon. Triangle strips and fans become one polygon per
+// triangle, inner rings are attached as holes to the preceding outer ring and any other ring starts
+// a new polygon.
+func (mp *MultiPatchShape) ToGeoJson() GeoJsonGeometry {
+	out := GeoJsonMultiPolygon{
+		Type:        "MultiPolygon",
+		Coordinates: [][][][]float64{},
+	}
+
+	triangle := func(a, b, c []float64) {
+		out.Coordinates = append(out.Coordinates, [][][]float64{{a, b, c, a}})
+	}
+
+	for i := range mp.Parts {
+		positions := mp.partPositions(i)
+		// Parts without a part type, which only a shape built by hand can have, are taken as plain rings.
+		partType := Ring
+		if i < len(mp.PartTypes) {
+			partType = mp.PartTypes[i]
+		}
+		switch partType {
+		case TriangleStrip:
+			for j := 2; j < len(positions); j++ {
+				triangle(positions[j-2], positions[j-1], positions[j])
+			}
+		case TriangleFan:
+			for j := 2; j < len(positions); j++ {
+				triangle(positions[0], positions[j-1], positions[j])
+			}
+		case InnerRing:
+			if last := len(out.Coordinates) - 1; last >= 0 {
+				out.Coordinates[last] = append(out.Coordinates[last], positions)
+				continue
+			}
+			fallthrough
+		default:
+			out.Coordinates = append(out.Coordinates, [][][]float64{positions})
+		}
+	}
+	return out
+}
+
+// bounds returns the bounding box of the points. An empty list has an empty box at the origin.
+func bounds(points []Point) Rectangle {
+	if len(points) == 0 {
+		return Rectangle{}
+	}
+	return transformPoints(points, func(pt Point) Point { return pt })
+}
+
+// valueRange returns the range of the values. An empty list has an empty range.
+func valueRange(values []float64) Range {
+	if len(values) == 0 {
+		return Range{}
+	}
+	r := Range{Min: math.MaxFloat64, Max: -math.MaxFloat64}
+	for _, value := range values {
+		r.Min = min(r.Min, value)
+		r.Max = max(r.Max, value)
+	}
+	return r
+}
+
+// transformPoints applies fn to every point in place and returns the bounding box of the results.
+func transformPoints(points []Point, fn func(Point) Point) Rectangle {
+	mbr := Rectangle{
+		Start: Point{X: math.MaxFloat64, Y: math.MaxFloat64},
+		End:   Point{X: -math.MaxFloat64, Y: -math.MaxFloat64},
+	}
+	for i, pt := range points {
+		points[i] = fn(pt)
+		mbr.Start.X = min(mbr.Start.X, points[i].X)
+		mbr.End.X = max(mbr.End.X, points[i].X)
+		mbr.Start.Y = min(mbr.Start.Y, points[i].Y)
+		mbr.End.Y = max(mbr.End.Y, points[i].Y)
+	}
+	return mbr
+}
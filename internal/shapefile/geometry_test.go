@@ -0,0 +1,171 @@
+package shapefile
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/nilptrderef/gogeo/internal/common"
+)
+
+// polygon builds a polygon with a part for each ring.
+func polygon(rings ...[]Point) *Polygon {
+	p := &Polygon{Multipart{Type: PolygonType}}
+	for _, ring := range rings {
+		p.Parts = append(p.Parts, uint32(len(p.Points)))
+		p.Points = append(p.Points, ring...)
+	}
+	return p
+}
+
+// Rings are clockwise shells and counterclockwise holes, as stored in shapefiles.
+var (
+	square = ring(0, 0, 0, 10, 10, 10, 10, 0, 0, 0)
+	hole   = ring(2, 2, 4, 2, 4, 4, 2, 4, 2, 2)
+	island = ring(20, 0, 20, 10, 30, 10, 30, 0, 20, 0)
+	lagoon = ring(22, 2, 24, 2, 24, 4, 22, 4, 22, 2)
+)
+
+func TestPolygonRings(t *testing.T) {
+	tests := []struct {
+		name  string
+		rings [][]Point
+		want  [][]int
+	}{
+		{"shell", [][]Point{square}, [][]int{{0}}},
+		{"shell with a hole", [][]Point{square, hole}, [][]int{{0, 1}}},
+		{"two islands, one with a hole", [][]Point{square, island, lagoon}, [][]int{{0}, {1, 2}}},
+		{"hole of the second island first", [][]Point{lagoon, square, island}, [][]int{{1}, {2, 0}}},
+		{"hole before its shell", [][]Point{hole, square}, [][]int{{1, 0}}},
+		// An island in a lake: the hole of the island belongs to it rather than to the shell around the lake.
+		{"island in a lake", [][]Point{
+			ring(-10, -10, -10, 40, 40, 40, 40, -10, -10, -10),
+			ring(-5, -5, 35, -5, 35, 35, -5, 35, -5, -5),
+			square,
+			hole,
+		}, [][]int{{0, 1}, {2, 3}}},
+		// The corner of the hole touches the shell, its other points decide.
+		{"hole touching its shell", [][]Point{square, ring(0, 0, 4, 2, 2, 4, 0, 0)}, [][]int{{0, 1}}},
+		{"hole outside every shell", [][]Point{square, lagoon}, [][]int{{0}, {1}}},
+		{"ring without area", [][]Point{square, ring(2, 2, 4, 4, 2, 2)}, [][]int{{0}, {1}}},
+	}
+	for _, test := range tests {
+		if got := polygon(test.rings...).Rings(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: rings %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestContainsPart(t *testing.T) {
+	tests := []struct {
+		name         string
+		outer, inner []Point
+		want         bool
+	}{
+		{"hole", square, hole, true},
+		{"same ring", square, square, false},
+		{"shell in its hole", hole, square, false},
+		{"apart", square, island, false},
+		{"touching corner", square, ring(0, 0, 4, 2, 2, 4, 0, 0), true},
+		{"sharing an edge", square, ring(0, 0, 0, 10, -5, 5, 0, 0), false},
+		// The bounds of the concave ring contain the hole, but the ring does not.
+		{"in the notch", ring(0, 0, 0, 10, 10, 10, 10, 8, 2, 8, 2, 0, 0, 0), ring(4, 2, 6, 2, 6, 4, 4, 4, 4, 2), false},
+	}
+	for _, test := range tests {
+		if got := polygon(test.outer, test.inner).containsPart(0, 1); got != test.want {
+			t.Errorf("%s: contains %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPointInRing(t *testing.T) {
+	// A U opening to the top.
+	u := ring(0, 0, 0, 10, 3, 10, 3, 3, 7, 3, 7, 10, 10, 10, 10, 0, 0, 0)
+	tests := []struct {
+		pt   Point
+		ring []Point
+		want bool
+	}{
+		{Point{X: 5, Y: 5}, square, true},
+		{Point{X: 0.001, Y: 9.999}, square, true},
+		{Point{X: 15, Y: 5}, square, false},
+		{Point{X: -5, Y: 5}, square, false},
+		{Point{X: 5, Y: -5}, square, false},
+		{Point{X: 1, Y: 5}, u, true},
+		{Point{X: 5, Y: 2}, u, true},
+		{Point{X: 5, Y: 5}, u, false},
+		// Rings need not be closed.
+		{Point{X: 5, Y: 5}, square[:4], true},
+	}
+	for _, test := range tests {
+		if got := pointInRing(test.pt, test.ring); got != test.want {
+			t.Errorf("point %v in %v: %v, want %v", test.pt, test.ring, got, test.want)
+		}
+	}
+}
+
+// ringArea returns the signed area of GeoJSON positions, positive when they run counterclockwise.
+func ringArea(positions [][]float64) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(positions); i++ {
+		area += positions[i][0]*positions[i+1][1] - positions[i+1][0]*positions[i][1]
+	}
+	return area / 2
+}
+
+func TestPolygonToGeoJson(t *testing.T) {
+	got := polygon(square, hole).ToGeoJson()
+	want := GeoJsonPolygon{Type: "Polygon", Coordinates: [][][]float64{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{2, 2}, {2, 4}, {4, 4}, {4, 2}, {2, 2}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("shell with a hole written as %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		rings [][]Point
+		// Number of rings of each polygon
+		want []int
+	}{
+		{"shell", [][]Point{square}, []int{1}},
+		{"shell with a hole", [][]Point{square, hole}, []int{2}},
+		{"two islands, one with a hole", [][]Point{square, island, lagoon}, []int{1, 2}},
+		{"hole before its shell", [][]Point{hole, square}, []int{2}},
+		{"hole outside every shell", [][]Point{square, lagoon}, []int{1, 1}},
+	}
+	for _, test := range tests {
+		var polygons [][][][]float64
+		switch geometry := polygon(test.rings...).ToGeoJson().(type) {
+		case GeoJsonPolygon:
+			if len(test.want) != 1 {
+				t.Errorf("%s: written as a single polygon", test.name)
+				continue
+			}
+			polygons = [][][][]float64{geometry.Coordinates}
+		case GeoJsonMultiPolygon:
+			if len(test.want) == 1 {
+				t.Errorf("%s: written as a multipolygon", test.name)
+				continue
+			}
+			polygons = geometry.Coordinates
+		default:
+			t.Errorf("%s: written as %T", test.name, geometry)
+			continue
+		}
+
+		var rings []int
+		for i, p := range polygons {
+			// RFC 7946 has shells counterclockwise and holes clockwise.
+			for j, positions := range p {
+				if area := ringArea(positions); (j == 0) != (area > 0) {
+					t.Errorf("%s: ring %d of polygon %d has area %v", test.name, j, i, area)
+				}
+			}
+			rings = append(rings, len(p))
+		}
+		if !reflect.DeepEqual(rings, test.want) {
+			t.Errorf("%s: polygons of %v rings, want %v", test.name, rings, test.want)
+		}
+	}
+}
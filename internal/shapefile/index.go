@@ -0,0 +1,183 @@
+package shapefile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// IndexEntry locates a single record in the .shp file. Both values are counts of 16-bit words, the offset
+// points at the record header and the length excludes it, matching RecordHeader.Len.
+type IndexEntry struct {
+	Offset uint32
+	Len    uint32
+}
+
+// Reader gives random access to the records of a shapefile through its .shx index. Records are only read
+// from the .shp file when requested, so a single record can be pulled out of a large file cheaply.
+type Reader struct {
+	Header Header
+	shp    io.ReaderAt
+	shx    io.ReaderAt
+	count  int
+}
+
+func NewReader(shp, shx io.ReaderAt) (*Reader, error) {
+	reader := &Reader{shp: shp, shx: shx}
+	if err := reader.Header.Parse(io.NewSectionReader(shp, 0, 100)); err != nil {
+		return nil, err
+	}
+
+	var index Header
+	if err := index.Parse(io.NewSectionReader(shx, 0, 100)); err != nil {
+		return nil, err
+	}
+
+	// The index is a 100 byte header followed by one 8 byte entry per record.
+	length := int64(index.File.FileLength) * 2
+	if length < 100 || (length-100)%8 != 0 {
+		return nil, fmt.Errorf("invalid index file length %d", length)
+	}
+	reader.count = int((length - 100) / 8)
+
+	return reader, nil
+}
+
+// Len returns the number of records in the shapefile, as reported by the index.
+func (r *Reader) Len() int {
+	return r.count
+}
+
+// Entry returns the index entry of the record at position i. Positions are zero based, so position i
+// holds record number i+1.
+func (r *Reader) Entry(i int) (IndexEntry, error) {
+	entries, err := r.entries(i, i+1)
+	if err != nil {
+		return IndexEntry{}, err
+	}
+	return entries[0], nil
+}
+
+// Record reads the record at position i.
+func (r *Reader) Record(i int) (Record, error) {
+	records, err := r.Records(i, i+1)
+	if err != nil {
+		return Record{}, err
+	}
+	return records[0], nil
+}
+
+// Records reads the records at positions [start, end). Records are usually stored back to back, in
+// which case the whole range is fetched from the .shp file with a single read. Records that cannot be
+// parsed are reported as a ParseError.
+func (r *Reader) Records(start, end int) ([]Record, error) {
+	entries, err := r.entries(start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	// Entries are checked against the file length before anything is read, so a corrupt index cannot
+	// cause reads, or allocations, beyond the end of the file.
+	fileLength := int64(r.Header.File.FileLength) * 2
+	total := int64(0)
+	for i, entry := range entries {
+		offset := int64(entry.Offset) * 2
+		length := 8 + int64(entry.Len)*2
+		if offset < 100 || offset+length > fileLength {
+			return nil, &ParseError{
+				Record: start + i + 1,
+				Offset: offset,
+				Err:    fmt.Errorf("index entry of %d bytes lies outside of the file length of %d bytes", length, fileLength),
+			}
+		}
+		total += length
+	}
+
+	first := int64(entries[0].Offset) * 2
+	last := int64(entries[len(entries)-1].Offset)*2 + 8 + int64(entries[len(entries)-1].Len)*2
+
+	// The block is only read when it is mostly made of the requested records. When it cannot be read, the
+	// records are read one by one to find the one at fault.
+	var data []byte
+	if last > first && last-first <= 2*total {
+		data, _ = r.bytesAt(first, last-first)
+	}
+
+	records := make([]Record, len(entries))
+	for i, entry := range entries {
+		offset := int64(entry.Offset)*2 - first
+		length := 8 + int64(entry.Len)*2
+
+		// Records that are not stored in order fall outside of the block and are read separately.
+		var content []byte
+		if offset >= 0 && offset+length <= int64(len(data)) {
+			content = data[offset : offset+length]
+		} else {
+			content, err = r.bytesAt(int64(entry.Offset)*2, length)
+		}
+
+		if err == nil {
+			records[i], err = parseRecord(content, r.Header.Shape.Type)
+		}
+		if err == nil && records[i].Number != uint32(start+i+1) {
+			err = fmt.Errorf("index entry %d points at record %d", start+i+1, records[i].Number)
+		}
+		if err != nil {
+			return nil, &ParseError{Record: start + i + 1, Offset: int64(entry.Offset) * 2, Err: err}
+		}
+	}
+	return records, nil
+}
+
+func (r *Reader) entries(start, end int) ([]IndexEntry, error) {
+	if start < 0 || end > r.count || start > end {
+		return nil, fmt.Errorf("record range [%d, %d) out of bounds for %d records", start, end, r.count)
+	}
+
+	if start == end {
+		return nil, nil
+	}
+	// The count comes from the header of the index, so the last entry is read first to make sure the index
+	// really holds that many before allocating room for them.
+	if _, err := readAt(r.shx, 100+int64(end)*8-8, 8); err != nil {
+		return nil, fmt.Errorf("index ends before entry %d: %w", end, err)
+	}
+
+	entries := make([]IndexEntry, end-start)
+	section := io.NewSectionReader(r.shx, 100+int64(start)*8, int64(end-start)*8)
+	if err := binary.Read(section, binary.BigEndian, entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *Reader) bytesAt(offset, length int64) ([]byte, error) {
+	return readAt(r.shp, offset, length)
+}
+
+// readAt reads length bytes at offset. Lengths are checked against the file length given by its header, which
+// cannot be trusted either, so the last byte is read before allocating the whole length.
+func readAt(ra io.ReaderAt, offset, length int64) ([]byte, error) {
+	if length > 1<<16 {
+		var last [1]byte
+		if _, err := ra.ReadAt(last[:], offset+length-1); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+
+	data := make([]byte, length)
+	n, err := ra.ReadAt(data, offset)
+	if n == len(data) {
+		return data, nil
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, err
+}
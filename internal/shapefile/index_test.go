@@ -0,0 +1,103 @@
+package shapefile
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReaderRecords(t *testing.T) {
+	shapes := append(testShapes(PolygonType), testShapes(PolygonType)...)
+	shp, shx, _ := writeShapes(t, PolygonType, shapes)
+	parsed, err := Parse(bytes.NewReader(shp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewReader(bytes.NewReader(shp), bytes.NewReader(shx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.Len() != len(parsed.Records) {
+		t.Fatalf("index has %d records, shapefile %d", reader.Len(), len(parsed.Records))
+	}
+
+	// Every range reads the same records as parsing the whole file.
+	for start := 0; start <= reader.Len(); start++ {
+		for end := start; end <= reader.Len(); end++ {
+			records, err := reader.Records(start, end)
+			if err != nil {
+				t.Fatalf("records [%d, %d): %v", start, end, err)
+			}
+			if len(records) != end-start || (len(records) > 0 && !reflect.DeepEqual(records, parsed.Records[start:end])) {
+				t.Errorf("records [%d, %d) read as %+v, want %+v", start, end, records, parsed.Records[start:end])
+			}
+		}
+	}
+	for i, want := range parsed.Records {
+		if record, err := reader.Record(i); err != nil || !reflect.DeepEqual(record, want) {
+			t.Errorf("record %d read as %+v, %v, want %+v", i, record, err, want)
+		}
+	}
+
+	for _, bounds := range [][2]int{{-1, 2}, {2, reader.Len() + 1}, {4, 3}, {reader.Len(), reader.Len() + 1}} {
+		if records, err := reader.Records(bounds[0], bounds[1]); err == nil {
+			t.Errorf("records [%d, %d) of %d read as %+v", bounds[0], bounds[1], reader.Len(), records)
+		}
+	}
+	if _, err := reader.Record(reader.Len()); err == nil {
+		t.Errorf("read record %d of %d", reader.Len(), reader.Len())
+	}
+	if _, err := reader.Entry(-1); err == nil {
+		t.Error("read index entry -1")
+	}
+}
+
+func FuzzReaderRecords(f *testing.F) {
+	for _, st := range allShapeTypes {
+		shp, shx, _ := writeShapes(f, st, testShapes(st))
+		f.Add(shp, shx)
+	}
+
+	f.Fuzz(func(t *testing.T, shp, shx []byte) {
+		reader, err := NewReader(bytes.NewReader(shp), bytes.NewReader(shx))
+		if err != nil {
+			return
+		}
+
+		var records []Record
+		checkAllocations(t, len(shp)+len(shx), func() {
+			records, err = reader.Records(0, reader.Len())
+		})
+		if err == nil {
+			if len(records) != reader.Len() {
+				t.Fatalf("read %d records, index has %d", len(records), reader.Len())
+			}
+			for i, record := range records {
+				if record.Number != uint32(i+1) {
+					t.Fatalf("record %d numbered %d", i+1, record.Number)
+				}
+			}
+			return
+		}
+
+		var parseError *ParseError
+		if !errors.As(err, &parseError) {
+			// Only an index shorter than its header claims fails before any record is read.
+			if _, entryErr := reader.Entry(reader.Len() - 1); entryErr == nil {
+				t.Fatalf("got %v, want a ParseError", err)
+			}
+			return
+		}
+		if parseError.Record < 1 || parseError.Record > reader.Len() {
+			t.Fatalf("error at record %d of %d", parseError.Record, reader.Len())
+		}
+		entry, err := reader.Entry(parseError.Record - 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parseError.Offset != int64(entry.Offset)*2 {
+			t.Fatalf("error at offset %d, index entry %d is at %d", parseError.Offset, parseError.Record, int64(entry.Offset)*2)
+		}
+	})
+}
@@ -1,128 +1,241 @@
 package shapefile
 
 import (
+	"bytes"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
 	"slices"
-	"strconv"
 
-	"github.com/nilptrderef/gogeo/internal/common"
 	. "github.com/nilptrderef/gogeo/internal/common"
 	"github.com/nilptrderef/gogeo/internal/dbase"
+	"github.com/nilptrderef/gogeo/internal/simplification"
 )
 
 type Shapefile struct {
 	Header  Header
 	Records []Record
+	// Fields of the dbase file the attributes were loaded from, used when writing them back out.
+	Fields []dbase.FieldDescriptor
+	// Overrides the code page declared by the dbase file when set, usually read from the .cpg file.
+	CodePage *dbase.CodePage
+	// Memo file of the dbase file, its .dbt or .fpt file, read along with the attributes when set.
+	Memo io.ReaderAt
 }
 
 func Parse(r io.Reader) (*Shapefile, error) {
-	shp := &Shapefile{}
-	if err := shp.Header.Parse(r); err != nil {
+	stream, err := NewStream(r, nil)
+	if err != nil {
 		return nil, err
 	}
 
-	for {
-		var rh RecordHeader
-		err := binary.Read(r, binary.BigEndian, &rh)
-		if err == io.EOF {
-			break
-		}
+	shp := &Shapefile{Header: stream.Header}
+	for record, err := range stream.Records() {
 		if err != nil {
 			return nil, err
 		}
+		shp.Records = append(shp.Records, record)
+	}
+	return shp, nil
+}
 
-		var st ShapeType
-		if err := binary.Read(r, binary.LittleEndian, &st); err != nil {
-			return nil, err
-		}
+// parseRecord parses a record header followed by its content. Records have to be null or of the shape type
+// of the file.
+func parseRecord(data []byte, st ShapeType) (Record, error) {
+	if len(data) < 8 {
+		return Record{}, io.ErrUnexpectedEOF
+	}
 
-		switch st {
-		case PolygonType:
-			poly := &Polygon{}
-			if err := poly.Parse(r); err != nil {
-				return nil, err
-			}
-			shp.Records = append(shp.Records, Record{Polygon: poly})
-		default:
-			// Discard remaining bytes in record. Total bytes = rh.Len * 2.
-			// We read 4 bytes (ShapeType) already.
-			if _, err := io.CopyN(io.Discard, r, int64(rh.Len*2-4)); err != nil {
-				return nil, err
-			}
+	var rh RecordHeader
+	rh.Index = binary.BigEndian.Uint32(data[0:4])
+	rh.Len = binary.BigEndian.Uint32(data[4:8])
+	if int64(rh.Len)*2 != int64(len(data)-8) {
+		return Record{}, fmt.Errorf("record %d has length %d but %d bytes are available", rh.Index, int64(rh.Len)*2, len(data)-8)
+	}
+	return parseContent(rh, data[8:], st)
+}
+
+// parseContent parses the content of the record described by rh.
+func parseContent(rh RecordHeader, content []byte, st ShapeType) (Record, error) {
+	shape, err := ParseShape(content)
+	if err != nil {
+		return Record{}, err
+	}
+	if shape.GetType() != Null && shape.GetType() != st {
+		return Record{}, fmt.Errorf("%s record in a %s shapefile", shape.GetType(), st)
+	}
+	return Record{Number: rh.Index, Geometry: shape}, nil
+}
+
+// ParseShape parses the content of a single record, starting at its shape type. The content has to hold
+// the shape exactly, counts that do not fit the content and bytes left over are both reported as errors.
+func ParseShape(content []byte) (Shape, error) {
+	r := bytes.NewReader(content)
+
+	var st ShapeType
+	if err := binary.Read(r, binary.LittleEndian, &st); err != nil {
+		return nil, err
+	}
+
+	shape, err := NewShape(st)
+	if err != nil {
+		return nil, err
+	}
+	if err := shape.Parse(r); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
+		return nil, fmt.Errorf("%s record: %w", st, err)
 	}
-	return shp, nil
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%s record has %d bytes left over", st, r.Len())
+	}
+	return shape, nil
 }
 
+// LoadAttributes reads the rows of the dbase file into the attributes of the matching records. Records whose
+// row is marked as deleted keep their geometry but are left without attributes.
 func (s *Shapefile) LoadAttributes(r io.Reader) error {
-	db, err := dbase.Parse(r)
+	rows, err := dbase.NewReader(r)
 	if err != nil {
 		return err
 	}
 
-	for i := 0; i < int(db.Header.RecordCount); i++ {
-		if i >= len(s.Records) {
-			break
+	// Hidden system fields are not part of the attributes, so they are not written back out either.
+	s.Fields = slices.DeleteFunc(slices.Clone(rows.Fields), func(field dbase.FieldDescriptor) bool {
+		return field.Flags&dbase.SystemField != 0
+	})
+	if s.CodePage != nil {
+		rows.CodePage = s.CodePage
+	}
+	if s.Memo != nil && rows.Header.HasMemo() {
+		if rows.Memo, err = dbase.NewMemoFile(s.Memo, &rows.Header); err != nil {
+			return err
 		}
-		record := &s.Records[i]
-		if record.Attrs == nil {
-			record.Attrs = make(map[string]string)
+	}
+	if int(rows.Header.RecordCount) != len(s.Records) {
+		return fmt.Errorf("shapefile has %d records but dbase file has %d", len(s.Records), rows.Header.RecordCount)
+	}
+
+	// Rows in the dbase file are matched to records by record number rather than by position.
+	byNumber := make(map[uint32]*Record, len(s.Records))
+	for i := range s.Records {
+		number := s.Records[i].Number
+		if _, found := byNumber[number]; found {
+			return fmt.Errorf("duplicate shapefile record number %d", number)
 		}
+		byNumber[number] = &s.Records[i]
+	}
 
-		var delFlag uint8
-		if err := binary.Read(r, binary.LittleEndian, &delFlag); err != nil {
+	rows.IncludeDeleted = true
+	for row, err := range rows.Records() {
+		if err != nil {
 			return err
 		}
 
-		for _, field := range db.Fields {
-			val, err := field.Read(r)
-			if err != nil {
-				return err
+		record, found := byNumber[uint32(row.Number)]
+		if !found {
+			return fmt.Errorf("dbase row %d has no matching shapefile record", row.Number)
+		}
+		if !row.Deleted {
+			record.Attrs = row.Values
+		}
+	}
+	return nil
+}
+
+// WriteAttributes writes the attributes of every record to a dbase file, using the fields they were loaded with.
+// Without fields, such as for attributes that were computed rather than loaded, the fields are inferred from
+// the attributes and stored in Fields.
+func (s *Shapefile) WriteAttributes(dbf io.WriteSeeker) error {
+	if s.Fields == nil {
+		if err := s.inferFields(); err != nil {
+			return err
+		}
+	}
+
+	w, err := dbase.NewWriter(dbf, s.Fields)
+	if err != nil {
+		return err
+	}
+	for _, record := range s.Records {
+		if err := w.Write(record.Attrs); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// inferFields picks the fields of the attributes of every record, in alphabetical order.
+func (s *Shapefile) inferFields() error {
+	var columns []string
+	rows := make([]map[string]any, len(s.Records))
+	for i, record := range s.Records {
+		for name := range record.Attrs {
+			if !slices.Contains(columns, name) {
+				columns = append(columns, name)
 			}
-			record.Attrs[field.GetName()] = val
 		}
+		rows[i] = record.Attrs
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("shapefile has no attributes to write")
+	}
+	slices.Sort(columns)
+
+	fields, err := dbase.Descriptors(dbase.InferSchema(columns, rows))
+	if err != nil {
+		return err
 	}
+	s.Fields = fields
 	return nil
 }
 
+var (
+	conus  = AlbersConstant(AlbersParams{Phi1: 29.5, Phi2: 45.5, Phi0: 23, Lam0: -96})
+	alaska = AlbersConstant(AlbersParams{Phi1: 55, Phi2: 65, Phi0: 50, Lam0: -154})
+	hawaii = AlbersConstant(AlbersParams{Phi1: 8, Phi2: 18, Phi0: 13, Lam0: -157})
+)
+
+// ProjectPoint projects a longitude/latitude point with the Albers projection of its state. Alaska and
+// Hawaii are scaled and moved below the contiguous states.
+func ProjectPoint(statefp string, pt Point) Point {
+	var projected Point
+	if statefp == "02" { // Alaska
+		lon := pt.X
+		if lon > 0 {
+			lon -= 360
+		}
+		projected = Albers(pt.Y, lon, alaska)
+		projected.X = (projected.X * 0.35) - 2100
+		projected.Y = (projected.Y * 0.35) + 50
+	} else if statefp == "15" { // Hawaii
+		projected = Albers(pt.Y, pt.X, hawaii)
+		projected.X = (projected.X * 0.4) - 600
+		projected.Y = (projected.Y * 0.4) - 250
+	} else {
+		projected = Albers(pt.Y, pt.X, conus)
+	}
+	return projected
+}
+
 func (s *Shapefile) Project() {
 	s.Header.Shape.Mbr.Start.X = math.MaxFloat64
 	s.Header.Shape.Mbr.Start.Y = math.MaxFloat64
 	s.Header.Shape.Mbr.End.X = -math.MaxFloat64
 	s.Header.Shape.Mbr.End.Y = -math.MaxFloat64
 
-	conus := common.AlbersConstant(common.AlbersParams{Phi1: 29.5, Phi2: 45.5, Phi0: 23, Lam0: -96})
-	alaska := common.AlbersConstant(common.AlbersParams{Phi1: 55, Phi2: 65, Phi0: 50, Lam0: -154})
-	hawaii := common.AlbersConstant(common.AlbersParams{Phi1: 8, Phi2: 18, Phi0: 13, Lam0: -157})
-
-	for i, record := range s.Records {
-		statefp := record.Attrs["STATEFP"]
-		for j, pt := range record.Polygon.Points {
-			var projected common.Point
-			if statefp == "02" { // Alaska
-				lon := pt.X
-				if lon > 0 {
-					lon -= 360
-				}
-				projected = Albers(pt.Y, lon, alaska)
-				projected.X = (projected.X * 0.35) - 2100
-				projected.Y = (projected.Y * 0.35) + 50
-			} else if statefp == "15" { // Hawaii
-				projected = Albers(pt.Y, pt.X, hawaii)
-				projected.X = (projected.X * 0.4) - 600
-				projected.Y = (projected.Y * 0.4) - 250
-			} else {
-				projected = Albers(pt.Y, pt.X, conus)
-			}
-			s.Records[i].Polygon.Points[j] = projected
-
+	for _, record := range s.Records {
+		statefp := record.Attrs.String("STATEFP")
+		record.Geometry.Transform(func(pt Point) Point {
+			projected := ProjectPoint(statefp, pt)
 			s.Header.Shape.Mbr.Start.X = min(s.Header.Shape.Mbr.Start.X, projected.X)
 			s.Header.Shape.Mbr.End.X = max(s.Header.Shape.Mbr.End.X, projected.X)
 			s.Header.Shape.Mbr.Start.Y = min(s.Header.Shape.Mbr.Start.Y, projected.Y)
 			s.Header.Shape.Mbr.End.Y = max(s.Header.Shape.Mbr.End.Y, projected.Y)
-		}
+			return projected
+		})
 	}
 }
 
@@ -133,11 +246,7 @@ func (s *Shapefile) ToGeoJson() GeoJson {
 	}
 
 	for i, record := range s.Records {
-		geojson.Features[i] = GeoJsonFeature{
-			Type:       "Feature",
-			Properties: record.Attrs,
-			Geometry:   record.Polygon.ToGeoJsonPolygon(),
-		}
+		geojson.Features[i] = record.ToGeoJsonFeature()
 	}
 
 	return geojson
@@ -148,35 +257,9 @@ func (s *Shapefile) ToMap() Map {
 	m.Mbr = s.Header.Shape.Mbr
 
 	for _, record := range s.Records {
-		var county County
-		county.Mbr.Start.X = math.MaxFloat64
-		county.Mbr.Start.Y = math.MaxFloat64
-		county.Mbr.End.X = -math.MaxFloat64
-		county.Mbr.End.Y = -math.MaxFloat64
-
-		county.Id = record.Attrs["GEOID"]
-		county.Name = record.Attrs["NAMELSAD"]
-		county.State = StateAbbrFips[record.Attrs["STATEFP"]]
-
-		lat, _ := strconv.ParseFloat(record.Attrs["INTPTLAT"], 32)
-		county.InternalLat = float32(lat)
-		lon, _ := strconv.ParseFloat(record.Attrs["INTPTLON"], 32)
-		county.InternalLon = float32(lon)
-
-		current := -1
-		for i, pt := range record.Polygon.Points {
-			if slices.Contains(record.Polygon.Parts, uint32(i)) {
-				county.Parts = append(county.Parts, nil)
-				current = len(county.Parts) - 1
-			}
-
-			county.Parts[current] = append(county.Parts[current], pt.X, pt.Y)
-			county.Mbr.Start.X = min(county.Mbr.Start.X, pt.X)
-			county.Mbr.End.X = max(county.Mbr.End.X, pt.X)
-			county.Mbr.Start.Y = min(county.Mbr.Start.Y, pt.Y)
-			county.Mbr.End.Y = max(county.Mbr.End.Y, pt.Y)
+		if county, ok := record.ToCounty(); ok {
+			m.Counties = append(m.Counties, county)
 		}
-		m.Counties = append(m.Counties, county)
 	}
 
 	return m
@@ -221,19 +304,96 @@ const (
 	MultiPatch  ShapeType = 31
 )
 
+// HasZ reports whether records of this type carry Z values. MultiPatch is always three dimensional.
+func (st ShapeType) HasZ() bool {
+	switch st {
+	case PointZ, PolylineZ, PolygonZ, MultiPointZ, MultiPatch:
+		return true
+	}
+	return false
+}
+
+// HasM reports whether records of this type may carry measures. Every Z type may also carry measures.
+func (st ShapeType) HasM() bool {
+	switch st {
+	case PointM, PolylineM, PolygonM, MultiPointM:
+		return true
+	}
+	return st.HasZ()
+}
+
+func (st ShapeType) String() string {
+	switch st {
+	case Null:
+		return "Null"
+	case PointType:
+		return "Point"
+	case Polyline:
+		return "Polyline"
+	case PolygonType:
+		return "Polygon"
+	case MultiPoint:
+		return "MultiPoint"
+	case PointZ:
+		return "PointZ"
+	case PolylineZ:
+		return "PolylineZ"
+	case PolygonZ:
+		return "PolygonZ"
+	case MultiPointZ:
+		return "MultiPointZ"
+	case PointM:
+		return "PointM"
+	case PolylineM:
+		return "PolylineM"
+	case PolygonM:
+		return "PolygonM"
+	case MultiPointM:
+		return "MultiPointM"
+	case MultiPatch:
+		return "MultiPatch"
+	default:
+		return fmt.Sprintf("ShapeType(%d)", uint32(st))
+	}
+}
+
+// fileCode is the magic number every .shp and .shx file starts with.
+const fileCode = 9994
+
+// Parse reads the header of a .shp or .shx file, checking the file code and that the file length leaves
+// room for the header itself.
 func (h *Header) Parse(r io.Reader) error {
 	if err := binary.Read(r, binary.BigEndian, &h.File); err != nil {
 		return err
 	}
+	if h.File.FileCode != fileCode {
+		return fmt.Errorf("not a shapefile, file code is %d instead of %d", h.File.FileCode, fileCode)
+	}
+	if h.File.FileLength < 50 {
+		return fmt.Errorf("file length of %d bytes is shorter than the header", int64(h.File.FileLength)*2)
+	}
 	if err := binary.Read(r, binary.LittleEndian, &h.Shape); err != nil {
 		return err
 	}
 	return nil
 }
 
+func (h *Header) Write(w io.Writer) error {
+	if err := binary.Write(w, binary.BigEndian, h.File); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, h.Shape); err != nil {
+		return err
+	}
+	return nil
+}
+
 type Record struct {
-	Polygon *Polygon
-	Attrs   map[string]string
+	// One based record number taken from the record header. Record N is described by row N of the dbase file.
+	Number uint32
+	// Null records are kept as a NullShape so that record numbers stay aligned with the dbase file.
+	Geometry Shape
+	Attrs    Properties
 }
 
 type RecordHeader struct {
@@ -242,64 +402,120 @@ type RecordHeader struct {
 	Len uint32
 }
 
-type Polygon struct {
-	Header PolygonHeader
-	Parts  []uint32
-	Points []Point
+// Project projects the geometry of the record in place, see ProjectPoint.
+func (r *Record) Project() {
+	statefp := r.Attrs.String("STATEFP")
+	r.Geometry.Transform(func(pt Point) Point {
+		return ProjectPoint(statefp, pt)
+	})
 }
 
-type PolygonHeader struct {
-	Mbr        Rectangle
-	PartCount  uint32
-	PointCount uint32
+// SimplifyInPlace simplifies every ring of a polygon record and every line of a polyline record, whose ends
+// are kept. Z and M values of the remaining points are kept. Records of any other shape type are left
+// untouched.
+func (r *Record) SimplifyInPlace(simplifier simplification.Simplifier, percentage float64) error {
+	_, err := r.SimplifyValidated(simplifier, percentage, simplification.NoValidation)
+	return err
 }
 
-func (p *Polygon) Parse(r io.Reader) error {
-	if err := binary.Read(r, binary.LittleEndian, &p.Header); err != nil {
-		return err
+// SimplifyValidated simplifies the record like SimplifyInPlace, then checks or repairs all of its rings
+// together, see simplification.Validate. valid is false when rings cross themselves or each other. Lines are
+// free to cross and are not checked.
+func (r *Record) SimplifyValidated(simplifier simplification.Simplifier, percentage float64, validation simplification.Validation) (valid bool, err error) {
+	if simplifier == nil {
+		return true, nil
 	}
 
-	p.Parts = make([]uint32, p.Header.PartCount)
-	if err := binary.Read(r, binary.LittleEndian, &p.Parts); err != nil {
-		return err
-	}
+	switch shape := r.Geometry.(type) {
+	case *PolylineShape:
+		lines := shape.coordinates()
+		for i := range lines {
+			lines[i], err = simplifier.SimplifyLine(lines[i], percentage)
+			if err != nil {
+				return false, err
+			}
+		}
+		shape.Multipart = shape.subset(lines)
+		return true, nil
+
+	case *Polygon:
+		original := shape.coordinates()
+		rings := make([][]float64, len(original))
+		for i := range original {
+			rings[i], err = simplifier.Simplify(original[i], percentage)
+			if err != nil {
+				return false, err
+			}
+		}
+		rings, valid = simplification.Validate(original, rings, validation)
+
+		// Rings below the minimum area of the simplifier come back empty, and the holes of an outer ring go
+		// along with it.
+		for _, group := range shape.Rings() {
+			if len(rings[group[0]]) == 0 {
+				for _, part := range group {
+					rings[part] = nil
+				}
+			}
+		}
 
-	p.Points = make([]Point, p.Header.PointCount)
-	if err := binary.Read(r, binary.LittleEndian, &p.Points); err != nil {
-		return err
+		simplified := shape.subset(rings)
+		if len(simplified.Points) == 0 {
+			// Every ring was dropped, which leaves nothing but the attributes.
+			r.Geometry = &NullShape{}
+			return valid, nil
+		}
+		shape.Multipart = simplified
+		return valid, nil
 	}
+	return true, nil
+}
 
+// Coordinates returns the X and Y values of every ring of a polygon record or line of a polyline record as flat
+// coordinates [x, y, x, y, ...]. Records of any other shape type have none.
+func (r *Record) Coordinates() [][]float64 {
+	switch shape := r.Geometry.(type) {
+	case *PolylineShape:
+		return shape.coordinates()
+	case *Polygon:
+		return shape.coordinates()
+	}
 	return nil
 }
 
-func (p *Polygon) ToGeoJsonPolygon() GeoJsonPolygon {
-	out := GeoJsonPolygon{
-		Type:        "Polygon",
-		Coordinates: [][][]float64{},
+func (r *Record) ToGeoJsonFeature() GeoJsonFeature {
+	return GeoJsonFeature{
+		Type:       "Feature",
+		Properties: r.Attrs,
+		Geometry:   r.Geometry.ToGeoJson(),
 	}
-	var currentPart []([]float64)
+}
 
-	// Helper to check if index is a part start
-	isPart := func(idx int) bool {
-		for _, partIdx := range p.Parts {
-			if int(partIdx) == idx {
-				return true
-			}
-		}
-		return false
+// ToCounty converts a polygon record into a County. Records of any other shape type are reported as not ok.
+func (r *Record) ToCounty() (County, bool) {
+	polygon, ok := r.Geometry.(*Polygon)
+	if !ok {
+		return County{}, false
 	}
 
-	for i, pt := range p.Points {
-		if i != 0 && isPart(i) {
-			out.Coordinates = append(out.Coordinates, currentPart)
-			currentPart = []([]float64){}
+	county := NewCounty(r.Attrs)
+
+	for _, group := range polygon.Rings() {
+		rings := make(Rings, 0, len(group))
+		for _, i := range group {
+			start, end := polygon.PartBounds(i)
+			coordinates := make(Coordinates, 0, (end-start)*2)
+			for _, pt := range polygon.Points[start:end] {
+				coordinates = append(coordinates, pt.X, pt.Y)
+				county.Mbr.Start.X = min(county.Mbr.Start.X, pt.X)
+				county.Mbr.End.X = max(county.Mbr.End.X, pt.X)
+				county.Mbr.Start.Y = min(county.Mbr.Start.Y, pt.Y)
+				county.Mbr.End.Y = max(county.Mbr.End.Y, pt.Y)
+			}
+			rings = append(rings, coordinates)
 		}
-		// Rounding can be applied here if needed, but standard float64 used
-		currentPart = append(currentPart, []float64{pt.X, pt.Y})
-	}
-	if len(currentPart) > 0 {
-		out.Coordinates = append(out.Coordinates, currentPart)
+		county.Parts = append(county.Parts, rings)
 	}
 
-	return out
+	return county, true
 }
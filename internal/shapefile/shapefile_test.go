@@ -0,0 +1,256 @@
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nilptrderef/gogeo/internal/dbase"
+)
+
+// shapeContent encodes a shape as the content of a record, starting at its shape type.
+func shapeContent(t testing.TB, shape Shape) []byte {
+	t.Helper()
+	var content bytes.Buffer
+	binary.Write(&content, binary.LittleEndian, shape.GetType())
+	if err := shape.Write(&content); err != nil {
+		t.Fatal(err)
+	}
+	return content.Bytes()
+}
+
+// writeRows writes a dbase file holding a NAME column with a row for each name, marking the rows at the
+// deleted positions as deleted.
+func writeRows(t testing.TB, names []string, deleted ...int) []byte {
+	t.Helper()
+	rows := make([]map[string]any, len(names))
+	for i, name := range names {
+		rows[i] = map[string]any{"NAME": name}
+	}
+	fields, err := dbase.Descriptors(dbase.InferSchema([]string{"NAME"}, rows))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dbf seekBuffer
+	w, err := dbase.NewWriter(&dbf, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range deleted {
+		dbf.data[int(w.Header.HeaderLength)+i*int(w.Header.RecordLength)] = '*'
+	}
+	return dbf.data
+}
+
+// checkAllocations fails when fn allocates more than a small multiple of the size of its input, which only
+// happens when counts or lengths read from the input are trusted before checking them.
+func checkAllocations(t *testing.T, input int, fn func()) {
+	t.Helper()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	if allocated, limit := after.TotalAlloc-before.TotalAlloc, uint64(1<<20+64*input); allocated > limit {
+		t.Fatalf("allocated %d bytes for %d bytes of input", allocated, input)
+	}
+}
+
+func FuzzParseShape(f *testing.F) {
+	for _, st := range allShapeTypes {
+		for _, shape := range testShapes(st) {
+			f.Add(shapeContent(f, shape))
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		var shape Shape
+		var err error
+		checkAllocations(t, len(content), func() {
+			shape, err = ParseShape(content)
+		})
+		if err != nil {
+			return
+		}
+
+		points, _, _ := shapeValues(shape)
+		if len(points)*16 > len(content) {
+			t.Fatalf("%d points parsed from %d bytes", len(points), len(content))
+		}
+		var multipart *Multipart
+		switch s := shape.(type) {
+		case *PolylineShape:
+			multipart = &s.Multipart
+		case *Polygon:
+			multipart = &s.Multipart
+		case *MultiPatchShape:
+			multipart = &s.Multipart
+		}
+		if multipart != nil {
+			if len(multipart.Parts)*4 > len(content) {
+				t.Fatalf("%d parts parsed from %d bytes", len(multipart.Parts), len(content))
+			}
+			if err := checkParts(multipart.Parts, len(points)); err != nil {
+				t.Fatalf("parsed invalid parts: %v", err)
+			}
+		}
+
+		// Whatever was accepted can be written back and parsed again.
+		if _, err := ParseShape(shapeContent(t, shape)); err != nil {
+			t.Fatalf("written shape cannot be parsed back: %v", err)
+		}
+	})
+}
+
+func TestParseShapeRejectsLeftovers(t *testing.T) {
+	content := shapeContent(t, testShapes(PolygonType)[0])
+	if _, err := ParseShape(append(content, 0, 0, 0, 0)); err == nil || !strings.Contains(err.Error(), "left over") {
+		t.Errorf("got %v, want bytes left over", err)
+	}
+	if _, err := ParseShape(content[:len(content)-1]); err == nil {
+		t.Error("parsed a truncated record")
+	}
+}
+
+// TestCorruptRecords corrupts the third record of a polygon shapefile, after a polygon and a null record, and
+// expects both the stream and the indexed reader to report it with its number and offset.
+func TestCorruptRecords(t *testing.T) {
+	shapes := testShapes(PolygonType)
+	offset := 100
+	for _, shape := range shapes[:2] {
+		offset += 8 + 4 + shape.ContentLength()
+	}
+	// Offsets of the counts and the first part within the content of a polygon record
+	const partCount, pointCount, firstPart = 8 + 36, 8 + 40, 8 + 44
+
+	tests := []struct {
+		name    string
+		corrupt func(shp, shx []byte)
+		// Errors expected from the stream and from the indexed reader
+		stream, reader string
+	}{
+		{"point count", func(shp, shx []byte) {
+			binary.LittleEndian.PutUint32(shp[offset+pointCount:], 1<<31-1)
+		}, "need", "need"},
+		{"part count", func(shp, shx []byte) {
+			binary.LittleEndian.PutUint32(shp[offset+partCount:], 1<<30)
+		}, "need", "need"},
+		{"first part", func(shp, shx []byte) {
+			binary.LittleEndian.PutUint32(shp[offset+firstPart:], 3)
+		}, "first part starts at point 3", "first part starts at point 3"},
+		{"point count too small", func(shp, shx []byte) {
+			binary.LittleEndian.PutUint32(shp[offset+pointCount:], 1)
+		}, "left over", "left over"},
+		{"content length", func(shp, shx []byte) {
+			binary.BigEndian.PutUint32(shp[offset+4:], 1<<31-1)
+			binary.BigEndian.PutUint32(shx[100+2*8+4:], 1<<31-1)
+		}, "runs past the file length", "outside of the file length"},
+		{"shape type", func(shp, shx []byte) {
+			binary.LittleEndian.PutUint32(shp[offset+8:], uint32(Polyline))
+		}, "Polyline record in a Polygon shapefile", "Polyline record in a Polygon shapefile"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shp, shx, _ := writeShapes(t, PolygonType, testShapes(PolygonType))
+			test.corrupt(shp, shx)
+
+			check := func(source string, err error, want string) {
+				t.Helper()
+				var parseError *ParseError
+				if !errors.As(err, &parseError) {
+					t.Fatalf("%s: got %v, want a ParseError", source, err)
+				}
+				if parseError.Record != 3 || parseError.Offset != int64(offset) {
+					t.Errorf("%s: error at record %d offset %d, want record 3 offset %d", source, parseError.Record, parseError.Offset, offset)
+				}
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("%s: got %v, want %q", source, err, want)
+				}
+			}
+
+			stream, err := NewStream(bytes.NewReader(shp), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read := 0
+			for _, err := range stream.Records() {
+				if err != nil {
+					check("stream", err, test.stream)
+					break
+				}
+				read++
+			}
+			if read != 2 {
+				t.Errorf("stream read %d records before the corrupt one, want 2", read)
+			}
+
+			reader, err := NewReader(bytes.NewReader(shp), bytes.NewReader(shx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = reader.Records(0, reader.Len())
+			check("reader", err, test.reader)
+			if _, err := reader.Record(1); err != nil {
+				t.Errorf("record before the corrupt one: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadAttributes(t *testing.T) {
+	// Records are matched to rows by number, whatever their order in the shapefile.
+	shp := &Shapefile{Records: []Record{
+		{Number: 2, Geometry: &PointShape{Type: PointType}},
+		{Number: 1, Geometry: &NullShape{}},
+		{Number: 4, Geometry: &PointShape{Type: PointType}},
+		{Number: 3, Geometry: &PointShape{Type: PointType}},
+	}}
+	if err := shp.LoadAttributes(bytes.NewReader(writeRows(t, []string{"one", "two", "three", "four"}, 2))); err != nil {
+		t.Fatal(err)
+	}
+	// The deleted row leaves its record in place without attributes.
+	want := []string{"two", "one", "four", ""}
+	if len(shp.Records) != len(want) {
+		t.Fatalf("%d records after loading attributes, want %d", len(shp.Records), len(want))
+	}
+	for i, record := range shp.Records {
+		if got := record.Attrs.String("NAME"); got != want[i] {
+			t.Errorf("record %d named %q, want %q", record.Number, got, want[i])
+		}
+	}
+	if shp.Records[3].Attrs != nil {
+		t.Errorf("deleted row loaded as %v", shp.Records[3].Attrs)
+	}
+
+	tests := []struct {
+		name    string
+		numbers []uint32
+		rows    int
+	}{
+		{"fewer rows", []uint32{1, 2, 3}, 2},
+		{"more rows", []uint32{1, 2}, 3},
+		{"duplicate number", []uint32{1, 1, 2}, 3},
+		{"unmatched row", []uint32{1, 2, 4}, 3},
+	}
+	for _, test := range tests {
+		shp := &Shapefile{}
+		for _, number := range test.numbers {
+			shp.Records = append(shp.Records, Record{Number: number, Geometry: &NullShape{}})
+		}
+		names := strings.Fields(strings.Repeat("row ", test.rows))
+		if err := shp.LoadAttributes(bytes.NewReader(writeRows(t, names))); err == nil {
+			t.Errorf("%s: loaded %d rows onto records %v", test.name, test.rows, test.numbers)
+		}
+	}
+}
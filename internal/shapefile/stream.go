@@ -0,0 +1,146 @@
+package shapefile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/nilptrderef/gogeo/internal/dbase"
+)
+
+// Stream reads a shapefile and, optionally, its dbase file in lockstep so that records can be processed one
+// at a time without holding the whole file in memory.
+type Stream struct {
+	Header Header
+	Dbase  *dbase.Dbase
+	// Number of records skipped so far by Records because their dbase row is marked as deleted
+	Deleted int
+
+	shp  io.Reader
+	rows *dbase.Reader
+}
+
+// NewStream reads the headers of the shapefile and of the dbase file. The dbase file may be nil, in which
+// case records are returned without attributes.
+func NewStream(shp io.Reader, dbf io.Reader) (*Stream, error) {
+	s := &Stream{shp: shp}
+	if err := s.Header.Parse(shp); err != nil {
+		return nil, err
+	}
+
+	if dbf != nil {
+		rows, err := dbase.NewReader(dbf)
+		if err != nil {
+			return nil, err
+		}
+		s.Dbase = rows.Dbase
+		s.rows = rows
+	}
+	return s, nil
+}
+
+// Records returns an iterator over the remaining records of the stream. Iteration stops after the first
+// error, which is yielded alongside an empty record. Records that cannot be parsed, or that do not line up
+// with the rows of the dbase file, are reported as a ParseError. Records whose dbase row is marked as deleted
+// are skipped and counted in Deleted.
+func (s *Stream) Records() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		rows := 0
+		offset := int64(100)
+		end := int64(s.Header.File.FileLength) * 2
+		for number := 1; offset < end; number++ {
+			start := offset
+			fail := func(err error) {
+				yield(Record{}, &ParseError{Record: number, Offset: start, Err: err})
+			}
+
+			var rh RecordHeader
+			if err := binary.Read(s.shp, binary.BigEndian, &rh); err != nil {
+				if err == io.EOF {
+					err = fmt.Errorf("file ends before the length of %d bytes given by its header: %w", end, io.ErrUnexpectedEOF)
+				}
+				fail(err)
+				return
+			}
+
+			length := int64(rh.Len) * 2
+			if offset+8+length > end {
+				fail(fmt.Errorf("record length of %d bytes runs past the file length of %d bytes", length, end))
+				return
+			}
+			content, err := readContent(s.shp, length)
+			if err != nil {
+				fail(err)
+				return
+			}
+
+			record, err := parseContent(rh, content, s.Header.Shape.Type)
+			if err != nil {
+				fail(err)
+				return
+			}
+			offset += 8 + length
+
+			if s.rows != nil {
+				// Both files are read front to back, so record N has to be followed by record N+1.
+				row, err := s.rows.Read()
+				if err == io.EOF {
+					fail(fmt.Errorf("shapefile has more records than the %d in the dbase file", s.Dbase.Header.RecordCount))
+					return
+				}
+				if err != nil {
+					fail(err)
+					return
+				}
+				rows++
+				if rh.Index != uint32(row.Number) {
+					fail(fmt.Errorf("shapefile record %d found where record %d was expected", rh.Index, row.Number))
+					return
+				}
+				if row.Deleted {
+					s.Deleted++
+					continue
+				}
+				record.Attrs = row.Values
+			}
+
+			if !yield(record, nil) {
+				return
+			}
+		}
+
+		// The missing record would have followed the last one.
+		if s.rows != nil && rows != int(s.Dbase.Header.RecordCount) {
+			yield(Record{}, &ParseError{
+				Record: rows + 1,
+				Offset: offset,
+				Err:    fmt.Errorf("shapefile has %d records but dbase file has %d", rows, s.Dbase.Header.RecordCount),
+			})
+		}
+	}
+}
+
+// readContent reads length bytes of record content. Large records are read in chunks so that a corrupt
+// length in a truncated file fails once the data runs out rather than allocating the whole length upfront.
+func readContent(r io.Reader, length int64) ([]byte, error) {
+	if length <= 1<<16 {
+		content := make([]byte, length)
+		if _, err := io.ReadFull(r, content); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return content, nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(r, length))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) != length {
+		return nil, fmt.Errorf("record content ends after %d of %d bytes: %w", len(content), length, io.ErrUnexpectedEOF)
+	}
+	return content, nil
+}
@@ -0,0 +1,111 @@
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStreamRecords(t *testing.T) {
+	shapes := append(testShapes(PolygonType), testShapes(PolygonType)...)
+	shp, _, _ := writeShapes(t, PolygonType, shapes)
+	names := []string{"one", "two", "three", "four", "five", "six"}
+
+	// Offsets of every record header, and of the end of the file.
+	offsets := []int64{100}
+	for _, shape := range shapes {
+		offsets = append(offsets, offsets[len(offsets)-1]+8+4+int64(shape.ContentLength()))
+	}
+
+	tests := []struct {
+		name    string
+		rows    []string
+		deleted []int
+		// Record numbers read, along with the names of their rows
+		numbers []uint32
+		// Record number and offset of the error, when there is one
+		errRecord int
+		errOffset int64
+	}{
+		{name: "matching", rows: names, numbers: []uint32{1, 2, 3, 4, 5, 6}},
+		{name: "deleted", rows: names, deleted: []int{1, 2, 5}, numbers: []uint32{1, 4, 5}},
+		{name: "short", rows: names[:4], numbers: []uint32{1, 2, 3, 4}, errRecord: 5, errOffset: offsets[4]},
+		{name: "long", rows: append(names, "seven"), numbers: []uint32{1, 2, 3, 4, 5, 6}, errRecord: 7, errOffset: offsets[6]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stream, err := NewStream(bytes.NewReader(shp), bytes.NewReader(writeRows(t, test.rows, test.deleted...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var numbers []uint32
+			var streamErr error
+			for record, err := range stream.Records() {
+				if err != nil {
+					streamErr = err
+					break
+				}
+				numbers = append(numbers, record.Number)
+				if name := record.Attrs.String("NAME"); name != names[record.Number-1] {
+					t.Errorf("record %d has the attributes of %q", record.Number, name)
+				}
+			}
+			if fmt.Sprint(numbers) != fmt.Sprint(test.numbers) {
+				t.Errorf("read records %v, want %v", numbers, test.numbers)
+			}
+			if stream.Deleted != len(test.deleted) {
+				t.Errorf("skipped %d deleted records, want %d", stream.Deleted, len(test.deleted))
+			}
+
+			if test.errRecord == 0 {
+				if streamErr != nil {
+					t.Error(streamErr)
+				}
+				return
+			}
+			var parseError *ParseError
+			if !errors.As(streamErr, &parseError) {
+				t.Fatalf("got %v, want a ParseError", streamErr)
+			}
+			if parseError.Record != test.errRecord || parseError.Offset != test.errOffset {
+				t.Errorf("error at record %d offset %d, want record %d offset %d", parseError.Record, parseError.Offset, test.errRecord, test.errOffset)
+			}
+		})
+	}
+}
+
+func FuzzStreamRecords(f *testing.F) {
+	for _, st := range allShapeTypes {
+		shp, _, _ := writeShapes(f, st, testShapes(st))
+		f.Add(shp)
+	}
+
+	f.Fuzz(func(t *testing.T, shp []byte) {
+		stream, err := NewStream(bytes.NewReader(shp), nil)
+		if err != nil {
+			return
+		}
+
+		// Records are read back to back, so the offset of a failing record follows from the ones before it.
+		offset := int64(100)
+		number := 0
+		checkAllocations(t, len(shp), func() {
+			for _, err := range stream.Records() {
+				if err != nil {
+					var parseError *ParseError
+					if !errors.As(err, &parseError) {
+						t.Fatalf("got %v, want a ParseError", err)
+					}
+					if parseError.Record != number+1 || parseError.Offset != offset {
+						t.Fatalf("error at record %d offset %d, want record %d offset %d", parseError.Record, parseError.Offset, number+1, offset)
+					}
+					return
+				}
+				number++
+				offset += 8 + int64(binary.BigEndian.Uint32(shp[offset+4:]))*2
+			}
+		})
+	})
+}
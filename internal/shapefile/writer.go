@@ -0,0 +1,205 @@
+package shapefile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	. "github.com/nilptrderef/gogeo/internal/common"
+)
+
+// Writer writes records to a .shp file and its .shx index. Both headers depend on every record written,
+// so they are filled in by Close, which is why the outputs have to be seekable.
+type Writer struct {
+	Header Header
+
+	shp    io.WriteSeeker
+	shx    io.WriteSeeker
+	shpBuf *bufio.Writer
+	shxBuf *bufio.Writer
+	count  uint32
+	// Offset of the next record in 16-bit words
+	offset uint32
+	empty  bool
+}
+
+// NewWriter starts a shapefile holding records of the given shape type. Null records may always be written.
+func NewWriter(shp, shx io.WriteSeeker, st ShapeType) (*Writer, error) {
+	w := &Writer{
+		shp:    shp,
+		shx:    shx,
+		shpBuf: bufio.NewWriter(shp),
+		shxBuf: bufio.NewWriter(shx),
+		offset: 50,
+		empty:  true,
+	}
+	w.Header.File.FileCode = fileCode
+	w.Header.Shape.Version = 1000
+	w.Header.Shape.Type = st
+	w.Header.Shape.Zrange = Range{Min: math.MaxFloat64, Max: -math.MaxFloat64}
+	w.Header.Shape.Mrange = Range{Min: math.MaxFloat64, Max: -math.MaxFloat64}
+
+	// Reserve room for the headers, they are written once all records are known.
+	var reserved [100]byte
+	if _, err := w.shpBuf.Write(reserved[:]); err != nil {
+		return nil, err
+	}
+	if _, err := w.shxBuf.Write(reserved[:]); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends a record holding the shape. Records are numbered in the order they are written.
+func (w *Writer) Write(shape Shape) error {
+	st := shape.GetType()
+	if st != Null && st != w.Header.Shape.Type {
+		return fmt.Errorf("cannot write a %s record to a %s shapefile", st, w.Header.Shape.Type)
+	}
+	// Parts and measures that do not match the points would be written with the wrong content length.
+	if shape, ok := shape.(interface{ check() error }); ok {
+		if err := shape.check(); err != nil {
+			return fmt.Errorf("cannot write record %d: %w", w.count+1, err)
+		}
+	}
+
+	w.count++
+	rh := RecordHeader{Index: w.count, Len: uint32(4+shape.ContentLength()) / 2}
+	if err := binary.Write(w.shpBuf, binary.BigEndian, rh); err != nil {
+		return err
+	}
+	if err := binary.Write(w.shpBuf, binary.LittleEndian, st); err != nil {
+		return err
+	}
+	if err := shape.Write(w.shpBuf); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w.shxBuf, binary.BigEndian, IndexEntry{Offset: w.offset, Len: rh.Len}); err != nil {
+		return err
+	}
+	w.offset += 4 + rh.Len
+
+	w.extend(shape)
+	return nil
+}
+
+// Close writes the headers of both files. It does not close the underlying writers.
+func (w *Writer) Close() error {
+	// Ranges that never received a value are written as zero.
+	if w.Header.Shape.Zrange.Min > w.Header.Shape.Zrange.Max {
+		w.Header.Shape.Zrange = Range{}
+	}
+	if w.Header.Shape.Mrange.Min > w.Header.Shape.Mrange.Max {
+		w.Header.Shape.Mrange = Range{}
+	}
+
+	if err := w.shpBuf.Flush(); err != nil {
+		return err
+	}
+	if err := w.shxBuf.Flush(); err != nil {
+		return err
+	}
+
+	w.Header.File.FileLength = w.offset
+	if err := writeHeader(w.shp, w.Header); err != nil {
+		return err
+	}
+
+	index := w.Header
+	index.File.FileLength = 50 + 4*w.count
+	return writeHeader(w.shx, index)
+}
+
+func writeHeader(ws io.WriteSeeker, header Header) error {
+	if _, err := ws.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := header.Write(ws); err != nil {
+		return err
+	}
+	_, err := ws.Seek(0, io.SeekEnd)
+	return err
+}
+
+// extend grows the bounding box and the Z and M ranges of the header to include a written shape.
+func (w *Writer) extend(shape Shape) {
+	var mbr Rectangle
+	var measures *Measures
+	switch s := shape.(type) {
+	case *PointShape:
+		mbr = Rectangle{Start: s.Point, End: s.Point}
+		measures = &Measures{Z: []float64{s.Z}, M: []float64{s.M}}
+	case *MultiPointShape:
+		if len(s.Points) == 0 {
+			return
+		}
+		mbr = s.Mbr
+		measures = &s.Measures
+	case *PolylineShape:
+		if len(s.Points) == 0 {
+			return
+		}
+		mbr = s.Header.Mbr
+		measures = &s.Measures
+	case *Polygon:
+		if len(s.Points) == 0 {
+			return
+		}
+		mbr = s.Header.Mbr
+		measures = &s.Measures
+	case *MultiPatchShape:
+		if len(s.Points) == 0 {
+			return
+		}
+		mbr = s.Header.Mbr
+		measures = &s.Measures
+	default:
+		return
+	}
+
+	if w.empty {
+		w.empty = false
+		w.Header.Shape.Mbr = mbr
+	}
+
+	w.Header.Shape.Mbr.Start.X = min(w.Header.Shape.Mbr.Start.X, mbr.Start.X)
+	w.Header.Shape.Mbr.Start.Y = min(w.Header.Shape.Mbr.Start.Y, mbr.Start.Y)
+	w.Header.Shape.Mbr.End.X = max(w.Header.Shape.Mbr.End.X, mbr.End.X)
+	w.Header.Shape.Mbr.End.Y = max(w.Header.Shape.Mbr.End.Y, mbr.End.Y)
+
+	if w.Header.Shape.Type.HasZ() {
+		for _, z := range measures.Z {
+			w.Header.Shape.Zrange.Min = min(w.Header.Shape.Zrange.Min, z)
+			w.Header.Shape.Zrange.Max = max(w.Header.Shape.Zrange.Max, z)
+		}
+	}
+	if w.Header.Shape.Type.HasM() {
+		for _, m := range measures.M {
+			w.Header.Shape.Mrange.Min = min(w.Header.Shape.Mrange.Min, m)
+			w.Header.Shape.Mrange.Max = max(w.Header.Shape.Mrange.Max, m)
+		}
+	}
+}
+
+// Write writes the records of the shapefile to shp and their index to shx.
+func (s *Shapefile) Write(shp, shx io.WriteSeeker) error {
+	w, err := NewWriter(shp, shx, s.Header.Shape.Type)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range s.Records {
+		if err := w.Write(record.Geometry); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+	s.Header = w.Header
+	return nil
+}
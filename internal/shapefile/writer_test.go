@@ -0,0 +1,320 @@
+package shapefile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+
+	. "github.com/nilptrderef/gogeo/internal/common"
+)
+
+// seekBuffer is an in-memory io.WriteSeeker for the writers.
+type seekBuffer struct {
+	data []byte
+	pos  int64
+}
+
+func (b *seekBuffer) Write(p []byte) (int, error) {
+	if end := b.pos + int64(len(p)); end > int64(len(b.data)) {
+		b.data = append(b.data, make([]byte, end-int64(len(b.data)))...)
+	}
+	n := copy(b.data[b.pos:], p)
+	b.pos += int64(n)
+	return n, nil
+}
+
+func (b *seekBuffer) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += b.pos
+	case io.SeekEnd:
+		offset += int64(len(b.data))
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	b.pos = offset
+	return offset, nil
+}
+
+// writeShapes writes the shapes as a shapefile of the given type and returns its .shp and .shx files.
+func writeShapes(t testing.TB, st ShapeType, shapes []Shape) (shp, shx []byte, header Header) {
+	t.Helper()
+	var shpBuf, shxBuf seekBuffer
+	w, err := NewWriter(&shpBuf, &shxBuf, st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, shape := range shapes {
+		if err := w.Write(shape); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return shpBuf.data, shxBuf.data, w.Header
+}
+
+func ring(points ...float64) []Point {
+	var ring []Point
+	for i := 0; i+1 < len(points); i += 2 {
+		ring = append(ring, Point{X: points[i], Y: points[i+1]})
+	}
+	return ring
+}
+
+func values(count int, start float64) []float64 {
+	values := make([]float64, count)
+	for i := range values {
+		values[i] = start + float64(i)
+	}
+	return values
+}
+
+// testShapes returns a few records of the shape type, with a null record in between.
+func testShapes(st ShapeType) []Shape {
+	square := ring(0, 0, 0, 10, 10, 10, 10, 0, 0, 0)
+	hole := ring(2, 2, 4, 2, 4, 4, 2, 4, 2, 2)
+	far := ring(-5, 20, -3, 25, 30, -7, -5, 20)
+
+	measures := func(count int, z, m float64) Measures {
+		var ms Measures
+		if st.HasZ() {
+			ms.Z = values(count, z)
+		}
+		if st.HasM() {
+			ms.M = values(count, m)
+		}
+		return ms
+	}
+
+	switch st {
+	case PointType, PointZ, PointM:
+		first := &PointShape{Type: st, Point: Point{X: 1, Y: 2}}
+		second := &PointShape{Type: st, Point: Point{X: -4, Y: 9}}
+		if st.HasZ() {
+			first.Z, second.Z = 7, -7
+		}
+		if st.HasM() {
+			first.M, second.M = 3, 12
+		}
+		return []Shape{first, &NullShape{}, second}
+	case MultiPoint, MultiPointZ, MultiPointM:
+		return []Shape{
+			&MultiPointShape{Type: st, Points: square[:4], Measures: measures(4, 1, 100)},
+			&NullShape{},
+			&MultiPointShape{Type: st, Points: far[:3], Measures: measures(3, -20, -50)},
+		}
+	case Polyline, PolylineZ, PolylineM:
+		return []Shape{
+			&PolylineShape{Multipart{Type: st, Parts: []uint32{0}, Points: far, Measures: measures(len(far), 5, 0)}},
+			&NullShape{},
+			&PolylineShape{Multipart{Type: st, Parts: []uint32{0, 3}, Points: square, Measures: measures(len(square), -1, 10)}},
+		}
+	case PolygonType, PolygonZ, PolygonM:
+		points := append(append([]Point{}, square...), hole...)
+		return []Shape{
+			&Polygon{Multipart{Type: st, Parts: []uint32{0, uint32(len(square))}, Points: points, Measures: measures(len(points), 0, 3)}},
+			&NullShape{},
+			&Polygon{Multipart{Type: st, Parts: []uint32{0}, Points: far, Measures: measures(len(far), 40, -3)}},
+		}
+	case MultiPatch:
+		points := append(append([]Point{}, square...), far...)
+		return []Shape{
+			&MultiPatchShape{
+				Multipart: Multipart{Type: st, Parts: []uint32{0, uint32(len(square))}, Points: points, Measures: measures(len(points), 2, 8)},
+				PartTypes: []PatchType{OuterRing, TriangleStrip},
+			},
+			&NullShape{},
+			&MultiPatchShape{
+				Multipart: Multipart{Type: st, Parts: []uint32{0}, Points: hole, Measures: measures(len(hole), -9, 1)},
+				PartTypes: []PatchType{TriangleFan},
+			},
+		}
+	}
+	return nil
+}
+
+// shapeValues returns the points, Z and M values of a shape.
+func shapeValues(shape Shape) ([]Point, []float64, []float64) {
+	switch s := shape.(type) {
+	case *PointShape:
+		return []Point{s.Point}, []float64{s.Z}, []float64{s.M}
+	case *MultiPointShape:
+		return s.Points, s.Z, s.M
+	case *PolylineShape:
+		return s.Points, s.Z, s.M
+	case *Polygon:
+		return s.Points, s.Z, s.M
+	case *MultiPatchShape:
+		return s.Points, s.Z, s.M
+	}
+	return nil, nil, nil
+}
+
+var allShapeTypes = []ShapeType{
+	PointType, PointZ, PointM,
+	MultiPoint, MultiPointZ, MultiPointM,
+	Polyline, PolylineZ, PolylineM,
+	PolygonType, PolygonZ, PolygonM,
+	MultiPatch,
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	for _, st := range allShapeTypes {
+		t.Run(st.String(), func(t *testing.T) {
+			shapes := testShapes(st)
+			shp, shx, header := writeShapes(t, st, shapes)
+
+			if got := int(header.File.FileLength) * 2; got != len(shp) {
+				t.Errorf(".shp header length %d, file has %d bytes", got, len(shp))
+			}
+
+			// The header covers every point and value written.
+			mbr := Rectangle{Start: Point{X: math.MaxFloat64, Y: math.MaxFloat64}, End: Point{X: -math.MaxFloat64, Y: -math.MaxFloat64}}
+			zrange := Range{Min: math.MaxFloat64, Max: -math.MaxFloat64}
+			mrange := zrange
+			for _, shape := range shapes {
+				points, z, m := shapeValues(shape)
+				for _, pt := range points {
+					mbr.Start.X, mbr.Start.Y = min(mbr.Start.X, pt.X), min(mbr.Start.Y, pt.Y)
+					mbr.End.X, mbr.End.Y = max(mbr.End.X, pt.X), max(mbr.End.Y, pt.Y)
+				}
+				for _, v := range z {
+					zrange = Range{Min: min(zrange.Min, v), Max: max(zrange.Max, v)}
+				}
+				for _, v := range m {
+					mrange = Range{Min: min(mrange.Min, v), Max: max(mrange.Max, v)}
+				}
+			}
+			if !st.HasZ() {
+				zrange = Range{}
+			}
+			if !st.HasM() {
+				mrange = Range{}
+			}
+			want := ShapeInfo{Version: 1000, Type: st, Mbr: mbr, Zrange: zrange, Mrange: mrange}
+			if header.Shape != want {
+				t.Errorf("header %+v, want %+v", header.Shape, want)
+			}
+
+			stream, err := NewStream(bytes.NewReader(shp), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stream.Header != header {
+				t.Errorf("read header %+v, written %+v", stream.Header, header)
+			}
+			var offsets []int64
+			offset := int64(100)
+			i := 0
+			for record, err := range stream.Records() {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if record.Number != uint32(i+1) {
+					t.Errorf("record %d numbered %d", i+1, record.Number)
+				}
+				if !reflect.DeepEqual(record.Geometry, shapes[i]) {
+					t.Errorf("record %d read as %+v, written %+v", i+1, record.Geometry, shapes[i])
+				}
+				offsets = append(offsets, offset)
+				offset += 8 + 4 + int64(shapes[i].ContentLength())
+				i++
+			}
+			if i != len(shapes) {
+				t.Fatalf("read %d records, wrote %d", i, len(shapes))
+			}
+
+			reader, err := NewReader(bytes.NewReader(shp), bytes.NewReader(shx))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := 100 + 8*reader.Len(); got != len(shx) {
+				t.Errorf(".shx has %d bytes, index of %d records needs %d", len(shx), reader.Len(), got)
+			}
+			if reader.Len() != len(shapes) {
+				t.Fatalf("index has %d records, wrote %d", reader.Len(), len(shapes))
+			}
+			for i, shape := range shapes {
+				entry, err := reader.Entry(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if int64(entry.Offset)*2 != offsets[i] || int(entry.Len)*2 != 4+shape.ContentLength() {
+					t.Errorf("index entry %d is %+v, record at %d of %d bytes", i, entry, offsets[i], 4+shape.ContentLength())
+				}
+			}
+			records, err := reader.Records(0, reader.Len())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, record := range records {
+				if !reflect.DeepEqual(record.Geometry, shapes[i]) {
+					t.Errorf("indexed record %d read as %+v, written %+v", i+1, record.Geometry, shapes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriterRejectsOtherShapeTypes(t *testing.T) {
+	var shp, shx seekBuffer
+	w, err := NewWriter(&shp, &shx, PolygonType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&PointShape{Type: PointType}); err == nil {
+		t.Error("wrote a Point record to a Polygon shapefile")
+	}
+}
+
+func TestWriterRejectsMismatchedShapes(t *testing.T) {
+	points := []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 0, Y: 0}}
+	tests := []struct {
+		name  string
+		shape Shape
+	}{
+		{"missing Z", &Polygon{Multipart{Type: PolygonZ, Parts: []uint32{0}, Points: points, Measures: Measures{Z: []float64{1, 2}}}}},
+		{"no Z", &PolylineShape{Multipart{Type: PolylineZ, Parts: []uint32{0}, Points: points}}},
+		{"extra M", &PolylineShape{Multipart{Type: PolylineM, Parts: []uint32{0}, Points: points, Measures: Measures{M: []float64{1, 2, 3, 4, 5}}}}},
+		{"multipoint M", &MultiPointShape{Type: MultiPointM, Points: points, Measures: Measures{M: []float64{1}}}},
+		{"part beyond points", &Polygon{Multipart{Type: PolygonType, Parts: []uint32{0, 4}, Points: points}}},
+		{"no part", &Polygon{Multipart{Type: PolygonType, Points: points}}},
+		{"missing part type", &MultiPatchShape{Multipart: Multipart{Type: MultiPatch, Parts: []uint32{0, 2}, Points: points, Measures: Measures{Z: make([]float64, 4)}}, PartTypes: []PatchType{Ring}}},
+	}
+	for _, test := range tests {
+		var shp, shx seekBuffer
+		w, err := NewWriter(&shp, &shx, test.shape.GetType())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(test.shape); err == nil {
+			t.Errorf("%s: wrote a record that does not match its points", test.name)
+		}
+	}
+
+	// Shapes without M values are fine, they are optional.
+	var shp, shx seekBuffer
+	w, err := NewWriter(&shp, &shx, PolygonM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(&Polygon{Multipart{Type: PolygonM, Parts: []uint32{0}, Points: points}}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMultiPatchWithoutPartTypes(t *testing.T) {
+	points := []Point{{X: 0, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}, {X: 0, Y: 0}}
+	patch := &MultiPatchShape{Multipart: Multipart{Type: MultiPatch, Parts: []uint32{0}, Points: points}}
+	geometry, ok := patch.ToGeoJson().(GeoJsonMultiPolygon)
+	if !ok || len(geometry.Coordinates) != 1 || len(geometry.Coordinates[0][0]) != 4 {
+		t.Errorf("part without a type converted to %+v, want a single ring", geometry)
+	}
+}
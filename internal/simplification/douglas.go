@@ -14,14 +14,44 @@ type DouglasPeuckerSimplifier struct {
 	// The minimum number of points to which a polygon should be simplified. The output should always
 	// be greater than this number as long as the input is greater than this number. Defaults to 4.
 	MinimumPoints int
+
+	// Points further than Tolerance from the simplified line are kept, in the units of the coordinates, on top
+	// of the points kept by percentage. Pass a percentage of 0 to simplify by tolerance alone. Value of 0 will
+	// be ignored.
+	Tolerance float64
+
+	// Rings enclosing less than MinimumArea, in the units of the coordinates squared, are dropped. Value of 0
+	// will be ignored.
+	MinimumArea float64
 }
 
-// Simplify simplifies a set of coordinates (flat [x, y, x, y, ...]) using the Douglas-Peucker algorithm.
+// Simplify simplifies a set of coordinates (flat [x, y, x, y, ...]) using the Douglas-Peucker algorithm. Rings,
+// whose last point repeats the first, are anchored on that point so they stay closed, and get points back
+// until they keep their orientation rather than collapsing or turning inside out. Rings smaller than the
+// minimum area are dropped, leaving no coordinates.
 func (d DouglasPeuckerSimplifier) Simplify(coordinates []float64, percentage float64) ([]float64, error) {
+	return d.simplify(coordinates, percentage, closedRing(coordinates))
+}
+
+// SimplifyLine simplifies an open line (flat [x, y, x, y, ...]) using the Douglas-Peucker algorithm. Its first
+// and last points are always kept, and it is never treated as a ring, even when they are equal.
+func (d DouglasPeuckerSimplifier) SimplifyLine(coordinates []float64, percentage float64) ([]float64, error) {
+	return d.simplify(coordinates, percentage, false)
+}
+
+func (d DouglasPeuckerSimplifier) simplify(coordinates []float64, percentage float64, ring bool) ([]float64, error) {
 	if len(coordinates)%2 != 0 {
 		return nil, errors.New("coordinates must be divisible by 2")
 	}
 
+	var area float64
+	if ring {
+		area = signedArea(coordinates)
+		if math.Abs(area) < d.MinimumArea {
+			return []float64{}, nil
+		}
+	}
+
 	pointCount := len(coordinates) / 2
 	minimum := 4
 	if d.MinimumPoints > 0 {
@@ -29,12 +59,41 @@ func (d DouglasPeuckerSimplifier) Simplify(coordinates []float64, percentage flo
 	}
 
 	target := max(minimum, int(float64(pointCount)*percentage))
-	if pointCount <= minimum || target >= pointCount {
+	if pointCount <= minimum || (d.Tolerance == 0 && target >= pointCount) {
 		result := make([]float64, len(coordinates))
 		copy(result, coordinates)
 		return result, nil
 	}
 
+	thresholds := d.thresholds(coordinates)
+
+	sortedThresholds := make([]float64, pointCount)
+	copy(sortedThresholds, thresholds)
+	sort.Slice(sortedThresholds, func(i, j int) bool {
+		return sortedThresholds[i] > sortedThresholds[j]
+	})
+
+	for {
+		cutoff := d.cutoff(sortedThresholds, target)
+
+		result := make([]float64, 0, target*2)
+		for i := range pointCount {
+			if thresholds[i] >= cutoff {
+				result = append(result, coordinates[i*2], coordinates[i*2+1])
+			}
+		}
+
+		if !ring || target >= pointCount || sameOrientation(area, result) {
+			return result, nil
+		}
+		target = min(pointCount, target*2)
+	}
+}
+
+// thresholds assigns every point of a set of coordinates (flat [x, y, x, y, ...]) the tolerance at which it
+// would be removed. The first and last points are never removed.
+func (d DouglasPeuckerSimplifier) thresholds(coordinates []float64) []float64 {
+	pointCount := len(coordinates) / 2
 	thresholds := make([]float64, pointCount)
 	if pointCount > 0 {
 		thresholds[0] = math.MaxFloat64
@@ -84,76 +143,46 @@ func (d DouglasPeuckerSimplifier) Simplify(coordinates []float64, percentage flo
 	if pointCount > 2 {
 		processSegment(0, pointCount-1, 1, math.MaxFloat64)
 	}
+	return thresholds
+}
 
-	sortedThresholds := make([]float64, pointCount)
-	copy(sortedThresholds, thresholds)
-	sort.Slice(sortedThresholds, func(i, j int) bool {
-		return sortedThresholds[i] > sortedThresholds[j]
-	})
-
-	var cutoff float64
-	if target < len(sortedThresholds) {
-		cutoff = sortedThresholds[target-1]
-	} else {
-		cutoff = 0
+// Rank assigns every point of a set of coordinates (flat [x, y, x, y, ...]) its importance, the distance
+// from the simplified line at which Douglas-Peucker keeps it. Rings, whose first and last points are equal,
+// rank those points like their most important point so that small rings can be dropped as a whole.
+func (d DouglasPeuckerSimplifier) Rank(coordinates []float64) ([]float64, error) {
+	if len(coordinates)%2 != 0 {
+		return nil, errors.New("coordinates must be divisible by 2")
 	}
 
-	result := make([]float64, 0, target*2)
-	for i := range pointCount {
-		if thresholds[i] >= cutoff {
-			result = append(result, coordinates[i*2], coordinates[i*2+1])
+	ranks := d.thresholds(coordinates)
+	pointCount := len(ranks)
+	if closedRing(coordinates) {
+		highest := 0.0
+		for _, rank := range ranks[1 : pointCount-1] {
+			highest = max(highest, rank)
 		}
+		ranks[0] = highest
+		ranks[pointCount-1] = highest
 	}
-
-	return result, nil
+	return ranks, nil
 }
 
-// SimplifyPoints simplifies a set of points ([][x, y]) using the Douglas-Peucker algorithm.
+// SimplifyPoints simplifies a set of points ([][x, y]) like Simplify. Points keep any values beyond X and Y.
 func (d DouglasPeuckerSimplifier) SimplifyPoints(points [][]float64, percentage float64) ([][]float64, error) {
-	for _, point := range points {
-		if len(point) != 2 {
-			return nil, errors.New("points must be all be of length 2")
-		}
+	coordinates, err := flattenPoints(points)
+	if err != nil {
+		return nil, err
 	}
-
-	minimum := 4
-	if d.MinimumPoints > 0 {
-		minimum = d.MinimumPoints
+	simplified, err := d.Simplify(coordinates, percentage)
+	if err != nil {
+		return nil, err
 	}
+	return matchPoints(points, simplified), nil
+}
 
-	target := max(minimum, int(float64(len(points))*percentage))
-	if len(points) <= minimum || target >= len(points) {
-		result := make([][]float64, len(points))
-		for i, point := range points {
-			result[i] = []float64{point[0], point[1]}
-		}
-		return result, nil
-	}
-
-	// Calculate Thresholds (Epsilon ranks) for all points
-	thresholds := make([]float64, len(points))
-
-	// Ensure the first and last points are always kept by giving them infinite threshold
-	if len(points) > 0 {
-		thresholds[0] = math.MaxFloat64
-		thresholds[len(points)-1] = math.MaxFloat64
-	}
-
-	if len(points) > 2 {
-		// Recursive calculation
-		_ = d.ProcessSegment(points, thresholds, 0, len(points)-1, 1, math.MaxFloat64)
-	}
-
-	// Determine the Cutoff Value to select by percentage, we sort the thresholds to find the N-th largest value.
-	sortedThresholds := make([]float64, len(points))
-	copy(sortedThresholds, thresholds)
-	// Sort descending
-	sort.Slice(sortedThresholds, func(i, j int) bool {
-		return sortedThresholds[i] > sortedThresholds[j]
-	})
-
-	// The value at the target index is our "cutoff" (epsilon).
-	// Any point with a threshold >= cutoff is kept.
+// cutoff returns the smallest threshold of the points to keep, given the thresholds sorted in descending order.
+// The target number of points is kept along with every point whose threshold reaches the tolerance.
+func (d DouglasPeuckerSimplifier) cutoff(sortedThresholds []float64, target int) float64 {
 	var cutoff float64
 	if target < len(sortedThresholds) {
 		cutoff = sortedThresholds[target-1]
@@ -161,64 +190,10 @@ func (d DouglasPeuckerSimplifier) SimplifyPoints(points [][]float64, percentage
 		cutoff = 0
 	}
 
-	// Filter Points
-	resultList := make([][]float64, 0)
-	for i, p := range points {
-		if thresholds[i] >= cutoff {
-			resultList = append(resultList, p)
-		}
-	}
-
-	return resultList, nil
-}
-
-// Recursive function to calculate maximum distance and assign thresholds
-func (d DouglasPeuckerSimplifier) ProcessSegment(points [][]float64, dest []float64, startIdx, endIdx int, depth int, distSqPrev float64) float64 {
-	ax := points[startIdx][0]
-	ay := points[startIdx][1]
-	cx := points[endIdx][0]
-	cy := points[endIdx][1]
-
-	var maxDistSq float64 = 0
-	var maxIdx int = 0
-
-	// Find point with maximum distance from segment AC
-	for i := startIdx + 1; i < endIdx; i++ {
-		distSq := d.GetSqSegDist(points[i][0], points[i][1], ax, ay, cx, cy)
-		if distSq >= maxDistSq {
-			maxDistSq = distSq
-			maxIdx = i
-		}
-	}
-
-	// Constraint 1: Parent Threshold Cap
-	// Ensure child nodes never have a higher threshold than their parent segment.
-	// This ensures that as you slide the epsilon slider, points disappear hierarchically.
-	if distSqPrev < maxDistSq {
-		maxDistSq = distSqPrev
-	}
-
-	var distSqLeft float64 = 0
-	var distSqRight float64 = 0
-	// Recurse Left
-	if maxIdx-startIdx > 1 {
-		distSqLeft = d.ProcessSegment(points, dest, startIdx, maxIdx, depth+1, maxDistSq)
+	if d.Tolerance > 0 {
+		cutoff = min(cutoff, d.Tolerance)
 	}
-	// Recurse Right
-	if endIdx-maxIdx > 1 {
-		distSqRight = d.ProcessSegment(points, dest, maxIdx, endIdx, depth+1, maxDistSq)
-	}
-
-	// Constraint 2: Island Polygon (Ring) Preservation
-	// If we are at the top level (depth 1) and it's a closed loop (start == end),
-	// we force the max point to keep the max threshold of its children.
-	// This prevents a small triangle from collapsing into a single point too early.
-	if depth == 1 && ax == cx && ay == cy {
-		maxDistSq = max(distSqLeft, distSqRight)
-	}
-
-	dest[maxIdx] = math.Sqrt(maxDistSq)
-	return maxDistSq
+	return cutoff
 }
 
 // Squared distance from point (px, py) to line segment (ax, ay)-(bx, by)
@@ -0,0 +1,15 @@
+package simplification
+
+import "testing"
+
+func douglasPeucker(minimumPoints int, minimumArea float64) Simplifier {
+	return DouglasPeuckerSimplifier{MinimumPoints: minimumPoints, MinimumArea: minimumArea}
+}
+
+func TestDouglasPeuckerRings(t *testing.T) {
+	testRings(t, douglasPeucker)
+}
+
+func TestDouglasPeuckerLines(t *testing.T) {
+	testLines(t, douglasPeucker)
+}
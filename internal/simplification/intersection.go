@@ -0,0 +1,190 @@
+package simplification
+
+import (
+	"slices"
+)
+
+// Validation is what is done about rings that cross themselves or each other once simplified.
+type Validation int
+
+const (
+	// Rings are not checked
+	NoValidation Validation = iota
+	// Rings are checked, see Intersections
+	ReportIntersections
+	// Removed points are restored until rings no longer cross, see Repair
+	RepairIntersections
+)
+
+// Validate checks the simplified rings of a feature against each other as asked by validation, restoring
+// points of the original rings when repairing. valid is false when rings cross.
+func Validate(original, simplified [][]float64, validation Validation) (rings [][]float64, valid bool) {
+	switch validation {
+	case ReportIntersections:
+		return simplified, Intersections(simplified) == nil
+	case RepairIntersections:
+		return Repair(original, simplified)
+	}
+	return simplified, true
+}
+
+// segment runs from point index to point index+1 of a ring.
+type segment struct {
+	ring, index    int
+	ax, ay, bx, by float64
+}
+
+// Intersections finds the segments of the rings (flat [x, y, x, y, ...]) that cross or touch another segment
+// of the same or of another ring, and flags them by ring and by index of their first point. Segments meeting
+// at a point they share, such as neighbors along a ring, do not intersect. It returns nil when no segments
+// intersect.
+func Intersections(rings [][]float64) [][]bool {
+	var segments []segment
+	for r, ring := range rings {
+		for i := 0; i+3 < len(ring); i += 2 {
+			s := segment{ring: r, index: i / 2, ax: ring[i], ay: ring[i+1], bx: ring[i+2], by: ring[i+3]}
+			if s.ax != s.bx || s.ay != s.by {
+				segments = append(segments, s)
+			}
+		}
+	}
+
+	// Sweeping along X, only the segments whose X ranges overlap are compared.
+	slices.SortFunc(segments, func(a, b segment) int {
+		switch ax, bx := min(a.ax, a.bx), min(b.ax, b.bx); {
+		case ax < bx:
+			return -1
+		case ax > bx:
+			return 1
+		}
+		return 0
+	})
+
+	var flags [][]bool
+	var active []segment
+	for _, s := range segments {
+		left := min(s.ax, s.bx)
+		active = slices.DeleteFunc(active, func(a segment) bool {
+			return max(a.ax, a.bx) < left
+		})
+
+		for _, a := range active {
+			if max(a.ay, a.by) < min(s.ay, s.by) || max(s.ay, s.by) < min(a.ay, a.by) || !intersects(a, s) {
+				continue
+			}
+			if flags == nil {
+				flags = make([][]bool, len(rings))
+				for r, ring := range rings {
+					flags[r] = make([]bool, max(0, len(ring)/2-1))
+				}
+			}
+			flags[a.ring][a.index] = true
+			flags[s.ring][s.index] = true
+		}
+		active = append(active, s)
+	}
+	return flags
+}
+
+// intersects reports whether two segments cross, or whether an end of one lies on the other anywhere but at
+// an end they share.
+func intersects(s, t segment) bool {
+	d1 := orientation(t.ax, t.ay, t.bx, t.by, s.ax, s.ay)
+	d2 := orientation(t.ax, t.ay, t.bx, t.by, s.bx, s.by)
+	d3 := orientation(s.ax, s.ay, s.bx, s.by, t.ax, t.ay)
+	d4 := orientation(s.ax, s.ay, s.bx, s.by, t.bx, t.by)
+	if d1*d2 < 0 && d3*d4 < 0 {
+		return true
+	}
+
+	return (d1 == 0 && touches(t, s.ax, s.ay)) ||
+		(d2 == 0 && touches(t, s.bx, s.by)) ||
+		(d3 == 0 && touches(s, t.ax, t.ay)) ||
+		(d4 == 0 && touches(s, t.bx, t.by))
+}
+
+// orientation returns the sign of the turn from a to b to c: positive when counterclockwise, negative when
+// clockwise and 0 when the points are collinear.
+func orientation(ax, ay, bx, by, cx, cy float64) float64 {
+	cross := (bx-ax)*(cy-ay) - (by-ay)*(cx-ax)
+	switch {
+	case cross > 0:
+		return 1
+	case cross < 0:
+		return -1
+	}
+	return 0
+}
+
+// touches reports whether the point, collinear with the segment, lies on it but not at one of its ends.
+func touches(s segment, x, y float64) bool {
+	if (x == s.ax && y == s.ay) || (x == s.bx && y == s.by) {
+		return false
+	}
+	return min(s.ax, s.bx) <= x && x <= max(s.ax, s.bx) && min(s.ay, s.by) <= y && y <= max(s.ay, s.by)
+}
+
+// Repair restores points of the original rings removed by simplifying until no segments of the simplified
+// rings intersect, see Intersections. Every simplified ring has to be an ordered subset of the original ring
+// at the same index. Each intersecting segment gets back the removed point furthest from it, like
+// Douglas-Peucker would have kept, until it no longer intersects. valid is false when segments still
+// intersect with every point between their ends restored, which happens when the original rings already did.
+func Repair(original, simplified [][]float64) (rings [][]float64, valid bool) {
+	// Positions of the remaining points in the original rings
+	kept := make([][]int, len(original))
+	for r, ring := range original {
+		k := 0
+		for i := 0; i+1 < len(ring) && k+1 < len(simplified[r]); i += 2 {
+			if ring[i] == simplified[r][k] && ring[i+1] == simplified[r][k+1] {
+				kept[r] = append(kept[r], i/2)
+				k += 2
+			}
+		}
+	}
+
+	rings = simplified
+	var measure DouglasPeuckerSimplifier
+	for {
+		flags := Intersections(rings)
+		if flags == nil {
+			return rings, true
+		}
+
+		restored := false
+		for r, ring := range flags {
+			points := original[r]
+			var positions []int
+			for s, position := range kept[r] {
+				positions = append(positions, position)
+				if s+1 >= len(kept[r]) || s >= len(ring) || !ring[s] {
+					continue
+				}
+
+				start, end := position, kept[r][s+1]
+				furthest, distance := -1, -1.0
+				for i := start + 1; i < end; i++ {
+					d := measure.GetSqSegDist(points[i*2], points[i*2+1], points[start*2], points[start*2+1], points[end*2], points[end*2+1])
+					if d > distance {
+						furthest, distance = i, d
+					}
+				}
+				if furthest >= 0 {
+					positions = append(positions, furthest)
+					restored = true
+				}
+			}
+			kept[r] = positions
+		}
+		if !restored {
+			return rings, false
+		}
+
+		rings = make([][]float64, len(original))
+		for r, positions := range kept {
+			rings[r] = make([]float64, 0, len(positions)*2)
+			for _, i := range positions {
+				rings[r] = append(rings[r], original[r][i*2], original[r][i*2+1])
+			}
+		}
+	}
+}
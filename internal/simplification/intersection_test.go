@@ -0,0 +1,118 @@
+package simplification
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+var (
+	// Square running clockwise
+	square = []float64{0, 0, 0, 10, 10, 10, 10, 0, 0, 0}
+	// Ring crossing itself between (0, 0)-(2, 2) and (2, 0)-(0, 2)
+	bowTie = []float64{0, 0, 2, 2, 2, 0, 0, 2, 0, 0}
+	// The bow tie with a point going around the crossing
+	untiedBowTie = []float64{0, 0, 2, 2, 2, 0, 3, 3, 0, 2, 0, 0}
+	// Square bulging down to (5, -5), around the hole
+	bulge = []float64{0, 0, 0, 10, 10, 10, 10, 0, 5, -5, 0, 0}
+	// Hole running counterclockwise across the bottom side of the square
+	hole = []float64{4, -1, 6, -1, 6, 1, 4, 1, 4, -1}
+)
+
+func TestIntersections(t *testing.T) {
+	tests := []struct {
+		name  string
+		rings [][]float64
+		// Flagged segments by ring, nil when none intersect
+		want [][]bool
+	}{
+		{"square", [][]float64{square}, nil},
+		{"bow tie", [][]float64{bowTie}, [][]bool{{true, false, true, false}}},
+		{"untied bow tie", [][]float64{untiedBowTie}, nil},
+		{"hole inside", [][]float64{bulge, hole}, nil},
+		{"hole crossing", [][]float64{square, hole}, [][]bool{{false, false, false, true}, {false, true, false, true}}},
+		{"point on a segment", [][]float64{square, {5, 0, 5, 5, 6, 5, 5, 0}}, [][]bool{{false, false, false, true}, {true, false, true}}},
+		{"rings sharing a corner", [][]float64{square, {10, 10, 10, 12, 12, 12, 10, 10}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Intersections(test.rings); !slices.EqualFunc(got, test.want, slices.Equal) {
+				t.Errorf("flagged %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIntersectionsSweep(t *testing.T) {
+	// The sweep flags the same segments as comparing every pair.
+	r := rand.New(rand.NewSource(1))
+	for range 500 {
+		rings := make([][]float64, 1+r.Intn(3))
+		for i := range rings {
+			for range 3 + r.Intn(8) {
+				rings[i] = append(rings[i], float64(r.Intn(20)), float64(r.Intn(20)))
+			}
+			rings[i] = append(rings[i], rings[i][0], rings[i][1])
+		}
+
+		var segments []segment
+		for ri, ring := range rings {
+			for i := 0; i+3 < len(ring); i += 2 {
+				segments = append(segments, segment{ring: ri, index: i / 2, ax: ring[i], ay: ring[i+1], bx: ring[i+2], by: ring[i+3]})
+			}
+		}
+		var want [][]bool
+		for i, s := range segments {
+			for _, o := range segments[i+1:] {
+				if (s.ax == s.bx && s.ay == s.by) || (o.ax == o.bx && o.ay == o.by) || !intersects(s, o) {
+					continue
+				}
+				if want == nil {
+					want = make([][]bool, len(rings))
+					for ri, ring := range rings {
+						want[ri] = make([]bool, len(ring)/2-1)
+					}
+				}
+				want[s.ring][s.index] = true
+				want[o.ring][o.index] = true
+			}
+		}
+
+		if got := Intersections(rings); !slices.EqualFunc(got, want, slices.Equal) {
+			t.Fatalf("rings %v flagged %v, want %v", rings, got, want)
+		}
+	}
+}
+
+func TestRepair(t *testing.T) {
+	tests := []struct {
+		name                 string
+		original, simplified [][]float64
+		want                 [][]float64
+		valid                bool
+	}{
+		{"valid", [][]float64{bulge, hole}, [][]float64{bulge, hole}, [][]float64{bulge, hole}, true},
+		{"bow tie", [][]float64{untiedBowTie}, [][]float64{bowTie}, [][]float64{untiedBowTie}, true},
+		{"shell crossing its hole", [][]float64{bulge, hole}, [][]float64{square, hole}, [][]float64{bulge, hole}, true},
+		{"crossing from the start", [][]float64{bowTie}, [][]float64{bowTie}, [][]float64{bowTie}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if flags := Intersections(test.original); (flags == nil) != test.valid {
+				t.Fatalf("original rings flagged %v", flags)
+			}
+			got, valid := Repair(test.original, test.simplified)
+			if valid != test.valid || !slices.EqualFunc(got, test.want, slices.Equal) {
+				t.Errorf("repaired to %v, %t, want %v, %t", got, valid, test.want, test.valid)
+			}
+
+			reported, valid := Validate(test.original, test.simplified, ReportIntersections)
+			if crossing := Intersections(test.simplified) != nil; valid == crossing || !slices.EqualFunc(reported, test.simplified, slices.Equal) {
+				t.Errorf("validation reported %t for rings crossing: %t", valid, crossing)
+			}
+			if repaired, valid := Validate(test.original, test.simplified, RepairIntersections); valid != test.valid || !slices.EqualFunc(repaired, test.want, slices.Equal) {
+				t.Errorf("validation repaired to %v, %t", repaired, valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package simplification
+
+import (
+	"math"
+	"slices"
+)
+
+// Quality measures how much of the shape of a feature is lost by simplifying its rings or lines, in the units
+// of the coordinates.
+type Quality struct {
+	Points, SimplifiedPoints       int
+	Area, SimplifiedArea           float64
+	Perimeter, SimplifiedPerimeter float64
+	// Largest distance between the original and the simplified rings, see Hausdorff
+	Hausdorff float64
+}
+
+// Measure compares the rings or lines of a feature (flat [x, y, x, y, ...]) with what is left of them once
+// simplified. The area is enclosed by the closed rings, holes running opposite to their outer ring, and the
+// perimeter is the length of every ring and line.
+func Measure(original, simplified [][]float64) Quality {
+	var q Quality
+	q.Points, q.Area, q.Perimeter = measure(original)
+	q.SimplifiedPoints, q.SimplifiedArea, q.SimplifiedPerimeter = measure(simplified)
+	q.Hausdorff = Hausdorff(original, simplified)
+	return q
+}
+
+func measure(rings [][]float64) (points int, area, perimeter float64) {
+	for _, ring := range rings {
+		points += len(ring) / 2
+		if closedRing(ring) {
+			area += signedArea(ring)
+		}
+		for i := 0; i+3 < len(ring); i += 2 {
+			perimeter += math.Hypot(ring[i+2]-ring[i], ring[i+3]-ring[i+1])
+		}
+	}
+	return points, math.Abs(area), perimeter
+}
+
+// Add sums the points, areas and perimeters of other into q and keeps the largest Hausdorff distance, which
+// makes q the quality of both features together.
+func (q *Quality) Add(other Quality) {
+	q.Points += other.Points
+	q.SimplifiedPoints += other.SimplifiedPoints
+	q.Area += other.Area
+	q.SimplifiedArea += other.SimplifiedArea
+	q.Perimeter += other.Perimeter
+	q.SimplifiedPerimeter += other.SimplifiedPerimeter
+	q.Hausdorff = max(q.Hausdorff, other.Hausdorff)
+}
+
+// PointReduction returns the share of the points removed, from 0 to 1.
+func (q Quality) PointReduction() float64 {
+	return 1 - ratio(float64(q.SimplifiedPoints), float64(q.Points))
+}
+
+// AreaChange returns the change of area relative to the original area, negative when area was lost.
+func (q Quality) AreaChange() float64 {
+	return ratio(q.SimplifiedArea, q.Area) - 1
+}
+
+// PerimeterChange returns the change of perimeter relative to the original perimeter, which only ever shrinks.
+func (q Quality) PerimeterChange() float64 {
+	return ratio(q.SimplifiedPerimeter, q.Perimeter) - 1
+}
+
+// ratio returns simplified/original, or 1 when there was nothing to begin with.
+func ratio(simplified, original float64) float64 {
+	if original == 0 {
+		return 1
+	}
+	return simplified / original
+}
+
+// Hausdorff returns the largest distance from a point of either set of rings (flat [x, y, x, y, ...]) to the
+// nearest segment of the other, how far the simplified outline strays from the original one at worst. A
+// feature dropped altogether, nothing left of its simplified rings, strays by its whole extent: the diagonal
+// of the box bounding its original rings.
+func Hausdorff(original, simplified [][]float64) float64 {
+	if !slices.ContainsFunc(simplified, func(ring []float64) bool { return len(ring) >= 4 }) {
+		return extent(original)
+	}
+	return max(directedHausdorff(original, simplified), directedHausdorff(simplified, original))
+}
+
+// extent returns the diagonal of the box bounding the rings, 0 when they hold no point.
+func extent(rings [][]float64) float64 {
+	minX, minY := math.MaxFloat64, math.MaxFloat64
+	maxX, maxY := -math.MaxFloat64, -math.MaxFloat64
+	for _, ring := range rings {
+		for i := 0; i+1 < len(ring); i += 2 {
+			minX, maxX = min(minX, ring[i]), max(maxX, ring[i])
+			minY, maxY = min(minY, ring[i+1]), max(maxY, ring[i+1])
+		}
+	}
+	if minX > maxX {
+		return 0
+	}
+	return math.Hypot(maxX-minX, maxY-minY)
+}
+
+// directedHausdorff returns the largest distance from a point of from to the nearest segment of to. Points
+// are first compared with the segment of to starting at the last point they share, the segment that replaced
+// them when to is a simplification of from, and the search stops as soon as a segment is closer than the
+// largest distance so far, since the point cannot raise it anymore.
+func directedHausdorff(from, to [][]float64) float64 {
+	var segments []segment
+	starts := make(map[[2]float64]int)
+	for r, ring := range to {
+		for i := 0; i+3 < len(ring); i += 2 {
+			if _, found := starts[[2]float64{ring[i], ring[i+1]}]; !found {
+				starts[[2]float64{ring[i], ring[i+1]}] = len(segments)
+			}
+			segments = append(segments, segment{ring: r, index: i / 2, ax: ring[i], ay: ring[i+1], bx: ring[i+2], by: ring[i+3]})
+		}
+	}
+	if len(segments) == 0 {
+		return 0
+	}
+
+	var measure DouglasPeuckerSimplifier
+	distance := 0.0
+	for _, ring := range from {
+		current := -1
+		for i := 0; i+1 < len(ring); i += 2 {
+			x, y := ring[i], ring[i+1]
+			if s, found := starts[[2]float64{x, y}]; found {
+				current = s
+			}
+
+			nearest := math.MaxFloat64
+			if current >= 0 {
+				s := segments[current]
+				nearest = measure.GetSqSegDist(x, y, s.ax, s.ay, s.bx, s.by)
+			}
+			for _, s := range segments {
+				if nearest <= distance {
+					break
+				}
+				nearest = min(nearest, measure.GetSqSegDist(x, y, s.ax, s.ay, s.bx, s.by))
+			}
+			distance = max(distance, nearest)
+		}
+	}
+	return math.Sqrt(distance)
+}
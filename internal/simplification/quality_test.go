@@ -0,0 +1,36 @@
+package simplification
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHausdorff(t *testing.T) {
+	square := []float64{0, 0, 0, 4, 3, 4, 3, 0, 0, 0}
+	tests := []struct {
+		name                 string
+		original, simplified [][]float64
+		want                 float64
+	}{
+		{"unchanged", [][]float64{square}, [][]float64{square}, 0},
+		{"corner cut", [][]float64{square}, [][]float64{{0, 0, 0, 4, 3, 0, 0, 0}}, 2.4},
+		{"dropped", [][]float64{square}, nil, 5},
+		{"ring dropped", [][]float64{square, {10, 0, 10, 1, 11, 0, 10, 0}}, [][]float64{square}, 8},
+		{"collapsed to a point", [][]float64{square}, [][]float64{{1, 1}}, 5},
+		{"nothing", nil, nil, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Hausdorff(test.original, test.simplified); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("Hausdorff is %g, want %g", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMeasureDropped(t *testing.T) {
+	q := Measure([][]float64{{0, 0, 0, 4, 3, 4, 3, 0, 0, 0}}, nil)
+	if q.SimplifiedPoints != 0 || q.SimplifiedArea != 0 || q.AreaChange() != -1 || q.Hausdorff != 5 {
+		t.Errorf("dropped feature measured as %+v", q)
+	}
+}
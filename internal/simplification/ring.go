@@ -0,0 +1,57 @@
+package simplification
+
+import (
+	"errors"
+)
+
+// closedRing reports whether coordinates (flat [x, y, x, y, ...]) form a ring, whose last point repeats the
+// first.
+func closedRing(coordinates []float64) bool {
+	n := len(coordinates)
+	return n > 6 && coordinates[0] == coordinates[n-2] && coordinates[1] == coordinates[n-1]
+}
+
+// signedArea returns the area enclosed by a ring, positive when it runs counterclockwise and negative when it
+// runs clockwise.
+func signedArea(coordinates []float64) float64 {
+	n := len(coordinates) / 2
+	area := 0.0
+	for i := range n {
+		j := (i + 1) % n
+		area += coordinates[i*2]*coordinates[j*2+1] - coordinates[j*2]*coordinates[i*2+1]
+	}
+	return area / 2
+}
+
+// sameOrientation reports whether a simplified ring still encloses some area, on the same side as the
+// original ring of the given signed area.
+func sameOrientation(area float64, simplified []float64) bool {
+	simplifiedArea := signedArea(simplified)
+	return simplifiedArea != 0 && (simplifiedArea > 0) == (area > 0)
+}
+
+// flattenPoints returns the X and Y values of points ([][x, y, ...]) as flat coordinates.
+func flattenPoints(points [][]float64) ([]float64, error) {
+	coordinates := make([]float64, 0, len(points)*2)
+	for _, point := range points {
+		if len(point) < 2 {
+			return nil, errors.New("points must all be of at least length 2")
+		}
+		coordinates = append(coordinates, point[0], point[1])
+	}
+	return coordinates, nil
+}
+
+// matchPoints returns copies of the points remaining in simplified coordinates, which are an ordered subset
+// of the points, so that they keep any values beyond X and Y.
+func matchPoints(points [][]float64, simplified []float64) [][]float64 {
+	result := make([][]float64, 0, len(simplified)/2)
+	k := 0
+	for _, point := range points {
+		if k+1 < len(simplified) && point[0] == simplified[k] && point[1] == simplified[k+1] {
+			result = append(result, append([]float64(nil), point...))
+			k += 2
+		}
+	}
+	return result
+}
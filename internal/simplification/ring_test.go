@@ -0,0 +1,119 @@
+package simplification
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// jagged returns a closed ring of n points around the origin, alternating between a radius of 10 and 7,
+// clockwise unless counterClockwise is set.
+func jagged(n int, counterClockwise bool) []float64 {
+	var ring []float64
+	for i := range n {
+		angle := -2 * math.Pi * float64(i) / float64(n)
+		if counterClockwise {
+			angle = -angle
+		}
+		radius := 10.0
+		if i%2 == 1 {
+			radius = 7
+		}
+		ring = append(ring, radius*math.Cos(angle), radius*math.Sin(angle))
+	}
+	return append(ring, ring[0], ring[1])
+}
+
+// sliver returns a closed ring barely wider than the noise along its long sides, which easily collapses or
+// turns inside out when simplified.
+func sliver() []float64 {
+	var ring []float64
+	for i := range 30 {
+		ring = append(ring, float64(i), 0.01*math.Sin(float64(i)*7))
+	}
+	for i := 29; i >= 0; i-- {
+		ring = append(ring, float64(i), 0.05+0.01*math.Sin(float64(i)*11))
+	}
+	return append(ring, ring[0], ring[1])
+}
+
+// ordered reports whether the points of simplified appear in coordinates, in the same order.
+func ordered(simplified, coordinates []float64) bool {
+	k := 0
+	for i := 0; i+1 < len(coordinates) && k+1 < len(simplified); i += 2 {
+		if coordinates[i] == simplified[k] && coordinates[i+1] == simplified[k+1] {
+			k += 2
+		}
+	}
+	return k == len(simplified)
+}
+
+// simplifierOptions builds a simplifier with the given minimum points and minimum area.
+type simplifierOptions func(minimumPoints int, minimumArea float64) Simplifier
+
+func testRings(t *testing.T, newSimplifier simplifierOptions) {
+	tests := []struct {
+		name          string
+		ring          []float64
+		percentage    float64
+		minimumPoints int
+		minimumArea   float64
+		// Whether the ring is dropped, or comes back unchanged
+		dropped, unchanged bool
+	}{
+		{name: "clockwise", ring: jagged(40, false), percentage: 0.2},
+		{name: "counterclockwise", ring: jagged(40, true), percentage: 0.2},
+		{name: "down to the minimum", ring: jagged(40, false), percentage: 0},
+		{name: "sliver", ring: sliver(), percentage: 0.05},
+		// Simplified down to 4 points, the hook turns inside out with Douglas-Peucker and the fold with
+		// Visvalingam-Whyatt, unless they get points back.
+		{name: "hook", ring: []float64{9, 7, 0, 7, 0, 11, 8, 10, 10, 7, 11, 3, 9, 6, 9, 7}, percentage: 0},
+		{name: "fold", ring: []float64{4, 0, 2, 0, 3, 3, 8, 5, 1, 10, 10, 10, 9, 4, 4, 0}, percentage: 0},
+		{name: "minimum points", ring: jagged(40, true), percentage: 0.01, minimumPoints: 9},
+		{name: "minimum points above the point count", ring: jagged(8, false), percentage: 0.1, minimumPoints: 10, unchanged: true},
+		{name: "minimum points of the point count", ring: jagged(8, false), percentage: 0.1, minimumPoints: 9, unchanged: true},
+		{name: "above the minimum area", ring: jagged(40, false), percentage: 0.2, minimumArea: 150},
+		{name: "below the minimum area", ring: jagged(40, false), percentage: 0.2, minimumArea: 250, dropped: true},
+		{name: "counterclockwise below the minimum area", ring: jagged(40, true), percentage: 1, minimumArea: 250, dropped: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := slices.Clone(test.ring)
+			simplified, err := newSimplifier(test.minimumPoints, test.minimumArea).Simplify(test.ring, test.percentage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(test.ring, input) {
+				t.Error("simplifying changed the input")
+			}
+
+			switch {
+			case test.dropped:
+				if len(simplified) != 0 {
+					t.Errorf("ring of area %g below the minimum area kept %d points", math.Abs(signedArea(input)), len(simplified)/2)
+				}
+				return
+			case test.unchanged:
+				if !slices.Equal(simplified, input) {
+					t.Errorf("ring of %d points simplified to %v", len(input)/2, simplified)
+				}
+				return
+			}
+
+			points := len(simplified) / 2
+			minimum := max(test.minimumPoints, 4)
+			if points < minimum || points > len(input)/2 {
+				t.Errorf("ring of %d points simplified to %d, expected between %d and %d", len(input)/2, points, minimum, len(input)/2)
+			}
+			if !closedRing(simplified) {
+				t.Errorf("simplified ring %v is not closed", simplified)
+			}
+			if !ordered(simplified, input) {
+				t.Errorf("simplified ring %v is not an ordered subset of the ring", simplified)
+			}
+			if area := signedArea(simplified); area == 0 || (area > 0) != (signedArea(input) > 0) {
+				t.Errorf("ring of area %g simplified to area %g", signedArea(input), signedArea(simplified))
+			}
+		})
+	}
+}
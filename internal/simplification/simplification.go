@@ -1,10 +1,26 @@
 package simplification
 
+// Simplifier removes points from rings and lines. The percentage passed to each method is the approximate
+// share of the points that should remain, between 0 and 1.
 type Simplifier interface {
-	// Simplify takes a slice of float64 which must have a length divisible by 2. It is interpreted as a slice
-	// of X,Y coordinates for polygon a single polygon. A simplifier's second parameter is the approximate percentage
-	// of points that should be remaining when the simplifier is done.
+	// Simplify takes the flat [x, y, x, y, ...] coordinates of a single ring of a polygon, whose length has to
+	// be divisible by 2. A ring whose last point repeats the first stays closed and keeps its orientation, so
+	// that outer rings and holes do not swap, and rings smaller than the minimum area of the simplifier come
+	// back empty. Coordinates whose ends differ are not a ring, and unlike SimplifyLine their ends may be
+	// removed.
 	Simplify(coordinates []float64, percentage float64) ([]float64, error)
 
+	// SimplifyPoints simplifies a ring of points ([][x, y]) like Simplify. Points keep any values beyond X and
+	// Y, such as Z and M.
 	SimplifyPoints(points [][]float64, percentage float64) ([][]float64, error)
+
+	// SimplifyLine takes coordinates like Simplify but as an open line, such as a road, a river or a border
+	// shared by two polygons, rather than a ring. Its first and last points are always kept, even when they
+	// are equal, and it is never dropped for its area.
+	SimplifyLine(coordinates []float64, percentage float64) ([]float64, error)
+
+	// Rank takes coordinates like Simplify and returns the importance of each point, using the same metric
+	// the simplifier removes points by. Ranks are comparable across calls, so keeping the points ranked above
+	// a single cutoff simplifies many polygons together.
+	Rank(coordinates []float64) ([]float64, error)
 }
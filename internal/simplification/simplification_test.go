@@ -0,0 +1,69 @@
+package simplification
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// zigzag returns an open line of n points running along X and zigzagging across it.
+func zigzag(n int) []float64 {
+	var line []float64
+	for i := range n {
+		line = append(line, float64(i), 3*math.Sin(float64(i)*1.3)+float64(i%3))
+	}
+	return line
+}
+
+func testLines(t *testing.T, newSimplifier simplifierOptions) {
+	tests := []struct {
+		name          string
+		line          []float64
+		percentage    float64
+		minimumPoints int
+		minimumArea   float64
+		unchanged     bool
+	}{
+		{name: "zigzag", line: zigzag(50), percentage: 0.2},
+		{name: "down to the minimum", line: zigzag(50), percentage: 0},
+		// Lines whose ends meet are not rings, so the minimum area does not drop them.
+		{name: "ends meeting", line: jagged(40, false), percentage: 0.2, minimumArea: 1000},
+		// The first point is in line with its neighbor and the last point, which would make it the first to go if
+		// the ends were neighbors like in a ring.
+		{name: "ends in line", line: []float64{0, 0, 1, 0, 2, 3, 3, -1, 4, 4, 5, -2, 6, 5, 2, 8, -3, 0}, percentage: 0.5},
+		{name: "minimum points", line: zigzag(50), percentage: 0.01, minimumPoints: 7},
+		{name: "minimum points above the point count", line: zigzag(6), percentage: 0.1, minimumPoints: 8, unchanged: true},
+		{name: "fewer points than the minimum", line: zigzag(3), percentage: 0.1, unchanged: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := slices.Clone(test.line)
+			simplified, err := newSimplifier(test.minimumPoints, test.minimumArea).SimplifyLine(test.line, test.percentage)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(test.line, input) {
+				t.Error("simplifying changed the input")
+			}
+			if test.unchanged {
+				if !slices.Equal(simplified, input) {
+					t.Errorf("line of %d points simplified to %v", len(input)/2, simplified)
+				}
+				return
+			}
+
+			points := len(simplified) / 2
+			minimum := max(test.minimumPoints, 4)
+			if points < minimum || points >= len(input)/2 {
+				t.Errorf("line of %d points simplified to %d, expected between %d and %d", len(input)/2, points, minimum, len(input)/2-1)
+			}
+			n := len(input)
+			if !slices.Equal(simplified[:2], input[:2]) || !slices.Equal(simplified[len(simplified)-2:], input[n-2:]) {
+				t.Errorf("line from %v to %v simplified to a line from %v to %v", input[:2], input[n-2:], simplified[:2], simplified[len(simplified)-2:])
+			}
+			if !ordered(simplified, input) {
+				t.Errorf("simplified line %v is not an ordered subset of the line", simplified)
+			}
+		})
+	}
+}
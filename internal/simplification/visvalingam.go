@@ -16,53 +16,97 @@ type VisvalingamSimplifier struct {
 	// The minimum number of points to which a polygon should be simplified. The output should always
 	// be greater than this number as long as the input is greater than this number. Defaults to 4.
 	MinimumPoints int
+
+	// Points whose effective area reaches Tolerance are kept, in the units of the coordinates squared, on top
+	// of the points kept by percentage. Pass a percentage of 0 to simplify by tolerance alone. Value of 0 will
+	// be ignored.
+	Tolerance float64
+
+	// Rings enclosing less than MinimumArea, in the units of the coordinates squared, are dropped. Value of 0
+	// will be ignored.
+	MinimumArea float64
 }
 
-// Simplifies a set of points in place using the Visvalingam-Whyatt algorithm.
+// Simplifies a set of points in place using the Visvalingam-Whyatt algorithm. Rings, whose last point repeats
+// the first, are anchored on that point so they stay closed, and get points back until they keep their
+// orientation rather than collapsing or turning inside out. Rings smaller than the minimum area are dropped,
+// leaving no coordinates.
 func (v VisvalingamSimplifier) Simplify(coordinates []float64, percentage float64) ([]float64, error) {
+	return v.simplify(coordinates, percentage, closedRing(coordinates), false)
+}
+
+// SimplifyLine simplifies an open line (flat [x, y, x, y, ...]) using the Visvalingam-Whyatt algorithm. Unlike
+// the points of a polygon its ends have a single neighbor, so they are always kept and never wrap around to
+// each other.
+func (v VisvalingamSimplifier) SimplifyLine(coordinates []float64, percentage float64) ([]float64, error) {
+	return v.simplify(coordinates, percentage, false, true)
+}
+
+func (v VisvalingamSimplifier) simplify(coordinates []float64, percentage float64, ring, line bool) ([]float64, error) {
 	if len(coordinates)%2 != 0 {
 		return nil, errors.New("coordinates must be divisible by 2")
 	}
 	points := len(coordinates) / 2
 
+	var area float64
+	if ring {
+		area = signedArea(coordinates)
+		if math.Abs(area) < v.MinimumArea {
+			return []float64{}, nil
+		}
+	}
+
 	minimum := 4
 	if v.MinimumPoints > 0 {
 		minimum = v.MinimumPoints
 	}
 
 	target := max(minimum, int(float64(points)*percentage))
-	if points <= minimum || target >= points {
+	if points <= minimum || (v.Tolerance == 0 && target >= points) {
 		result := make([]float64, len(coordinates))
 		copy(result, coordinates)
 		return result, nil
 	}
 
-	nodes := make([]node, points)
-	for i := range nodes {
-		if i == 0 {
-			nodes[i].Prev = points - 1
-		} else {
-			nodes[i].Prev = i - 1
-		}
-
-		if i == points-1 {
-			nodes[i].Next = 0
-		} else {
-			nodes[i].Next = i + 1
+	for {
+		result := v.eliminate(coordinates, target, ring, line)
+		if !ring || target >= points || sameOrientation(area, result) {
+			return result, nil
 		}
-		nodes[i].Removed = false
+		target = min(points, target*2)
+	}
+}
 
-		previous := nodes[i].Prev * 2
-		current := i * 2
-		next := nodes[i].Next * 2
-		nodes[i].Area = v.CalculateMetric(coordinates[previous:previous+2], coordinates[current:current+2], coordinates[next:next+2])
+// eliminate removes the points of the smallest effective area until target points remain, or until every
+// remaining point reaches the tolerance. The points of polygons are linked cyclically. The closing point of a
+// ring is left out and its first point is never removed, so that the ring can be closed again. The ends of a
+// line are never removed.
+func (v VisvalingamSimplifier) eliminate(coordinates []float64, target int, ring, line bool) []float64 {
+	points := len(coordinates) / 2
+	if ring {
+		points--
+		target--
+	}
+	point := func(i int) []float64 {
+		return coordinates[i*2 : i*2+2]
+	}
+	// fixed points are never removed and keep their neighbors.
+	fixed := func(i int) bool {
+		return (ring && i == 0) || (line && (i == 0 || i == points-1))
 	}
 
-	pq := make(priorityQueue, 0)
-	heap.Init(&pq)
-	for i := 0; i < points-1; i++ {
-		heap.Push(&pq, &pqItem{Index: i, Area: nodes[i].Area})
+	nodes := make([]node, points)
+	pq := make(priorityQueue, 0, points)
+	for i := range nodes {
+		nodes[i].Prev = (i - 1 + points) % points
+		nodes[i].Next = (i + 1) % points
+		if fixed(i) || (!ring && !line && i == points-1) {
+			continue
+		}
+		nodes[i].Area = v.CalculateMetric(point(nodes[i].Prev), point(i), point(nodes[i].Next))
+		pq = append(pq, &pqItem{Index: i, Area: nodes[i].Area})
 	}
+	heap.Init(&pq)
 
 	// Progressive Removal Loop
 	current := points
@@ -84,6 +128,11 @@ func (v VisvalingamSimplifier) Simplify(coordinates []float64, percentage float6
 			continue
 		}
 
+		// Every remaining point is at least as significant as the tolerance.
+		if v.Tolerance > 0 && item.Area >= v.Tolerance {
+			break
+		}
+
 		// Remove node
 		nodes[item.Index].Removed = true
 		current--
@@ -98,136 +147,127 @@ func (v VisvalingamSimplifier) Simplify(coordinates []float64, percentage float6
 		// We push NEW entries to the PQ.
 		// The old entries for `prev` and `next`
 		// remain in the PQ but will fail the "Stale check" when popped.
-
-		// Previous
-		prevprev := nodes[prev].Prev * 2
-		prevcurr := prev * 2
-		prevnext := nodes[prev].Next * 2
-		area := v.CalculateMetric(coordinates[prevprev:prevprev+2], coordinates[prevcurr:prevcurr+2], coordinates[prevnext:prevnext+2])
-		nodes[prev].Area = area
-		heap.Push(&pq, &pqItem{Index: prev, Area: area})
-		// Next
-		nextprev := nodes[next].Prev * 2
-		nextcurr := next * 2
-		nextnext := nodes[next].Next * 2
-		area = v.CalculateMetric(coordinates[nextprev:nextprev+2], coordinates[nextcurr:nextcurr+2], coordinates[nextnext:nextnext+2])
-		nodes[next].Area = area
-		heap.Push(&pq, &pqItem{Index: next, Area: area})
+		for _, neighbor := range []int{prev, next} {
+			if fixed(neighbor) {
+				continue
+			}
+			nodes[neighbor].Area = v.CalculateMetric(point(nodes[neighbor].Prev), point(neighbor), point(nodes[neighbor].Next))
+			heap.Push(&pq, &pqItem{Index: neighbor, Area: nodes[neighbor].Area})
+		}
 	}
 
-	result := make([]float64, target*2)
-	index := 0
+	result := make([]float64, 0, (current+1)*2)
 	for i, node := range nodes {
 		if !node.Removed {
-			result[index] = coordinates[i*2]
-			result[index+1] = coordinates[i*2+1]
-			index += 2
+			result = append(result, point(i)...)
 		}
 	}
-
-	return result, nil
+	if ring {
+		result = append(result, point(0)...)
+	}
+	return result
 }
 
-// Simplifies a set of points in place using the Visvalingam-Whyatt algorithm.
+// SimplifyPoints simplifies a set of points ([][x, y]) like Simplify. Points keep any values beyond X and Y.
 func (v VisvalingamSimplifier) SimplifyPoints(points [][]float64, percentage float64) ([][]float64, error) {
-	for _, point := range points {
-		if len(point) != 2 {
-			return nil, errors.New("points must be all be of length 2")
-		}
+	coordinates, err := flattenPoints(points)
+	if err != nil {
+		return nil, err
+	}
+	simplified, err := v.Simplify(coordinates, percentage)
+	if err != nil {
+		return nil, err
 	}
+	return matchPoints(points, simplified), nil
+}
 
-	minimum := 4
-	if v.MinimumPoints > 0 {
-		minimum = v.MinimumPoints
+// Rank assigns every point of a set of coordinates (flat [x, y, x, y, ...]) its importance, the effective
+// area at which Visvalingam-Whyatt removes it. A point never ranks below a point removed before it, so that
+// keeping the points above any rank gives the same result as removing points one at a time. Rings, whose
+// first and last points are equal, are ranked cyclically down to their last triangle, whose points share
+// the highest rank so that small rings can be dropped as a whole. The ends of open lines are never removed.
+func (v VisvalingamSimplifier) Rank(coordinates []float64) ([]float64, error) {
+	if len(coordinates)%2 != 0 {
+		return nil, errors.New("coordinates must be divisible by 2")
 	}
+	points := len(coordinates) / 2
+	ranks := make([]float64, points)
 
-	target := max(minimum, int(float64(len(points))*percentage))
-	if len(points) <= minimum || target >= len(points) {
-		result := make([][]float64, len(points))
-		for i, point := range points {
-			result[i] = append(result[i], point[0], point[1])
-		}
-		return result, nil
+	closed := closedRing(coordinates)
+	count := points
+	if closed {
+		// The closing point is ranked along with the first point.
+		count--
+	}
+	point := func(i int) []float64 {
+		return coordinates[i*2 : i*2+2]
 	}
 
-	nodes := make([]node, len(points))
+	nodes := make([]node, count)
+	pq := make(priorityQueue, 0, count)
 	for i := range nodes {
-		if i == 0 {
-			nodes[i].Prev = len(points) - 1
-		} else {
-			nodes[i].Prev = i - 1
-		}
-
-		if i == len(points)-1 {
-			nodes[i].Next = 0
-		} else {
-			nodes[i].Next = i + 1
+		nodes[i].Prev = i - 1
+		nodes[i].Next = i + 1
+		if closed {
+			nodes[i].Prev = (i - 1 + count) % count
+			nodes[i].Next = (i + 1) % count
+		} else if i == 0 || i == count-1 {
+			ranks[i] = math.MaxFloat64
+			continue
 		}
-		nodes[i].Removed = false
-		nodes[i].Area = v.CalculateMetric(points[nodes[i].Prev], points[i], points[nodes[i].Next])
+		nodes[i].Area = v.CalculateMetric(point(nodes[i].Prev), point(i), point(nodes[i].Next))
+		pq = append(pq, &pqItem{Index: i, Area: nodes[i].Area})
 	}
-
-	pq := make(priorityQueue, 0)
 	heap.Init(&pq)
-	for i := 0; i < len(points)-1; i++ {
-		heap.Push(&pq, &pqItem{Index: i, Area: nodes[i].Area})
-	}
-
-	// Progressive Removal Loop
-	current := len(points)
-	for current > target {
-		if pq.Len() == 0 {
-			break
-		}
 
+	// Rings stop at their last triangle and lines at their ends.
+	remaining := len(pq)
+	last := 0
+	if closed {
+		last = 3
+	}
+	highest := 0.0
+	for remaining > last && pq.Len() > 0 {
 		item := heap.Pop(&pq).(*pqItem)
-		if nodes[item.Index].Removed {
+		if nodes[item.Index].Removed || item.Area != nodes[item.Index].Area {
 			continue
 		}
 
-		// Stale check (Lazy Deletion):
-		// If the area in the PQ item doesn't match the current node area,
-		// it means this node was updated with a new area later in the queue.
-		// We discard this "stale" entry.
-		if item.Area != nodes[item.Index].Area {
-			continue
-		}
-
-		// Remove node
+		highest = max(highest, item.Area)
+		ranks[item.Index] = highest
 		nodes[item.Index].Removed = true
-		current--
+		remaining--
 
-		// Relink neighbors
 		prev := nodes[item.Index].Prev
 		next := nodes[item.Index].Next
 		nodes[prev].Next = next
 		nodes[next].Prev = prev
-
-		// Recalculate neighbors
-		// We push NEW entries to the PQ.
-		// The old entries for `prev` and `next`
-		// remain in the PQ but will fail the "Stale check" when popped.
-
-		// Previous
-		area := v.CalculateMetric(points[nodes[prev].Prev], points[prev], points[nodes[prev].Next])
-		nodes[prev].Area = area
-		heap.Push(&pq, &pqItem{Index: prev, Area: area})
-		// Next
-		area = v.CalculateMetric(points[nodes[next].Prev], points[next], points[nodes[next].Next])
-		nodes[next].Area = area
-		heap.Push(&pq, &pqItem{Index: next, Area: area})
+		for _, neighbor := range []int{prev, next} {
+			if !closed && (neighbor == 0 || neighbor == count-1) {
+				continue
+			}
+			nodes[neighbor].Area = v.CalculateMetric(point(nodes[neighbor].Prev), point(neighbor), point(nodes[neighbor].Next))
+			heap.Push(&pq, &pqItem{Index: neighbor, Area: nodes[neighbor].Area})
+		}
 	}
 
-	result := make([][]float64, target)
-	index := 0
-	for i, node := range nodes {
-		if !node.Removed {
-			result[index] = append(result[index], points[i][0], points[i][1])
-			index++
+	if closed {
+		// The last triangle shares the highest rank, which is at least its own area.
+		var triangle []int
+		for i := range nodes {
+			if !nodes[i].Removed {
+				triangle = append(triangle, i)
+			}
+		}
+		if len(triangle) == 3 {
+			highest = max(highest, v.CalculateMetric(point(triangle[0]), point(triangle[1]), point(triangle[2])))
 		}
+		for _, i := range triangle {
+			ranks[i] = highest
+		}
+		ranks[points-1] = ranks[0]
 	}
-
-	return result, nil
+	return ranks, nil
 }
 
 func (v VisvalingamSimplifier) CalculateMetric(a, b, c []float64) float64 {
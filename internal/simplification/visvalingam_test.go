@@ -0,0 +1,21 @@
+package simplification
+
+import "testing"
+
+func visvalingam(minimumPoints int, minimumArea float64) Simplifier {
+	return VisvalingamSimplifier{MinimumPoints: minimumPoints, MinimumArea: minimumArea}
+}
+
+func TestVisvalingamRings(t *testing.T) {
+	testRings(t, visvalingam)
+}
+
+func TestVisvalingamLines(t *testing.T) {
+	testLines(t, visvalingam)
+}
+
+func TestWeightedVisvalingamRings(t *testing.T) {
+	testRings(t, func(minimumPoints int, minimumArea float64) Simplifier {
+		return VisvalingamSimplifier{Weighting: 0.7, MinimumPoints: minimumPoints, MinimumArea: minimumArea}
+	})
+}